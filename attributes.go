@@ -1,7 +1,14 @@
 package kmip
 
 import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ansel1/merry"
 	"github.com/gemalto/kmip-go/kmip14"
+	"github.com/gemalto/kmip-go/ttlv"
 )
 
 // 3
@@ -14,11 +21,527 @@ import (
 // creates valid names. Clients are informed of such rules by a mechanism that is not specified by
 // this standard. Names SHALL be unique within a given key management domain,
 // but are NOT REQUIRED to be globally unique.
+//
+// Name Type is required, so a Name without one isn't valid, though it is still marshaled
+// as is.  Check names with Validate().  NewName() creates the common case, a Name with an
+// Uninterpreted Text String type.
 type Name struct {
 	NameValue string
 	NameType  kmip14.NameType
 }
 
+// NewName returns a Name with Name Type Uninterpreted Text String.
+func NewName(value string) Name {
+	return Name{NameValue: value, NameType: kmip14.NameTypeUninterpretedTextString}
+}
+
+// Validate returns ErrInvalidName if the Name Type isn't set.
+func (n Name) Validate() error {
+	if n.NameType == 0 {
+		return merry.Appendf(ErrInvalidName, "Name %q has no Name Type", n.NameValue)
+	}
+
+	return nil
+}
+
+// NameFromAttribute returns the Name in the value of a Name attribute.  The value may be a
+// Name, *Name, or the undecoded ttlv.TTLV, which is how Name values are unmarshaled into an
+// Attribute.  It returns ErrInvalidName if the Name isn't valid.
+func NameFromAttribute(a *Attribute) (Name, error) {
+	var n Name
+
+	switch v := a.AttributeValue.(type) {
+	case Name:
+		n = v
+	case *Name:
+		n = *v
+	case ttlv.TTLV:
+		if err := ttlv.Unmarshal(v, &n); err != nil {
+			return Name{}, merry.WithCause(ErrInvalidName, err).Append(err.Error())
+		}
+	default:
+		return Name{}, merry.Appendf(ErrInvalidName, "unexpected value type %T", a.AttributeValue)
+	}
+
+	if err := n.Validate(); err != nil {
+		return Name{}, err
+	}
+
+	return n, nil
+}
+
+// RevocationReason 3.31
+//
+// The Revocation Reason attribute is a structure used to indicate why the Managed Cryptographic
+// Object was revoked (e.g., "compromised", "expired", "no longer used", etc.).  This attribute is
+// only set by the server as a part of the Revoke Operation.
+//
+// The Revocation Message is optional, and is not encoded if empty.
+type RevocationReason struct {
+	RevocationReasonCode kmip14.RevocationReasonCode
+	RevocationMessage    string `ttlv:",omitempty"`
+}
+
+// Compromised returns true if the reason code is Key Compromise or CA Compromise, the reasons
+// which place the revoked object in the Compromised state, and set its Compromise Date.
+func (r RevocationReason) Compromised() bool {
+	return r.RevocationReasonCode == kmip14.RevocationReasonCodeKeyCompromise ||
+		r.RevocationReasonCode == kmip14.RevocationReasonCodeCACompromise
+}
+
+// RevocationReasonFromAttribute returns the RevocationReason in the value of a Revocation Reason
+// attribute.  As with NameFromAttribute(), the value may be a RevocationReason, *RevocationReason, or
+// the undecoded ttlv.TTLV.
+func RevocationReasonFromAttribute(a *Attribute) (RevocationReason, error) {
+	switch v := a.AttributeValue.(type) {
+	case RevocationReason:
+		return v, nil
+	case *RevocationReason:
+		return *v, nil
+	case ttlv.TTLV:
+		var r RevocationReason
+		if err := ttlv.Unmarshal(v, &r); err != nil {
+			return RevocationReason{}, merry.Prepend(err, "invalid Revocation Reason")
+		}
+
+		return r, nil
+	default:
+		return RevocationReason{}, merry.Errorf("invalid Revocation Reason: unexpected value type %T", a.AttributeValue)
+	}
+}
+
+// TimeFromAttribute returns the time in the value of a date attribute, like Activation Date.  The value
+// may be a time.Time, *time.Time, or ttlv.DateTimeExtended, which is how DateTime and DateTimeExtended values
+// are unmarshaled into an Attribute.
+func TimeFromAttribute(a *Attribute) (time.Time, error) {
+	switch v := a.AttributeValue.(type) {
+	case time.Time:
+		return v, nil
+	case *time.Time:
+		return *v, nil
+	case ttlv.DateTimeExtended:
+		return v.Time, nil
+	default:
+		return time.Time{}, merry.Errorf("invalid %s: unexpected value type %T", a.AttributeName, a.AttributeValue)
+	}
+}
+
+// Attributes holds a list of attributes, and encodes it as the KMIP 2.0 Attributes structure, in which
+// each attribute is encoded with its own tag, rather than as an Attribute structure.  Since the
+// attributes are held as the same Attribute values used by KMIP 1.x payloads, like TemplateAttribute,
+// the same list can be encoded in either form.
+//
+// Attribute names must be registered tags.  KMIP 2.0 has no Attribute Index, so the index is not
+// encoded.  Instead, when unmarshaling, the instances of each attribute are numbered in the order
+// they appear.  Unmarshaling also accepts KMIP 1.x Attribute structures as members.  Structure values
+// are unmarshaled as ttlv.TTLV, and other values as the result of TTLV.Value().
+type Attributes struct {
+	Attributes []Attribute
+}
+
+// Add appends a new instance of the attribute with the tag.
+func (a *Attributes) Add(tag ttlv.Tag, value interface{}) {
+	a.Attributes = append(a.Attributes, NewAttributeFromTag(tag, len(a.GetAllTag(tag)), value))
+}
+
+// Get returns a reference to the first Attribute in the list matching the name.
+// Returns nil if not found.
+func (a *Attributes) Get(s string) *Attribute {
+	if a == nil {
+		return nil
+	}
+
+	for i := range a.Attributes {
+		if a.Attributes[i].AttributeName == s {
+			return &a.Attributes[i]
+		}
+	}
+
+	return nil
+}
+
+// GetTag returns a reference to the first Attribute in the list matching the tag.
+// Returns nil if not found.
+func (a *Attributes) GetTag(tag ttlv.Tag) *Attribute {
+	return a.Get(tag.CanonicalName())
+}
+
+// GetAllTag returns all the instances of the attribute with the tag, in order.
+func (a *Attributes) GetAllTag(tag ttlv.Tag) []Attribute {
+	if a == nil {
+		return nil
+	}
+
+	var ret []Attribute
+
+	for i := range a.Attributes {
+		if a.Attributes[i].AttributeName == tag.CanonicalName() {
+			ret = append(ret, a.Attributes[i])
+		}
+	}
+
+	return ret
+}
+
+// Time returns the value of the first instance of the named date attribute, e.g. "Activation Date".
+// Returns false if the attribute is absent, or its value isn't a time.  See TimeFromAttribute().
+func (a *Attributes) Time(s string) (time.Time, bool) {
+	attr := a.Get(s)
+	if attr == nil {
+		return time.Time{}, false
+	}
+
+	t, err := TimeFromAttribute(attr)
+
+	return t, err == nil
+}
+
+func (a *Attributes) tagTime(tag ttlv.Tag) (time.Time, bool) {
+	return a.Time(tag.CanonicalName())
+}
+
+// InitialDate returns the Initial Date attribute, 3.23.  This and the other accessors of the
+// standard date attributes return false if the attribute is absent.
+func (a *Attributes) InitialDate() (time.Time, bool) {
+	return a.tagTime(kmip14.TagInitialDate)
+}
+
+// ActivationDate returns the Activation Date attribute, 3.24.
+func (a *Attributes) ActivationDate() (time.Time, bool) {
+	return a.tagTime(kmip14.TagActivationDate)
+}
+
+// ProcessStartDate returns the Process Start Date attribute, 3.25.
+func (a *Attributes) ProcessStartDate() (time.Time, bool) {
+	return a.tagTime(kmip14.TagProcessStartDate)
+}
+
+// ProtectStopDate returns the Protect Stop Date attribute, 3.26.
+func (a *Attributes) ProtectStopDate() (time.Time, bool) {
+	return a.tagTime(kmip14.TagProtectStopDate)
+}
+
+// DeactivationDate returns the Deactivation Date attribute, 3.27.
+func (a *Attributes) DeactivationDate() (time.Time, bool) {
+	return a.tagTime(kmip14.TagDeactivationDate)
+}
+
+// DestroyDate returns the Destroy Date attribute, 3.28.
+func (a *Attributes) DestroyDate() (time.Time, bool) {
+	return a.tagTime(kmip14.TagDestroyDate)
+}
+
+// CompromiseOccurrenceDate returns the Compromise Occurrence Date attribute, 3.29.
+func (a *Attributes) CompromiseOccurrenceDate() (time.Time, bool) {
+	return a.tagTime(kmip14.TagCompromiseOccurrenceDate)
+}
+
+// CompromiseDate returns the Compromise Date attribute, 3.30.
+func (a *Attributes) CompromiseDate() (time.Time, bool) {
+	return a.tagTime(kmip14.TagCompromiseDate)
+}
+
+// ArchiveDate returns the Archive Date attribute, 3.32.
+func (a *Attributes) ArchiveDate() (time.Time, bool) {
+	return a.tagTime(kmip14.TagArchiveDate)
+}
+
+// LastChangeDate returns the Last Change Date attribute, 3.38.
+func (a *Attributes) LastChangeDate() (time.Time, bool) {
+	return a.tagTime(kmip14.TagLastChangeDate)
+}
+
+// OriginalCreationDate returns the Original Creation Date attribute, 3.43.
+func (a *Attributes) OriginalCreationDate() (time.Time, bool) {
+	return a.tagTime(kmip14.TagOriginalCreationDate)
+}
+
+func (a *Attributes) MarshalTTLV(e *ttlv.Encoder, tag ttlv.Tag) error {
+	return e.EncodeStructure(tag, func(e *ttlv.Encoder) error {
+		for _, attr := range a.Attributes {
+			t, err := ttlv.DefaultRegistry.ParseTag(attr.AttributeName)
+			if err != nil {
+				return merry.Prependf(err, "attribute name %q cannot be encoded as a tag", attr.AttributeName)
+			}
+
+			if err := e.EncodeValue(t, retagTTLV(attr.AttributeValue, t)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (a *Attributes) UnmarshalTTLV(d *ttlv.Decoder, v ttlv.TTLV) error {
+	if len(v) == 0 {
+		return nil
+	}
+
+	if v.Type() != ttlv.TypeStructure {
+		return merry.Errorf("invalid type for Attributes: %s", v.Type().String())
+	}
+
+	a.Attributes = nil
+	counts := map[ttlv.Tag]int{}
+
+	for n := v.ValueStructure(); len(n) > 0; n = n.Next() {
+		if n.Tag() == kmip14.TagAttribute {
+			var attr Attribute
+			if err := d.DecodeValue(&attr, n); err != nil {
+				return err
+			}
+
+			a.Attributes = append(a.Attributes, attr)
+
+			continue
+		}
+
+		attr := Attribute{AttributeName: n.Tag().CanonicalName(), AttributeIndex: counts[n.Tag()]}
+		counts[n.Tag()]++
+
+		if n.Type() == ttlv.TypeStructure {
+			// tagged the way an Attribute structure's value would be, so the attribute can be
+			// marshaled in either form
+			attr.AttributeValue = retagTTLV(n[:n.FullLen()], kmip14.TagAttributeValue)
+		} else {
+			attr.AttributeValue = n.Value()
+		}
+
+		a.Attributes = append(a.Attributes, attr)
+	}
+
+	return nil
+}
+
+// retagTTLV returns a copy of v with its tag replaced, if v is an encoded TTLV value with a
+// different tag.  Other values are returned unchanged.
+func retagTTLV(v interface{}, tag ttlv.Tag) interface{} {
+	t, ok := v.(ttlv.TTLV)
+	if !ok || len(t) < 3 || t.Tag() == tag {
+		return v
+	}
+
+	c := append(ttlv.TTLV(nil), t...)
+	c[0], c[1], c[2] = byte(tag>>16), byte(tag>>8), byte(tag)
+
+	return c
+}
+
+// RangeAttributes calls fn with the name and value of each attribute in the message t, in the order
+// they are encoded, until fn returns false.  Attributes are found anywhere in t, in either form: the
+// KMIP 1.x Attribute structures, e.g. in a Template-Attribute, and the members of the KMIP 2.0 Attributes,
+// Common Attributes, Private Key Attributes, and Public Key Attributes structures.  t may itself be one
+// of these structures.
+//
+// So code like logging, diffing, or redaction can treat both forms alike, the name is always the
+// canonical name, e.g. "Cryptographic Algorithm", and the value is always tagged Attribute Value, as it
+// is in an Attribute structure, so the same attribute yields the same name and value in either form.
+// Attribute Indexes are ignored.  The values may share memory with t.
+//
+// Returns the error from t.Valid() if t is invalid, in which case fn is not called.
+func RangeAttributes(t ttlv.TTLV, fn func(name string, value ttlv.TTLV) bool) error {
+	if len(t) == 0 {
+		return nil
+	}
+
+	if err := t.Valid(); err != nil {
+		return err
+	}
+
+	rangeAttributes(t[:t.FullLen()], fn)
+
+	return nil
+}
+
+// rangeAttributes implements RangeAttributes on a valid value, returning false if fn stopped the iteration.
+func rangeAttributes(t ttlv.TTLV, fn func(name string, value ttlv.TTLV) bool) bool {
+	if t.Type() != ttlv.TypeStructure {
+		return true
+	}
+
+	switch t.Tag() {
+	case kmip14.TagAttribute:
+		var name string
+
+		var value ttlv.TTLV
+
+		for n := t.ValueStructure(); len(n) > 0; n = n.Next() {
+			switch {
+			case n.Tag() == kmip14.TagAttributeName && n.Type() == ttlv.TypeTextString:
+				name = n.ValueTextString()
+			case n.Tag() == kmip14.TagAttributeValue:
+				value = n[:n.FullLen()]
+			}
+		}
+
+		return fn(name, value)
+	case tagAttributes, tagCommonAttributes, tagPrivateKeyAttributes, tagPublicKeyAttributes:
+		for n := t.ValueStructure(); len(n) > 0; n = n.Next() {
+			if n.Tag() == kmip14.TagAttribute {
+				// vendor attributes are still encoded as Attribute structures in KMIP 2.0
+				if !rangeAttributes(n[:n.FullLen()], fn) {
+					return false
+				}
+
+				continue
+			}
+
+			value, _ := retagTTLV(n[:n.FullLen()], kmip14.TagAttributeValue).(ttlv.TTLV)
+			if !fn(n.Tag().CanonicalName(), value) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	for n := t.ValueStructure(); len(n) > 0; n = n.Next() {
+		if !rangeAttributes(n[:n.FullLen()], fn) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// EncodeAttributesMap encodes an Attribute structure for each entry in m, which maps attribute
+// names to values, e.g. as read from a config file or JSON document.  Attributes are encoded in
+// the order of their names, sorted, since maps are unordered.
+//
+// Names may be canonical, e.g. "Cryptographic Algorithm", or normalized, e.g. "CryptographicAlgorithm",
+// and are encoded as the canonical name.  Names which aren't registered tags in the DefaultRegistry
+// return ErrUnknownAttribute, unless lenient is true, or the name starts with "x-" or "y-", the prefixes
+// of custom attributes.  Unknown attributes are encoded with the given name, and the value's default
+// encoding.
+//
+// The type of each value is inferred from the registry:
+//
+//   - For attributes registered as an enum, a string or integer value is encoded as an
+//     Enumeration, and strings are parsed with ParseEnum, e.g. "AES" or "0x00000003".
+//   - For attributes registered as a bitmask, a string, integer, or list of strings is
+//     encoded as an Integer, and strings are parsed with ParseInt, e.g. "Encrypt|Decrypt".
+//   - Whole float64 values, which is how JSON numbers are decoded, are encoded as integers.
+//
+// Other values are encoded the same way as an Attribute's AttributeValue, e.g. strings as Text
+// Strings, and structs like Name as Structures.
+func EncodeAttributesMap(e *ttlv.Encoder, m map[string]interface{}, lenient bool) error {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		a, err := attributeFromMapEntry(name, m[name], lenient)
+		if err != nil {
+			return merry.Prepend(err, name)
+		}
+
+		if err := e.EncodeValue(kmip14.TagAttribute, &a); err != nil {
+			return merry.Prepend(err, name)
+		}
+	}
+
+	return nil
+}
+
+func attributeFromMapEntry(name string, value interface{}, lenient bool) (Attribute, error) {
+	tag, err := ttlv.DefaultRegistry.ParseTag(ttlv.NormalizeName(name))
+	if err != nil {
+		if !lenient && !strings.HasPrefix(name, "x-") && !strings.HasPrefix(name, "y-") {
+			return Attribute{}, merry.Here(ErrUnknownAttribute)
+		}
+
+		return Attribute{AttributeName: name, AttributeValue: wholeFloatToInt(value)}, nil
+	}
+
+	a := Attribute{AttributeName: tag.CanonicalName(), AttributeValue: wholeFloatToInt(value)}
+
+	enum := ttlv.DefaultRegistry.EnumForTag(tag)
+	if enum == nil {
+		return a, nil
+	}
+
+	switch v := a.AttributeValue.(type) {
+	case string:
+		if enum.Bitmask() {
+			i, err := ttlv.ParseInt(v, enum)
+			a.AttributeValue = i
+
+			return a, err
+		}
+
+		u, err := ttlv.ParseEnum(v, enum)
+		a.AttributeValue = ttlv.EnumValue(u)
+
+		return a, err
+	case []string, []interface{}:
+		if !enum.Bitmask() {
+			return a, merry.Errorf("a list of values is only valid for a bitmask, got %v", v)
+		}
+
+		items, ok := stringList(v)
+		if !ok {
+			return a, merry.Errorf("bitmask values must be strings, got %v", v)
+		}
+
+		var mask int32
+
+		for _, item := range items {
+			i, err := ttlv.ParseInt(item, enum)
+			if err != nil {
+				return a, err
+			}
+
+			mask |= i
+		}
+
+		a.AttributeValue = mask
+	case int:
+		if enum.Bitmask() {
+			a.AttributeValue = int32(v)
+		} else {
+			a.AttributeValue = ttlv.EnumValue(v)
+		}
+	}
+
+	return a, nil
+}
+
+// wholeFloatToInt converts whole float64 values to ints, since JSON numbers are decoded as float64.
+func wholeFloatToInt(v interface{}) interface{} {
+	if f, ok := v.(float64); ok && f == math.Trunc(f) && f >= math.MinInt32 && f <= math.MaxUint32 {
+		return int(f)
+	}
+
+	return v
+}
+
+// stringList converts a []string, or a []interface{} containing only strings, to a []string.
+func stringList(v interface{}) ([]string, bool) {
+	switch l := v.(type) {
+	case []string:
+		return l, true
+	case []interface{}:
+		ret := make([]string, len(l))
+
+		for i, item := range l {
+			s, ok := item.(string)
+			if !ok {
+				return nil, false
+			}
+
+			ret[i] = s
+		}
+
+		return ret, true
+	}
+
+	return nil, false
+}
+
 // Cryptographic Parameters 3.6 Table 65
 //
 // The Cryptographic Parameters attribute is a structure (see Table 65) that contains a set of OPTIONAL
@@ -68,3 +591,23 @@ type CryptographicParameters struct {
 	PSource                       []byte                           `ttlv:",omitempty"`
 	TrailerField                  int                              `ttlv:",omitempty"`
 }
+
+// CryptographicDomainParameters 3.7 Table 67
+//
+// The Cryptographic Domain Parameters attribute is a structure (see Table 67) that contains a set of OPTIONAL
+// fields that MAY need to be specified in the Create Key Pair Request Payload. Specific fields MAY only pertain
+// to certain types of Managed Cryptographic Objects.
+//
+// The domain parameter Qlength corresponds to the bit length of parameter Q (refer to [SEC2] and [SP800-56A]).
+// Qlength applies to algorithms such as DSA and DH. The bit length of parameter P (refer to [SEC2] and [SP800-56A])
+// is specified separately by setting the Cryptographic Length attribute.
+//
+// Recommended Curve is applicable to elliptic curve algorithms such as ECDSA, ECDH, and ECMQV.
+//
+// Curves added in KMIP 2.0, like Curve25519, are declared in the kmip20 package, and can be converted, e.g.
+// kmip14.RecommendedCurve(kmip20.RecommendedCurveCURVE25519).  They are formatted and parsed by name once the
+// kmip20 package has registered its definitions.
+type CryptographicDomainParameters struct {
+	Qlength          int                     `ttlv:",omitempty"`
+	RecommendedCurve kmip14.RecommendedCurve `ttlv:",omitempty"`
+}
@@ -0,0 +1,474 @@
+package kmip
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gemalto/kmip-go/kmip14"
+	"github.com/gemalto/kmip-go/ttlv"
+	"github.com/gemalto/kmip-go/ttlv/ttlvtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCryptographicDomainParameters(t *testing.T) {
+	var ta TemplateAttribute
+	ta.Append(kmip14.TagCryptographicAlgorithm, kmip14.CryptographicAlgorithmEC)
+	ta.Append(kmip14.TagCryptographicDomainParameters, CryptographicDomainParameters{
+		RecommendedCurve: kmip14.RecommendedCurveP_256,
+	})
+
+	b, err := ttlv.Marshal(ttlv.Value{Tag: kmip14.TagRequestPayload, Value: CreateKeyPairRequestPayload{CommonTemplateAttribute: &ta}})
+	require.NoError(t, err)
+
+	var payload CreateKeyPairRequestPayload
+	require.NoError(t, ttlv.Unmarshal(b, &payload))
+
+	a := payload.CommonTemplateAttribute.GetTag(kmip14.TagCryptographicDomainParameters)
+	require.NotNil(t, a)
+
+	// structured attribute values decode as TTLV
+	v, ok := a.AttributeValue.(ttlv.TTLV)
+	require.True(t, ok)
+
+	var params CryptographicDomainParameters
+	require.NoError(t, ttlv.Unmarshal(v, &params))
+	assert.Equal(t, CryptographicDomainParameters{RecommendedCurve: kmip14.RecommendedCurveP_256}, params)
+
+	// the curve is encoded by name
+	j, err := json.Marshal(v)
+	require.NoError(t, err)
+	assert.Contains(t, string(j), `"value":"P_256"`)
+}
+
+func TestName(t *testing.T) {
+	err := Name{NameValue: "my-key"}.Validate()
+	require.True(t, errors.Is(err, ErrInvalidName), Details(err))
+	require.NoError(t, NewName("my-key").Validate())
+
+	// invalid names are still marshaled, so they can be sent to servers which accept them
+	_, err = ttlv.Marshal(ttlv.Value{Tag: kmip14.TagName, Value: Name{NameValue: "my-key"}})
+	require.NoError(t, err)
+
+	_, err = NameFromAttribute(&Attribute{AttributeName: "Name", AttributeValue: Name{NameValue: "my-key"}})
+	require.True(t, errors.Is(err, ErrInvalidName), Details(err))
+
+	b, err := ttlv.Marshal(ttlv.Value{Tag: kmip14.TagName, Value: NewName("my-key")})
+	require.NoError(t, err)
+
+	expected, err := ttlv.Marshal(ttlv.NewStruct(kmip14.TagName,
+		ttlv.NewValue(kmip14.TagNameValue, "my-key"),
+		ttlv.NewValue(kmip14.TagNameType, kmip14.NameTypeUninterpretedTextString),
+	))
+	require.NoError(t, err)
+	assert.Equal(t, expected, b)
+
+	names := []Name{NewName("my-key"), {NameValue: "https://example.com/keys/1", NameType: kmip14.NameTypeURI}}
+
+	for _, version := range []ProtocolVersion{{ProtocolVersionMajor: 1, ProtocolVersionMinor: 4}, {ProtocolVersionMajor: 2}} {
+		b, err := ttlv.Marshal(ttlv.Value{Tag: kmip14.TagResponsePayload, Value: &GetAttributesResponsePayload{
+			ProtocolVersion:  version,
+			UniqueIdentifier: "1",
+			Attribute: []Attribute{
+				NewAttributeFromTag(kmip14.TagName, 0, names[0]),
+				NewAttributeFromTag(kmip14.TagCryptographicLength, 0, 256),
+				NewAttributeFromTag(kmip14.TagName, 1, &names[1]),
+			},
+		}})
+		require.NoError(t, err)
+
+		var p GetAttributesResponsePayload
+		require.NoError(t, ttlv.Unmarshal(b, &p))
+
+		decoded, err := p.Names()
+		require.NoError(t, err)
+		assert.Equal(t, names, decoded)
+	}
+
+	_, err = NameFromAttribute(&Attribute{AttributeName: "Name", AttributeValue: "my-key"})
+	assert.True(t, errors.Is(err, ErrInvalidName), Details(err))
+}
+
+func TestEncodeAttributesMap(t *testing.T) {
+	var m map[string]interface{}
+
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"Cryptographic Algorithm": "AES",
+		"CryptographicLength": 256,
+		"Cryptographic Usage Mask": ["Encrypt", "Decrypt"],
+		"State": 1,
+		"Object Group": "group",
+		"x-purpose": "testing"
+	}`), &m))
+	m["Name"] = NewName("key")
+
+	encode := func(m map[string]interface{}, lenient bool) (*TemplateAttribute, error) {
+		var buf bytes.Buffer
+
+		enc := ttlv.NewEncoder(&buf)
+		err := enc.EncodeStructure(kmip14.TagTemplateAttribute, func(e *ttlv.Encoder) error {
+			return EncodeAttributesMap(e, m, lenient)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		require.NoError(t, enc.Flush())
+
+		var ta TemplateAttribute
+		require.NoError(t, ttlv.Unmarshal(buf.Bytes(), &ta))
+
+		return &ta, nil
+	}
+
+	ta, err := encode(m, false)
+	require.NoError(t, err)
+
+	var names []string
+	for _, a := range ta.Attribute {
+		names = append(names, a.AttributeName)
+	}
+
+	assert.Equal(t, []string{
+		"Cryptographic Algorithm", "Cryptographic Usage Mask", "Cryptographic Length",
+		"Name", "Object Group", "State", "x-purpose",
+	}, names)
+
+	assert.Equal(t, ttlv.EnumValue(kmip14.CryptographicAlgorithmAES), ta.GetTag(kmip14.TagCryptographicAlgorithm).AttributeValue)
+	assert.Equal(t, int32(256), ta.GetTag(kmip14.TagCryptographicLength).AttributeValue)
+	assert.Equal(t, int32(kmip14.CryptographicUsageMaskEncrypt|kmip14.CryptographicUsageMaskDecrypt),
+		ta.GetTag(kmip14.TagCryptographicUsageMask).AttributeValue)
+	assert.Equal(t, ttlv.EnumValue(kmip14.StatePreActive), ta.GetTag(kmip14.TagState).AttributeValue)
+	assert.Equal(t, "group", ta.GetTag(kmip14.TagObjectGroup).AttributeValue)
+	assert.Equal(t, "testing", ta.Get("x-purpose").AttributeValue)
+
+	name, err := NameFromAttribute(ta.GetTag(kmip14.TagName))
+	require.NoError(t, err)
+	assert.Equal(t, NewName("key"), name)
+
+	_, err = encode(map[string]interface{}{"Flavor": "vanilla"}, false)
+	require.True(t, errors.Is(err, ErrUnknownAttribute), Details(err))
+
+	ta, err = encode(map[string]interface{}{"Flavor": "vanilla"}, true)
+	require.NoError(t, err)
+	assert.Equal(t, "vanilla", ta.Get("Flavor").AttributeValue)
+
+	_, err = encode(map[string]interface{}{"Cryptographic Algorithm": "Rot13"}, false)
+	require.True(t, errors.Is(err, ttlv.ErrUnregisteredEnumName), Details(err))
+
+	_, err = encode(map[string]interface{}{"Cryptographic Algorithm": []string{"AES"}}, false)
+	require.Error(t, err)
+}
+
+func TestRevocationReasonFromAttribute(t *testing.T) {
+	compromised := time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	b, err := ttlv.Marshal(ttlv.Value{Tag: kmip14.TagResponsePayload, Value: &GetAttributesResponsePayload{
+		UniqueIdentifier: "1",
+		Attribute: []Attribute{
+			NewAttributeFromTag(kmip14.TagRevocationReason, 0, RevocationReason{RevocationReasonCode: kmip14.RevocationReasonCodeSuperseded}),
+			NewAttributeFromTag(kmip14.TagCompromiseDate, 0, compromised),
+		},
+	}})
+	require.NoError(t, err)
+
+	var payload GetAttributesResponsePayload
+	require.NoError(t, ttlv.Unmarshal(b, &payload))
+
+	a := payload.Get("Revocation Reason")
+	require.NotNil(t, a)
+
+	reason, err := RevocationReasonFromAttribute(a)
+	require.NoError(t, err)
+	assert.Equal(t, RevocationReason{RevocationReasonCode: kmip14.RevocationReasonCodeSuperseded}, reason)
+	assert.False(t, reason.Compromised())
+
+	a = payload.Get("Compromise Date")
+	require.NotNil(t, a)
+	assert.Equal(t, compromised, a.AttributeValue)
+
+	_, err = RevocationReasonFromAttribute(&Attribute{AttributeValue: "superseded"})
+	require.EqualError(t, err, "invalid Revocation Reason: unexpected value type string")
+}
+
+func TestAttributes(t *testing.T) {
+	var attrs Attributes
+	attrs.Add(kmip14.TagCryptographicAlgorithm, kmip14.CryptographicAlgorithmAES)
+	attrs.Add(kmip14.TagCryptographicLength, 256)
+	attrs.Add(kmip14.TagName, NewName("first"))
+	attrs.Add(kmip14.TagName, NewName("second"))
+
+	assert.Len(t, attrs.GetAllTag(kmip14.TagName), 2)
+	assert.Equal(t, 1, attrs.GetAllTag(kmip14.TagName)[1].AttributeIndex)
+
+	b, err := ttlv.Marshal(ttlv.Value{Tag: tagAttributes, Value: &attrs})
+	require.NoError(t, err)
+
+	expected, err := ttlv.Marshal(ttlv.NewStruct(tagAttributes,
+		ttlv.NewValue(kmip14.TagCryptographicAlgorithm, kmip14.CryptographicAlgorithmAES),
+		ttlv.NewValue(kmip14.TagCryptographicLength, 256),
+		ttlv.NewStruct(kmip14.TagName,
+			ttlv.NewValue(kmip14.TagNameValue, "first"),
+			ttlv.NewValue(kmip14.TagNameType, kmip14.NameTypeUninterpretedTextString),
+		),
+		ttlv.NewStruct(kmip14.TagName,
+			ttlv.NewValue(kmip14.TagNameValue, "second"),
+			ttlv.NewValue(kmip14.TagNameType, kmip14.NameTypeUninterpretedTextString),
+		),
+	))
+	require.NoError(t, err)
+	ttlvtest.AssertTTLVEqual(t, expected, b)
+
+	var decoded Attributes
+	require.NoError(t, ttlv.Unmarshal(b, &decoded))
+	require.Len(t, decoded.Attributes, 4)
+
+	assert.Equal(t, ttlv.EnumValue(kmip14.CryptographicAlgorithmAES), decoded.GetTag(kmip14.TagCryptographicAlgorithm).AttributeValue)
+	assert.Equal(t, int32(256), decoded.GetTag(kmip14.TagCryptographicLength).AttributeValue)
+
+	names := decoded.GetAllTag(kmip14.TagName)
+	require.Len(t, names, 2)
+	assert.Equal(t, 1, names[1].AttributeIndex)
+
+	name, err := NameFromAttribute(&names[1])
+	require.NoError(t, err)
+	assert.Equal(t, NewName("second"), name)
+
+	// the same attributes can be encoded in the 1.x form
+	b, err = ttlv.Marshal(ttlv.Value{Tag: kmip14.TagTemplateAttribute, Value: TemplateAttribute{Attribute: decoded.Attributes}})
+	require.NoError(t, err)
+
+	var ta TemplateAttribute
+	require.NoError(t, ttlv.Unmarshal(b, &ta))
+	assert.Len(t, ta.Attribute, 4)
+	assert.Equal(t, 1, ta.GetAllTag(kmip14.TagName)[1].AttributeIndex)
+	assert.Equal(t, kmip14.TagAttributeValue, ta.GetAllTag(kmip14.TagName)[1].AttributeValue.(ttlv.TTLV).Tag())
+
+	// and Attribute structures are accepted when unmarshaling Attributes
+	b, err = ttlv.Marshal(ttlv.NewStruct(tagAttributes,
+		ttlv.NewValue(kmip14.TagAttribute, NewAttributeFromTag(kmip14.TagCryptographicLength, 0, 128)),
+	))
+	require.NoError(t, err)
+	require.NoError(t, ttlv.Unmarshal(b, &decoded))
+	assert.Equal(t, []Attribute{NewAttributeFromTag(kmip14.TagCryptographicLength, 0, int32(128))}, decoded.Attributes)
+
+	_, err = ttlv.Marshal(ttlv.Value{Tag: tagAttributes, Value: &Attributes{Attributes: []Attribute{{AttributeName: "x-custom", AttributeValue: "red"}}}})
+	require.Error(t, err)
+}
+
+func TestAttributes_structureRoundTrip(t *testing.T) {
+	// structure values followed by siblings must be decoded without the siblings
+	in, err := ttlv.Marshal(s(tagAttributes,
+		s(kmip14.TagName,
+			v(kmip14.TagNameValue, "first"),
+			v(kmip14.TagNameType, kmip14.NameTypeUninterpretedTextString),
+		),
+		s(kmip14.TagName,
+			v(kmip14.TagNameValue, "second"),
+			v(kmip14.TagNameType, kmip14.NameTypeURI),
+		),
+		v(kmip14.TagCryptographicLength, 256),
+	))
+	require.NoError(t, err)
+
+	var attrs Attributes
+	require.NoError(t, ttlv.Unmarshal(in, &attrs))
+	require.Len(t, attrs.Attributes, 3)
+
+	for _, a := range attrs.GetAllTag(kmip14.TagName) {
+		value, ok := a.AttributeValue.(ttlv.TTLV)
+		require.True(t, ok)
+		assert.Len(t, value, value.FullLen())
+	}
+
+	out, err := ttlv.Marshal(ttlv.Value{Tag: tagAttributes, Value: &attrs})
+	require.NoError(t, err)
+	ttlvtest.AssertTTLVEqual(t, in, out)
+
+	// and in the 1.x form
+	out, err = ttlv.Marshal(ttlv.Value{Tag: kmip14.TagTemplateAttribute, Value: TemplateAttribute{Attribute: attrs.Attributes}})
+	require.NoError(t, err)
+
+	var ta TemplateAttribute
+	require.NoError(t, ttlv.Unmarshal(out, &ta))
+
+	out, err = ttlv.Marshal(ttlv.Value{Tag: tagAttributes, Value: &Attributes{Attributes: ta.Attribute}})
+	require.NoError(t, err)
+	ttlvtest.AssertTTLVEqual(t, in, out)
+
+	// the Get Attributes response decodes the 2.0 form the same way
+	resp, err := ttlv.Marshal(s(kmip14.TagResponsePayload,
+		v(kmip14.TagUniqueIdentifier, "1"),
+		ttlv.Value{Tag: tagAttributes, Value: in},
+	))
+	require.NoError(t, err)
+
+	payload := GetAttributesResponsePayload{ProtocolVersion: ProtocolVersion{ProtocolVersionMajor: 2}}
+	require.NoError(t, ttlv.Unmarshal(resp, &payload))
+
+	out, err = ttlv.Marshal(ttlv.Value{Tag: kmip14.TagResponsePayload, Value: &payload})
+	require.NoError(t, err)
+	ttlvtest.AssertTTLVEqual(t, resp, out)
+}
+
+func TestRangeAttributes(t *testing.T) {
+	name := Name{NameValue: "key1", NameType: kmip14.NameTypeUninterpretedTextString}
+
+	v1, err := ttlv.Marshal(s(kmip14.TagRequestPayload,
+		v(kmip14.TagObjectType, kmip14.ObjectTypeSymmetricKey),
+		s(kmip14.TagTemplateAttribute,
+			s(kmip14.TagAttribute,
+				v(kmip14.TagAttributeName, "Cryptographic Algorithm"),
+				v(kmip14.TagAttributeValue, kmip14.CryptographicAlgorithmAES),
+			),
+			s(kmip14.TagAttribute,
+				v(kmip14.TagAttributeName, "Cryptographic Length"),
+				v(kmip14.TagAttributeValue, 256),
+			),
+			s(kmip14.TagAttribute,
+				v(kmip14.TagAttributeName, "Name"),
+				v(kmip14.TagAttributeIndex, 0),
+				v(kmip14.TagAttributeValue, name),
+			),
+			s(kmip14.TagAttribute,
+				v(kmip14.TagAttributeName, "x-custom"),
+				v(kmip14.TagAttributeValue, "hello"),
+			),
+		),
+	))
+	require.NoError(t, err)
+
+	v2, err := ttlv.Marshal(s(kmip14.TagRequestPayload,
+		v(kmip14.TagObjectType, kmip14.ObjectTypeSymmetricKey),
+		s(tagAttributes,
+			v(kmip14.TagCryptographicAlgorithm, kmip14.CryptographicAlgorithmAES),
+			v(kmip14.TagCryptographicLength, 256),
+			v(kmip14.TagName, name),
+			s(kmip14.TagAttribute,
+				v(kmip14.TagAttributeName, "x-custom"),
+				v(kmip14.TagAttributeValue, "hello"),
+			),
+		),
+	))
+	require.NoError(t, err)
+
+	type pair struct {
+		name  string
+		value ttlv.TTLV
+	}
+
+	collect := func(msg ttlv.TTLV) []pair {
+		var pairs []pair
+
+		require.NoError(t, RangeAttributes(msg, func(name string, value ttlv.TTLV) bool {
+			pairs = append(pairs, pair{name: name, value: value})
+			return true
+		}))
+
+		return pairs
+	}
+
+	pairs := collect(v1)
+	require.Len(t, pairs, 4)
+	assert.Equal(t, pairs, collect(v2))
+
+	assert.Equal(t, "Cryptographic Algorithm", pairs[0].name)
+	assert.Equal(t, kmip14.TagAttributeValue, pairs[0].value.Tag())
+	assert.Equal(t, ttlv.EnumValue(kmip14.CryptographicAlgorithmAES), pairs[0].value.Value())
+	assert.Equal(t, "Name", pairs[2].name)
+	assert.Equal(t, ttlv.TypeStructure, pairs[2].value.Type())
+	assert.Equal(t, "x-custom", pairs[3].name)
+
+	// the Attributes structure itself
+	attrs := collect(v2.ValueStructure().Next())
+	assert.Equal(t, pairs, attrs)
+
+	// stopping early
+	var names []string
+
+	require.NoError(t, RangeAttributes(v2, func(name string, _ ttlv.TTLV) bool {
+		names = append(names, name)
+		return len(names) < 2
+	}))
+	assert.Equal(t, []string{"Cryptographic Algorithm", "Cryptographic Length"}, names)
+
+	// invalid messages
+	err = RangeAttributes(v1[:len(v1)-1], func(string, ttlv.TTLV) bool {
+		t.Fatal("fn should not be called")
+		return false
+	})
+	require.Error(t, err)
+}
+
+func TestAttributes_dates(t *testing.T) {
+	date := time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	var attrs Attributes
+
+	accessors := map[ttlv.Tag]func() (time.Time, bool){
+		kmip14.TagInitialDate:              attrs.InitialDate,
+		kmip14.TagActivationDate:           attrs.ActivationDate,
+		kmip14.TagProcessStartDate:         attrs.ProcessStartDate,
+		kmip14.TagProtectStopDate:          attrs.ProtectStopDate,
+		kmip14.TagDeactivationDate:         attrs.DeactivationDate,
+		kmip14.TagDestroyDate:              attrs.DestroyDate,
+		kmip14.TagCompromiseOccurrenceDate: attrs.CompromiseOccurrenceDate,
+		kmip14.TagCompromiseDate:           attrs.CompromiseDate,
+		kmip14.TagArchiveDate:              attrs.ArchiveDate,
+		kmip14.TagLastChangeDate:           attrs.LastChangeDate,
+		kmip14.TagOriginalCreationDate:     attrs.OriginalCreationDate,
+	}
+
+	for tag, fn := range accessors {
+		_, ok := fn()
+		assert.False(t, ok, tag.CanonicalName())
+	}
+
+	// each date is set to a different time, and read back from a decoded Get Attributes response
+	var sent []Attribute
+	for tag := range accessors {
+		sent = append(sent, NewAttributeFromTag(tag, 0, date.Add(time.Duration(tag)*time.Second)))
+	}
+
+	b, err := ttlv.Marshal(ttlv.Value{Tag: kmip14.TagResponsePayload, Value: &GetAttributesResponsePayload{
+		UniqueIdentifier: "1",
+		Attribute:        sent,
+	}})
+	require.NoError(t, err)
+
+	var resp GetAttributesResponsePayload
+	require.NoError(t, ttlv.Unmarshal(b, &resp))
+
+	attrs = *resp.Attributes()
+
+	for tag, fn := range accessors {
+		v, ok := fn()
+		require.True(t, ok, tag.CanonicalName())
+		assert.True(t, date.Add(time.Duration(tag)*time.Second).Equal(v), tag.CanonicalName())
+
+		v, ok = attrs.Time(tag.CanonicalName())
+		require.True(t, ok, tag.CanonicalName())
+		assert.True(t, date.Add(time.Duration(tag)*time.Second).Equal(v), tag.CanonicalName())
+	}
+
+	// DateTimeExtended values are accepted too, but other types aren't times
+	attrs = Attributes{}
+	attrs.Add(kmip14.TagActivationDate, ttlv.DateTimeExtended{Time: date})
+	attrs.Add(kmip14.TagDestroyDate, "tomorrow")
+
+	v, ok := attrs.ActivationDate()
+	require.True(t, ok)
+	assert.True(t, date.Equal(v))
+
+	_, ok = attrs.DestroyDate()
+	assert.False(t, ok)
+
+	_, err = TimeFromAttribute(attrs.GetTag(kmip14.TagDestroyDate))
+	require.Error(t, err)
+
+	_, ok = (*Attributes)(nil).Time("Activation Date")
+	assert.False(t, ok)
+}
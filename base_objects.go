@@ -3,6 +3,7 @@ package kmip
 import (
 	"math/big"
 
+	"github.com/ansel1/merry"
 	"github.com/gemalto/kmip-go/kmip14"
 	"github.com/gemalto/kmip-go/ttlv"
 )
@@ -439,14 +440,25 @@ func (t *TemplateAttribute) GetIdx(s string, idx int) *Attribute {
 }
 
 // GetTag returns a reference to the first Attribute in the list matching the tag.
+// The attribute name may be the tag's canonical name (as set by Append()) or its
+// normalized name.
 // Returns nil if not found.
 func (t *TemplateAttribute) GetTag(tag ttlv.Tag) *Attribute {
+	if a := t.Get(tag.CanonicalName()); a != nil {
+		return a
+	}
+
 	return t.Get(tag.String())
 }
 
 // GetTagIdx returns a reference to the first Attribute in the list matching the tag and index.
+// The attribute name may be the tag's canonical name or its normalized name.
 // Returns nil if not found.
 func (t *TemplateAttribute) GetTagIdx(tag ttlv.Tag, idx int) *Attribute {
+	if a := t.GetIdx(tag.CanonicalName(), idx); a != nil {
+		return a
+	}
+
 	return t.GetIdx(tag.String(), idx)
 }
 
@@ -471,5 +483,192 @@ func (t *TemplateAttribute) Append(tag ttlv.Tag, value interface{}) {
 }
 
 func (t *TemplateAttribute) GetAllTag(tag ttlv.Tag) []Attribute {
-	return t.GetAll(tag.String())
+	if t == nil {
+		return nil
+	}
+
+	canonical, normalized := tag.CanonicalName(), tag.String()
+
+	var ret []Attribute
+
+	for i := range t.Attribute {
+		if name := t.Attribute[i].AttributeName; name == canonical || name == normalized {
+			ret = append(ret, t.Attribute[i])
+		}
+	}
+
+	return ret
+}
+
+// ExtensionInformation 2.1.9
+//
+// An Extension Information object is a structure describing Objects with Item Tag values in the Extensions
+// range. The Extension Name is a Text String that is used to name the Object. The Extension Tag is the Item
+// Tag Value of the Object. The Extension Type is the Item Type Value of the Object.
+//
+// Servers return Extension Information in response to a Query with the Query Extension List or
+// Query Extension Map functions.  Only the Query Extension Map response includes the Extension Tag and
+// Extension Type.
+type ExtensionInformation struct {
+	ExtensionName string
+	ExtensionTag  ttlv.Tag  `ttlv:",omitempty"`
+	ExtensionType ttlv.Type `ttlv:",omitempty"`
+}
+
+// RegisterExtensions registers the name of each extension tag described in exts with the registry.
+// Once registered, the extension tags can be formatted and parsed by name, e.g. when encoding
+// or decoding JSON and XML, or when printing TTLV values.
+//
+// Entries without an Extension Tag (e.g. from a Query Extension List response) are skipped.
+func RegisterExtensions(registry *ttlv.Registry, exts []ExtensionInformation) {
+	for _, ext := range exts {
+		if ext.ExtensionTag == ttlv.TagNone || ext.ExtensionName == "" {
+			continue
+		}
+
+		registry.RegisterTag(ext.ExtensionTag, ext.ExtensionName)
+	}
+}
+
+// ProfileInformation 2.1.19 (KMIP 1.4), 2.1.20 (KMIP 2.0)
+//
+// Servers return Profile Information in response to a Query with the Query Profiles function,
+// one for each profile the server supports.  The Server URI and Server Port identify the endpoint
+// the profile is available on, if it differs from the endpoint which received the Query.
+// Profile Version was introduced in KMIP 2.0, so it's only set by 2.0 servers.
+type ProfileInformation struct {
+	ProfileName    kmip14.ProfileName
+	ServerURI      string `ttlv:",omitempty"`
+	ServerPort     int    `ttlv:",omitempty"`
+	ProfileVersion *ProfileVersion
+}
+
+// profileInformation has the fields of ProfileInformation, without its methods.  The KMIP 2.0
+// Profile Version tag isn't registered unless the kmip20 package is imported, so ProfileInformation
+// encodes and decodes that field itself.
+type profileInformation struct {
+	ProfileName kmip14.ProfileName
+	ServerURI   string `ttlv:",omitempty"`
+	ServerPort  int    `ttlv:",omitempty"`
+}
+
+func (p ProfileInformation) MarshalTTLV(e *ttlv.Encoder, tag ttlv.Tag) error {
+	return e.EncodeStructure(tag, func(e *ttlv.Encoder) error {
+		e.EncodeEnumeration(kmip14.TagProfileName, uint32(p.ProfileName))
+
+		if p.ServerURI != "" {
+			e.EncodeTextString(kmip14.TagServerURI, p.ServerURI)
+		}
+
+		if p.ServerPort != 0 {
+			e.EncodeInteger(kmip14.TagServerPort, int32(p.ServerPort))
+		}
+
+		if p.ProfileVersion != nil {
+			return e.EncodeValue(tagProfileVersion, p.ProfileVersion)
+		}
+
+		return nil
+	})
+}
+
+func (p *ProfileInformation) UnmarshalTTLV(d *ttlv.Decoder, v ttlv.TTLV) error {
+	var fields profileInformation
+	if err := d.DecodeValue(&fields, v); err != nil {
+		return err
+	}
+
+	*p = ProfileInformation{
+		ProfileName: fields.ProfileName,
+		ServerURI:   fields.ServerURI,
+		ServerPort:  fields.ServerPort,
+	}
+
+	if n := v.Get(tagProfileVersion); n != nil {
+		p.ProfileVersion = &ProfileVersion{}
+		return d.DecodeValue(p.ProfileVersion, n)
+	}
+
+	return nil
+}
+
+// ProfileVersion 2.1.21 (KMIP 2.0)
+//
+// Like ProfileInformation, ProfileVersion encodes and decodes itself, since its tags are only
+// registered when the kmip20 package is imported.
+type ProfileVersion struct {
+	ProfileVersionMajor int
+	ProfileVersionMinor int
+}
+
+func (p ProfileVersion) MarshalTTLV(e *ttlv.Encoder, tag ttlv.Tag) error {
+	return e.EncodeStructure(tag, func(e *ttlv.Encoder) error {
+		e.EncodeInteger(tagProfileVersionMajor, int32(p.ProfileVersionMajor))
+		e.EncodeInteger(tagProfileVersionMinor, int32(p.ProfileVersionMinor))
+
+		return nil
+	})
+}
+
+func (p *ProfileVersion) UnmarshalTTLV(d *ttlv.Decoder, v ttlv.TTLV) error {
+	if v.Type() != ttlv.TypeStructure {
+		return merry.Errorf("invalid type for Profile Version: %s", v.Type().String())
+	}
+
+	*p = ProfileVersion{}
+
+	for n := v.ValueStructure(); len(n) > 0; n = n.Next() {
+		var err error
+
+		switch n.Tag() {
+		case tagProfileVersionMajor:
+			err = d.DecodeValue(&p.ProfileVersionMajor, n[:n.FullLen()])
+		case tagProfileVersionMinor:
+			err = d.DecodeValue(&p.ProfileVersionMinor, n[:n.FullLen()])
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ValidationInformation 2.1.20 (KMIP 1.4), 2.1.22 (KMIP 2.0)
+//
+// Servers return Validation Information in response to a Query with the Query Validations function,
+// one for each validation the server has passed, e.g. a FIPS 140 validation by NIST CMVP, or a
+// Common Criteria evaluation.  The Validation Authority Type, Validation Version Major, Validation Type,
+// and Validation Level are required.  A server may list several Validation Profiles.
+type ValidationInformation struct {
+	ValidationAuthorityType         kmip14.ValidationAuthorityType
+	ValidationAuthorityCountry      string `ttlv:",omitempty"`
+	ValidationAuthorityURI          string `ttlv:",omitempty"`
+	ValidationVersionMajor          int
+	ValidationVersionMinor          int `ttlv:",omitempty"`
+	ValidationType                  kmip14.ValidationType
+	ValidationLevel                 int
+	ValidationCertificateIdentifier string `ttlv:",omitempty"`
+	ValidationCertificateURI        string `ttlv:",omitempty"`
+	ValidationVendorURI             string `ttlv:",omitempty"`
+	ValidationProfile               []string
+}
+
+// CapabilityInformation 2.1.22 (KMIP 1.4), 2.1.23 (KMIP 2.0)
+//
+// Servers return Capability Information in response to a Query with the Query Capabilities function.
+// All the fields are optional: a capability the server didn't advertise is decoded as false, or
+// as the zero value of its enumeration, so callers can test the fields directly, e.g. to decide
+// whether to use streaming operations.
+type CapabilityInformation struct {
+	StreamingCapability     bool                      `ttlv:",omitempty"`
+	AsynchronousCapability  bool                      `ttlv:",omitempty"`
+	AttestationCapability   bool                      `ttlv:",omitempty"`
+	BatchUndoCapability     bool                      `ttlv:",omitempty"`
+	BatchContinueCapability bool                      `ttlv:",omitempty"`
+	UnwrapMode              kmip14.UnwrapMode         `ttlv:",omitempty"`
+	DestroyAction           kmip14.DestroyAction      `ttlv:",omitempty"`
+	ShreddingAlgorithm      kmip14.ShreddingAlgorithm `ttlv:",omitempty"`
+	RNGMode                 kmip14.RNGMode            `ttlv:",omitempty"`
 }
@@ -370,3 +370,82 @@ func v(tag ttlv.Tag, val interface{}) ttlv.Value {
 func s(tag ttlv.Tag, vals ...ttlv.Value) ttlv.Value {
 	return ttlv.NewStruct(tag, vals...)
 }
+
+func TestKeyWrappingData(t *testing.T) {
+	wrapped := KeyBlock{
+		KeyFormatType:          kmip14.KeyFormatTypeRaw,
+		KeyValue:               []byte{0xde, 0xad, 0xbe, 0xef},
+		CryptographicAlgorithm: kmip14.CryptographicAlgorithmAES,
+		CryptographicLength:    256,
+		KeyWrappingData: &KeyWrappingData{
+			WrappingMethod: kmip14.WrappingMethodEncrypt,
+			EncryptionKeyInformation: &EncryptionKeyInformation{
+				UniqueIdentifier: "kek",
+				CryptographicParameters: &CryptographicParameters{
+					BlockCipherMode: kmip14.BlockCipherModeNISTKeyWrap,
+				},
+			},
+			IVCounterNonce: []byte{1, 2, 3},
+			EncodingOption: kmip14.EncodingOptionNoEncoding,
+		},
+	}
+
+	// importing a wrapped key
+	b, err := ttlv.Marshal(ttlv.Value{Tag: kmip14.TagRequestPayload, Value: RegisterRequestPayload{
+		ObjectType:   kmip14.ObjectTypeSymmetricKey,
+		SymmetricKey: &SymmetricKey{KeyBlock: wrapped},
+	}})
+	require.NoError(t, err)
+
+	var find func(t ttlv.TTLV) ttlv.TTLV
+
+	find = func(t ttlv.TTLV) ttlv.TTLV {
+		for n := t; len(n) > 0; n = n.Next() {
+			if n.Tag() == kmip14.TagKeyWrappingData {
+				return n[:n.FullLen()]
+			}
+
+			if n.Type() == ttlv.TypeStructure {
+				if f := find(n.ValueStructure()); f != nil {
+					return f
+				}
+			}
+		}
+
+		return nil
+	}
+
+	kwd := find(b)
+
+	exp, err := ttlv.Marshal(s(kmip14.TagKeyWrappingData,
+		v(kmip14.TagWrappingMethod, kmip14.WrappingMethodEncrypt),
+		s(kmip14.TagEncryptionKeyInformation,
+			v(kmip14.TagUniqueIdentifier, "kek"),
+			s(kmip14.TagCryptographicParameters,
+				v(kmip14.TagBlockCipherMode, kmip14.BlockCipherModeNISTKeyWrap),
+			),
+		),
+		v(kmip14.TagIVCounterNonce, []byte{1, 2, 3}),
+		v(kmip14.TagEncodingOption, kmip14.EncodingOptionNoEncoding),
+	))
+	require.NoError(t, err)
+	assert.Equal(t, exp, kwd)
+
+	var reg RegisterRequestPayload
+	require.NoError(t, ttlv.Unmarshal(b, &reg))
+	require.NotNil(t, reg.SymmetricKey)
+	assert.Equal(t, wrapped, reg.SymmetricKey.KeyBlock)
+
+	// exporting a wrapped key
+	b, err = ttlv.Marshal(ttlv.Value{Tag: kmip14.TagResponsePayload, Value: GetResponsePayload{
+		ObjectType:       kmip14.ObjectTypeSymmetricKey,
+		UniqueIdentifier: "1",
+		SymmetricKey:     &SymmetricKey{KeyBlock: wrapped},
+	}})
+	require.NoError(t, err)
+
+	var get GetResponsePayload
+	require.NoError(t, ttlv.Unmarshal(b, &get))
+	require.NotNil(t, get.SymmetricKey)
+	assert.Equal(t, wrapped, get.SymmetricKey.KeyBlock)
+}
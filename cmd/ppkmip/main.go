@@ -102,10 +102,12 @@ xml format:
 	var inFormat string
 	var outFormat string
 	var inFile string
+	var query string
 
 	flag.StringVar(&inFormat, "i", "", "input format: hex|json|xml, defaults to auto detect")
 	flag.StringVar(&outFormat, "o", "", "output format: text|hex|prettyhex|json|xml, defaults to text")
 	flag.StringVar(&inFile, "f", "", "input file name, defaults to stdin")
+	flag.StringVar(&query, "q", "", "only print the values matching a tag path, e.g. BatchItem//UniqueIdentifier (see ttlv.Query)")
 
 	flag.Parse()
 
@@ -168,8 +170,7 @@ xml format:
 				fail("error parsing JSON", err)
 			}
 
-			printTTLV(outFormat, raw, count)
-			count++
+			count = printMatches(outFormat, query, raw, count)
 		}
 
 	case FormatXML:
@@ -187,15 +188,13 @@ xml format:
 				fail("error parsing XML", err)
 			}
 
-			printTTLV(outFormat, raw, count)
-			count++
+			count = printMatches(outFormat, query, raw, count)
 		}
 	case FormatHex:
 		raw := ttlv.TTLV(ttlv.Hex2bytes(buf.String()))
 
 		for len(raw) > 0 {
-			printTTLV(outFormat, raw, count)
-			count++
+			count = printMatches(outFormat, query, raw, count)
 			raw = raw.Next()
 		}
 	default:
@@ -203,6 +202,28 @@ xml format:
 	}
 }
 
+// printMatches prints raw, or if query is set, the values in raw which match it.  It returns
+// count plus the number of values printed.
+func printMatches(outFormat, query string, raw ttlv.TTLV, count int) int {
+	if query == "" {
+		printTTLV(outFormat, raw, count)
+
+		return count + 1
+	}
+
+	matches, err := ttlv.Query(raw, query)
+	if err != nil {
+		fail("error querying", err)
+	}
+
+	for _, m := range matches {
+		printTTLV(outFormat, m, count)
+		count++
+	}
+
+	return count
+}
+
 func printTTLV(outFormat string, raw ttlv.TTLV, count int) {
 	if count > 0 {
 		fmt.Println("")
@@ -0,0 +1,29 @@
+package kmip
+
+import (
+	"github.com/gemalto/kmip-go/kmip14"
+	"github.com/gemalto/kmip-go/ttlv"
+)
+
+// Defaults returns a map from the tags of optional values to the values the spec says to
+// assume when they are absent.  It can be used as a ttlv.Decoder's Defaults, so decoded
+// structs hold the effective values, and code reading them doesn't need to treat absent
+// values specially:
+//
+//	dec := ttlv.NewDecoder(r)
+//	dec.Defaults = kmip.Defaults()
+//
+// Each call returns a new map, so callers may add or override defaults of their own.
+//
+// Note that fields with the "omitempty" flag can't tell a default which was applied apart
+// from a value which was sent, so re-encoding a decoded struct may not reproduce the
+// original encoding.
+func Defaults() map[ttlv.Tag]interface{} {
+	return map[ttlv.Tag]interface{}{
+		kmip14.TagBatchErrorContinuationOption:  kmip14.BatchErrorContinuationOptionStop,
+		kmip14.TagBatchOrderOption:              true,
+		kmip14.TagEncodingOption:                kmip14.EncodingOptionTTLVEncoding,
+		kmip14.TagMaskGenerator:                 kmip14.MaskGeneratorMGF1,
+		kmip14.TagMaskGeneratorHashingAlgorithm: kmip14.HashingAlgorithmSHA_1,
+	}
+}
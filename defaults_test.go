@@ -0,0 +1,39 @@
+package kmip
+
+import (
+	"testing"
+
+	"github.com/gemalto/kmip-go/kmip14"
+	"github.com/gemalto/kmip-go/ttlv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaults(t *testing.T) {
+	b, err := ttlv.Marshal(ttlv.Value{Tag: kmip14.TagRequestHeader, Value: &RequestHeader{
+		ProtocolVersion: ProtocolVersion{ProtocolVersionMajor: 1, ProtocolVersionMinor: 4},
+		BatchCount:      1,
+	}})
+	require.NoError(t, err)
+
+	dec := ttlv.NewDecoder(nil)
+	dec.Defaults = Defaults()
+
+	var h RequestHeader
+	require.NoError(t, dec.DecodeValue(&h, b))
+	assert.Equal(t, kmip14.BatchErrorContinuationOptionStop, h.BatchErrorContinuationOption)
+	assert.True(t, h.BatchOrderOption)
+
+	b, err = ttlv.Marshal(ttlv.Value{Tag: kmip14.TagKeyWrappingData, Value: &KeyWrappingData{
+		WrappingMethod: kmip14.WrappingMethodEncrypt,
+	}})
+	require.NoError(t, err)
+
+	var kwd KeyWrappingData
+	require.NoError(t, dec.DecodeValue(&kwd, b))
+	assert.Equal(t, kmip14.EncodingOptionTTLVEncoding, kwd.EncodingOption)
+
+	// each call returns a new map
+	dec.Defaults[kmip14.TagBatchOrderOption] = false
+	assert.Equal(t, true, Defaults()[kmip14.TagBatchOrderOption])
+}
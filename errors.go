@@ -12,7 +12,14 @@ func Details(err error) string {
 	return merry.Details(err)
 }
 
-var ErrInvalidTag = errors.New("invalid tag")
+var (
+	ErrInvalidTag         = errors.New("invalid tag")
+	ErrBatchCountMismatch = errors.New("batch count does not match the number of batch items")
+	ErrInvalidName        = errors.New("invalid Name attribute")
+	ErrUnknownAttribute   = errors.New("unknown attribute")
+	ErrTooManyBatchItems  = errors.New("too many batch items")
+	ErrProfileViolation   = errors.New("profile violation")
+)
 
 type errKey int
 
@@ -40,3 +47,35 @@ func GetResultReason(err error) kmip14.ResultReason {
 		panic(fmt.Sprintf("err result reason attribute's value was wrong type, expected ResultReason, got %T", v))
 	}
 }
+
+// ItemError describes a response batch item which did not succeed.  See ResponseBatchItem.Err().
+type ItemError struct {
+	Operation         kmip14.Operation
+	UniqueBatchItemID []byte
+	ResultStatus      kmip14.ResultStatus
+	ResultReason      kmip14.ResultReason
+	ResultMessage     string
+}
+
+func (e *ItemError) Error() string {
+	msg := fmt.Sprintf("kmip: %s: %s", e.Operation.String(), e.ResultStatus.String())
+	if e.ResultStatus == kmip14.ResultStatusOperationUndone {
+		msg += " (rolled back after another batch item failed)"
+	}
+
+	if e.ResultReason != 0 {
+		msg += ": " + e.ResultReason.String()
+	}
+
+	if e.ResultMessage != "" {
+		msg += ": " + e.ResultMessage
+	}
+
+	return msg
+}
+
+// Undone returns true if the operation was undone because another item in the batch failed,
+// and the batch was sent with the Undo Batch Error Continuation Option.
+func (e *ItemError) Undone() bool {
+	return e.ResultStatus == kmip14.ResultStatusOperationUndone
+}
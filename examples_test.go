@@ -90,3 +90,42 @@ func ExampleServer() {
 	srv := kmip.Server{}
 	panic(srv.Serve(listener))
 }
+
+func Example_deferredPayload() {
+	b, err := ttlv.Marshal(ttlv.Value{Tag: kmip14.TagBatchItem, Value: kmip.ResponseBatchItem{
+		Operation:    kmip14.OperationDestroy,
+		ResultStatus: kmip14.ResultStatusSuccess,
+		ResponsePayload: kmip.DestroyResponsePayload{
+			UniqueIdentifier: "1",
+		},
+	}})
+	if err != nil {
+		panic(err)
+	}
+
+	// first decode the operation, leaving the payload encoded
+	var item struct {
+		Operation       kmip14.Operation
+		ResponsePayload ttlv.RawTTLV
+	}
+
+	if err := ttlv.Unmarshal(b, &item); err != nil {
+		panic(err)
+	}
+
+	// then decode the payload into the type for the operation
+	switch item.Operation {
+	case kmip14.OperationDestroy:
+		var payload kmip.DestroyResponsePayload
+		if err := item.ResponsePayload.Decode(&payload); err != nil {
+			panic(err)
+		}
+
+		fmt.Println(item.ResponsePayload.Tag, payload.UniqueIdentifier)
+	default:
+		fmt.Println("unexpected operation", item.Operation)
+	}
+
+	// Output:
+	// ResponsePayload 1
+}
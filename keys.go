@@ -0,0 +1,168 @@
+package kmip
+
+import (
+	"crypto/rsa"
+	"math/big"
+	"reflect"
+
+	"github.com/ansel1/merry"
+	"github.com/gemalto/kmip-go/kmip14"
+	"github.com/gemalto/kmip-go/ttlv"
+)
+
+// transparentKeyTypes maps the transparent Key Format Types to the structs which hold their key material.
+var transparentKeyTypes = map[kmip14.KeyFormatType]reflect.Type{
+	kmip14.KeyFormatTypeTransparentSymmetricKey:    reflect.TypeOf(TransparentSymmetricKey{}),
+	kmip14.KeyFormatTypeTransparentDSAPrivateKey:   reflect.TypeOf(TransparentDSAPrivateKey{}),
+	kmip14.KeyFormatTypeTransparentDSAPublicKey:    reflect.TypeOf(TransparentDSAPublicKey{}),
+	kmip14.KeyFormatTypeTransparentRSAPrivateKey:   reflect.TypeOf(TransparentRSAPrivateKey{}),
+	kmip14.KeyFormatTypeTransparentRSAPublicKey:    reflect.TypeOf(TransparentRSAPublicKey{}),
+	kmip14.KeyFormatTypeTransparentDHPrivateKey:    reflect.TypeOf(TransparentDHPrivateKey{}),
+	kmip14.KeyFormatTypeTransparentDHPublicKey:     reflect.TypeOf(TransparentDHPublicKey{}),
+	kmip14.KeyFormatTypeTransparentECDSAPrivateKey: reflect.TypeOf(TransparentECDSAPrivateKey{}),
+	kmip14.KeyFormatTypeTransparentECDSAPublicKey:  reflect.TypeOf(TransparentECDSAPublicKey{}),
+}
+
+// NewTransparentKeyBlock returns a KeyBlock with a Key Value structure holding the key material, which
+// must be a pointer to one of the Transparent*Key structs.  The Key Format Type is set to match the
+// key material.  The caller should set the Cryptographic Algorithm and Cryptographic Length.
+func NewTransparentKeyBlock(keyMaterial interface{}) (KeyBlock, error) {
+	t := reflect.TypeOf(keyMaterial)
+	if t != nil && t.Kind() == reflect.Ptr {
+		for format, typ := range transparentKeyTypes {
+			if t.Elem() == typ {
+				return KeyBlock{
+					KeyFormatType: format,
+					KeyValue:      &KeyValue{KeyMaterial: keyMaterial},
+				}, nil
+			}
+		}
+	}
+
+	return KeyBlock{}, merry.Errorf("unsupported transparent key material type: %T", keyMaterial)
+}
+
+// DecodeKeyMaterial decodes the Key Material in the Key Value into v, e.g. a pointer to one of the
+// Transparent*Key structs, or to a []byte.  The Key Value may be a KeyValue, *KeyValue, or the undecoded
+// ttlv.TTLV, which is how a Key Value structure is unmarshaled into a KeyBlock.  A wrapped Key Value, which
+// is a byte string, can't be decoded.
+func (kb *KeyBlock) DecodeKeyMaterial(v interface{}) error {
+	var keyMaterial interface{}
+
+	switch kv := kb.KeyValue.(type) {
+	case KeyValue:
+		keyMaterial = kv.KeyMaterial
+	case *KeyValue:
+		keyMaterial = kv.KeyMaterial
+	case ttlv.TTLV:
+		var decoded KeyValue
+		if err := ttlv.Unmarshal(kv, &decoded); err != nil {
+			return err
+		}
+
+		keyMaterial = decoded.KeyMaterial
+	case nil:
+		return merry.New("key block has no key value")
+	default:
+		return merry.Errorf("key value can't be decoded, it is wrapped or has an unexpected type: %T", kb.KeyValue)
+	}
+
+	// the key material may be an undecoded TTLV value, or already a go value, so normalize it through
+	// the encoding
+	b, err := ttlv.Marshal(ttlv.Value{Tag: kmip14.TagKeyMaterial, Value: keyMaterial})
+	if err != nil {
+		return err
+	}
+
+	return ttlv.Unmarshal(b, v)
+}
+
+// TransparentKey decodes the Key Material into the Transparent*Key struct matching the Key Format Type, and
+// returns a pointer to it, e.g. *TransparentRSAPrivateKey for Transparent RSA Private Key.  An error is returned
+// if the Key Format Type isn't one of the transparent formats.
+func (kb *KeyBlock) TransparentKey() (interface{}, error) {
+	typ, ok := transparentKeyTypes[kb.KeyFormatType]
+	if !ok {
+		return nil, merry.Errorf("key format type %v is not a supported transparent format", kb.KeyFormatType)
+	}
+
+	v := reflect.New(typ).Interface()
+	if err := kb.DecodeKeyMaterial(v); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// NewTransparentRSAPrivateKey returns the components of an RSA private key.  Only keys with two
+// prime factors can be represented.
+func NewTransparentRSAPrivateKey(k *rsa.PrivateKey) (*TransparentRSAPrivateKey, error) {
+	if len(k.Primes) != 2 {
+		return nil, merry.Errorf("rsa keys with %d primes can't be represented as Transparent RSA Private Keys", len(k.Primes))
+	}
+
+	p, q := k.Primes[0], k.Primes[1]
+	one := big.NewInt(1)
+
+	return &TransparentRSAPrivateKey{
+		Modulus:         new(big.Int).Set(k.N),
+		PrivateExponent: new(big.Int).Set(k.D),
+		PublicExponent:  big.NewInt(int64(k.E)),
+		P:               new(big.Int).Set(p),
+		Q:               new(big.Int).Set(q),
+		PrimeExponentP:  new(big.Int).Mod(k.D, new(big.Int).Sub(p, one)),
+		PrimeExponentQ:  new(big.Int).Mod(k.D, new(big.Int).Sub(q, one)),
+		CRTCoefficient:  new(big.Int).ModInverse(q, p),
+	}, nil
+}
+
+// RSAPrivateKey returns the key as an *rsa.PrivateKey.  Modulus, Public Exponent, Private Exponent,
+// P and Q must be present.  The other components are recomputed, and the key is validated.
+func (k *TransparentRSAPrivateKey) RSAPrivateKey() (*rsa.PrivateKey, error) {
+	if k.Modulus == nil || k.PublicExponent == nil || k.PrivateExponent == nil || k.P == nil || k.Q == nil {
+		return nil, merry.New("transparent rsa private key requires modulus, public exponent, private exponent, p, and q")
+	}
+
+	pub, err := (&TransparentRSAPublicKey{Modulus: k.Modulus, PublicExponent: k.PublicExponent}).RSAPublicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	key := &rsa.PrivateKey{
+		PublicKey: *pub,
+		D:         new(big.Int).Set(k.PrivateExponent),
+		Primes:    []*big.Int{new(big.Int).Set(k.P), new(big.Int).Set(k.Q)},
+	}
+
+	if err := key.Validate(); err != nil {
+		return nil, merry.Prepend(err, "invalid rsa private key")
+	}
+
+	key.Precompute()
+
+	return key, nil
+}
+
+// NewTransparentRSAPublicKey returns the components of an RSA public key.
+func NewTransparentRSAPublicKey(k *rsa.PublicKey) *TransparentRSAPublicKey {
+	return &TransparentRSAPublicKey{
+		Modulus:        new(big.Int).Set(k.N),
+		PublicExponent: big.NewInt(int64(k.E)),
+	}
+}
+
+// RSAPublicKey returns the key as an *rsa.PublicKey.
+func (k *TransparentRSAPublicKey) RSAPublicKey() (*rsa.PublicKey, error) {
+	if k.Modulus == nil || k.PublicExponent == nil {
+		return nil, merry.New("transparent rsa public key requires modulus and public exponent")
+	}
+
+	if !k.PublicExponent.IsInt64() || k.PublicExponent.Int64() < 2 || k.PublicExponent.Int64() > 1<<31-1 {
+		return nil, merry.Errorf("invalid rsa public exponent: %v", k.PublicExponent)
+	}
+
+	return &rsa.PublicKey{
+		N: new(big.Int).Set(k.Modulus),
+		E: int(k.PublicExponent.Int64()),
+	}, nil
+}
@@ -0,0 +1,81 @@
+package kmip
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+
+	"github.com/gemalto/kmip-go/kmip14"
+	"github.com/gemalto/kmip-go/ttlv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransparentKeys(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+
+	priv, err := NewTransparentRSAPrivateKey(rsaKey)
+	require.NoError(t, err)
+
+	// CRT components are computed from the key
+	rsaKey.Precompute()
+	assert.Equal(t, rsaKey.Precomputed.Dp, priv.PrimeExponentP)
+	assert.Equal(t, rsaKey.Precomputed.Dq, priv.PrimeExponentQ)
+	assert.Equal(t, rsaKey.Precomputed.Qinv, priv.CRTCoefficient)
+
+	privBlock, err := NewTransparentKeyBlock(priv)
+	require.NoError(t, err)
+	assert.Equal(t, kmip14.KeyFormatTypeTransparentRSAPrivateKey, privBlock.KeyFormatType)
+
+	symBlock, err := NewTransparentKeyBlock(&TransparentSymmetricKey{Key: []byte{1, 2, 3, 4}})
+	require.NoError(t, err)
+	assert.Equal(t, kmip14.KeyFormatTypeTransparentSymmetricKey, symBlock.KeyFormatType)
+
+	_, err = NewTransparentKeyBlock([]byte{1, 2, 3, 4})
+	require.Error(t, err)
+
+	b, err := ttlv.Marshal(ttlv.Value{Tag: kmip14.TagRequestPayload, Value: &RegisterRequestPayload{
+		ObjectType: kmip14.ObjectTypePrivateKey,
+		PrivateKey: &PrivateKey{KeyBlock: privBlock},
+	}})
+	require.NoError(t, err)
+
+	var reg RegisterRequestPayload
+	require.NoError(t, ttlv.Unmarshal(b, &reg))
+
+	decoded, err := reg.PrivateKey.KeyBlock.TransparentKey()
+	require.NoError(t, err)
+	require.IsType(t, &TransparentRSAPrivateKey{}, decoded)
+	assert.Equal(t, priv, decoded)
+
+	decodedKey, err := decoded.(*TransparentRSAPrivateKey).RSAPrivateKey()
+	require.NoError(t, err)
+	assert.True(t, rsaKey.Equal(decodedKey))
+
+	b, err = ttlv.Marshal(ttlv.Value{Tag: kmip14.TagRequestPayload, Value: &RegisterRequestPayload{
+		ObjectType:   kmip14.ObjectTypeSymmetricKey,
+		SymmetricKey: &SymmetricKey{KeyBlock: symBlock},
+	}})
+	require.NoError(t, err)
+	require.NoError(t, ttlv.Unmarshal(b, &reg))
+
+	var sym TransparentSymmetricKey
+	require.NoError(t, reg.SymmetricKey.KeyBlock.DecodeKeyMaterial(&sym))
+	assert.Equal(t, []byte{1, 2, 3, 4}, sym.Key)
+
+	pub, err := NewTransparentRSAPublicKey(&rsaKey.PublicKey).RSAPublicKey()
+	require.NoError(t, err)
+	assert.True(t, rsaKey.PublicKey.Equal(pub))
+
+	// non-transparent and wrapped keys can't be decoded as transparent keys
+	_, err = (&KeyBlock{KeyFormatType: kmip14.KeyFormatTypeRaw, KeyValue: []byte{1}}).TransparentKey()
+	require.Error(t, err)
+
+	_, err = (&KeyBlock{KeyFormatType: kmip14.KeyFormatTypeTransparentSymmetricKey, KeyValue: []byte{1}}).TransparentKey()
+	require.Error(t, err)
+
+	// incomplete keys can't be converted
+	_, err = (&TransparentRSAPrivateKey{Modulus: priv.Modulus, PrivateExponent: priv.PrivateExponent}).RSAPrivateKey()
+	require.Error(t, err)
+}
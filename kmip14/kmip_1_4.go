@@ -17,7 +17,12 @@ func init() {
 	Register(&ttlv.DefaultRegistry)
 }
 
-// Register registers the 1.4 enumeration values with the registry.
+// Register registers the 1.4 enumeration values, the values required in common
+// structures, the order of their values, and the attributes of each object type,
+// with the registry.
 func Register(registry *ttlv.Registry) {
 	RegisterGeneratedDefinitions(registry)
+	RegisterStructures(registry)
+	RegisterOrders(registry)
+	RegisterObjectAttributes(registry)
 }
@@ -3326,7 +3326,7 @@ func RegisterGeneratedDefinitions(r *ttlv.Registry) {
 		TagCertificateType:                       "Certificate Type",
 		TagCertificateValue:                      "Certificate Value",
 		TagCommonTemplateAttribute:               "Common Template-Attribute",
-		TagCompromiseDate:                        "Compromise  Date",
+		TagCompromiseDate:                        "Compromise Date",
 		TagCompromiseOccurrenceDate:              "Compromise Occurrence Date",
 		TagContactInformation:                    "Contact Information",
 		TagCredential:                            "Credential",
@@ -0,0 +1,102 @@
+package kmip14
+
+import (
+	"github.com/gemalto/kmip-go/ttlv"
+)
+
+// RegisterObjectAttributes registers the attributes which apply to each of the 1.4 object
+// types with the registry, for use by Registry.AttributeApplies().  The lists follow the
+// "Applies to Object Types" rows of the attribute definitions in section 3 of the spec.
+// Templates may hold the attributes of any type of object, so none are registered for
+// Template, and any attribute is accepted.
+func RegisterObjectAttributes(registry *ttlv.Registry) {
+	// attributes which apply to every type of object
+	common := []ttlv.Tag{
+		TagUniqueIdentifier,
+		TagName,
+		TagObjectType,
+		TagOperationPolicyName,
+		TagDigest,
+		TagState,
+		TagInitialDate,
+		TagActivationDate,
+		TagDeactivationDate,
+		TagDestroyDate,
+		TagCompromiseOccurrenceDate,
+		TagCompromiseDate,
+		TagRevocationReason,
+		TagArchiveDate,
+		TagObjectGroup,
+		TagFresh,
+		TagLink,
+		TagApplicationSpecificInformation,
+		TagContactInformation,
+		TagLastChangeDate,
+		TagCustomAttribute,
+		TagAlternativeName,
+		TagKeyValuePresent,
+		TagKeyValueLocation,
+		TagOriginalCreationDate,
+		TagDescription,
+		TagComment,
+		TagSensitive,
+		TagAlwaysSensitive,
+		TagExtractable,
+		TagNeverExtractable,
+	}
+
+	// attributes which apply to cryptographic objects: keys and certificates
+	cryptographic := []ttlv.Tag{
+		TagCryptographicAlgorithm,
+		TagCryptographicLength,
+		TagCryptographicParameters,
+		TagCryptographicUsageMask,
+		TagLeaseTime,
+		TagUsageLimits,
+	}
+
+	keys := []ttlv.Tag{
+		TagRandomNumberGenerator,
+		TagPKCS_12FriendlyName,
+	}
+
+	// Cryptographic Domain Parameters only apply to asymmetric keys
+	asymmetricKeys := []ttlv.Tag{
+		TagCryptographicDomainParameters,
+	}
+
+	// the dates which limit when a symmetric key may be used to protect and process data
+	processDates := []ttlv.Tag{
+		TagProcessStartDate,
+		TagProtectStopDate,
+	}
+
+	certificates := []ttlv.Tag{
+		TagCertificateType,
+		TagCertificateLength,
+		TagCertificateIdentifier,
+		TagCertificateSubject,
+		TagCertificateIssuer,
+		TagX_509CertificateIdentifier,
+		TagX_509CertificateSubject,
+		TagX_509CertificateIssuer,
+		TagDigitalSignatureAlgorithm,
+	}
+
+	register := func(objectType ObjectType, lists ...[]ttlv.Tag) {
+		registry.RegisterObjectAttributes(uint32(objectType), common...)
+
+		for _, l := range lists {
+			registry.RegisterObjectAttributes(uint32(objectType), l...)
+		}
+	}
+
+	register(ObjectTypeCertificate, cryptographic, certificates)
+	register(ObjectTypePGPKey, cryptographic, certificates, keys, asymmetricKeys)
+	register(ObjectTypeSymmetricKey, cryptographic, keys, processDates)
+	register(ObjectTypeSplitKey, cryptographic, keys, processDates)
+	register(ObjectTypePublicKey, cryptographic, keys, asymmetricKeys)
+	register(ObjectTypePrivateKey, cryptographic, keys, asymmetricKeys)
+	register(ObjectTypeSecretData, []ttlv.Tag{TagCryptographicParameters, TagCryptographicUsageMask, TagLeaseTime, TagUsageLimits})
+	register(ObjectTypeOpaqueObject)
+}
@@ -0,0 +1,98 @@
+package kmip14
+
+import (
+	"github.com/gemalto/kmip-go/ttlv"
+)
+
+// RegisterOrders registers the order the 1.4 spec defines for the values in the message
+// structures and the most common base objects, for use by Registry.ValidateOrder() and
+// Registry.Reorder().  The Batch Item order covers both request and response batch items,
+// since their values don't overlap.  The structures with an order are:
+//
+//   - Request Message, Response Message, Request Header, Response Header, Batch Item,
+//     Protocol Version, Authentication, Credential, and Message Extension
+//   - Key Block, Key Value, and Key Wrapping Data
+//   - Attribute, Name, and Link
+//   - Validation Information
+func RegisterOrders(registry *ttlv.Registry) {
+	// messages
+	registry.RegisterOrder(TagRequestMessage, TagRequestHeader, TagBatchItem)
+	registry.RegisterOrder(TagResponseMessage, TagResponseHeader, TagBatchItem)
+	registry.RegisterOrder(TagRequestHeader,
+		TagProtocolVersion,
+		TagMaximumResponseSize,
+		TagClientCorrelationValue,
+		TagServerCorrelationValue,
+		TagAsynchronousIndicator,
+		TagAttestationCapableIndicator,
+		TagAttestationType,
+		TagAuthentication,
+		TagBatchErrorContinuationOption,
+		TagBatchOrderOption,
+		TagTimeStamp,
+		TagBatchCount,
+	)
+	registry.RegisterOrder(TagResponseHeader,
+		TagProtocolVersion,
+		TagTimeStamp,
+		TagNonce,
+		TagAttestationType,
+		TagClientCorrelationValue,
+		TagServerCorrelationValue,
+		TagBatchCount,
+	)
+	registry.RegisterOrder(TagBatchItem,
+		TagOperation,
+		TagUniqueBatchItemID,
+		TagResultStatus,
+		TagResultReason,
+		TagResultMessage,
+		TagAsynchronousCorrelationValue,
+		TagRequestPayload,
+		TagResponsePayload,
+		TagMessageExtension,
+	)
+	registry.RegisterOrder(TagProtocolVersion, TagProtocolVersionMajor, TagProtocolVersionMinor)
+	registry.RegisterOrder(TagAuthentication, TagCredential)
+	registry.RegisterOrder(TagCredential, TagCredentialType, TagCredentialValue)
+	registry.RegisterOrder(TagMessageExtension, TagVendorIdentification, TagCriticalityIndicator, TagVendorExtension)
+
+	// key blocks
+	registry.RegisterOrder(TagKeyBlock,
+		TagKeyFormatType,
+		TagKeyCompressionType,
+		TagKeyValue,
+		TagCryptographicAlgorithm,
+		TagCryptographicLength,
+		TagKeyWrappingData,
+	)
+	registry.RegisterOrder(TagKeyValue, TagKeyMaterial, TagAttribute)
+	registry.RegisterOrder(TagKeyWrappingData,
+		TagWrappingMethod,
+		TagEncryptionKeyInformation,
+		TagMACSignatureKeyInformation,
+		TagMACSignature,
+		TagIVCounterNonce,
+		TagEncodingOption,
+	)
+
+	// attributes
+	registry.RegisterOrder(TagAttribute, TagAttributeName, TagAttributeIndex, TagAttributeValue)
+	registry.RegisterOrder(TagName, TagNameValue, TagNameType)
+	registry.RegisterOrder(TagLink, TagLinkType, TagLinkedObjectIdentifier)
+
+	// server information
+	registry.RegisterOrder(TagValidationInformation,
+		TagValidationAuthorityType,
+		TagValidationAuthorityCountry,
+		TagValidationAuthorityURI,
+		TagValidationVersionMajor,
+		TagValidationVersionMinor,
+		TagValidationType,
+		TagValidationLevel,
+		TagValidationCertificateIdentifier,
+		TagValidationCertificateURI,
+		TagValidationVendorURI,
+		TagValidationProfile,
+	)
+}
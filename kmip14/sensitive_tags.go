@@ -0,0 +1,29 @@
+package kmip14
+
+import (
+	"github.com/gemalto/kmip-go/ttlv"
+)
+
+// SensitiveTags returns the tags whose values may hold secrets: key material,
+// private key components, passwords, opaque data, and the data of cryptographic
+// operations.  It is conservative: some of these tags, like P and Q, also hold public
+// values in some structures.
+//
+// Each call returns a new set, so callers may add their own tags to it.
+func SensitiveTags() ttlv.TagSet {
+	return ttlv.NewTagSet(
+		TagCRTCoefficient,
+		TagD,
+		TagKey,
+		TagKeyMaterial,
+		TagOpaqueDataValue,
+		TagP,
+		TagPrimeExponentP,
+		TagPrimeExponentQ,
+		TagPrivateExponent,
+		TagQ,
+		TagX,
+		TagPassword,
+		TagData,
+	)
+}
@@ -0,0 +1,120 @@
+package kmip14
+
+import (
+	"github.com/gemalto/kmip-go/ttlv"
+)
+
+// RegisterStructures registers the values required in the most common 1.4 structures with the
+// registry, for use by Registry.ValidateStructure().  Values which the spec only requires in
+// some contexts, like the Operation in a Batch Item, aren't registered.  Values which may be
+// encoded as more than one type are registered without a type.
+func RegisterStructures(registry *ttlv.Registry) {
+	req := func(t ttlv.Tag, typ ttlv.Type) ttlv.RequiredValue {
+		return ttlv.RequiredValue{Tag: t, Type: typ}
+	}
+
+	// messages
+	registry.RegisterStructure(TagRequestMessage,
+		req(TagRequestHeader, ttlv.TypeStructure),
+		req(TagBatchItem, ttlv.TypeStructure),
+	)
+	registry.RegisterStructure(TagResponseMessage,
+		req(TagResponseHeader, ttlv.TypeStructure),
+		req(TagBatchItem, ttlv.TypeStructure),
+	)
+	registry.RegisterStructure(TagRequestHeader,
+		req(TagProtocolVersion, ttlv.TypeStructure),
+		req(TagBatchCount, ttlv.TypeInteger),
+	)
+	registry.RegisterStructure(TagResponseHeader,
+		req(TagProtocolVersion, ttlv.TypeStructure),
+		req(TagTimeStamp, ttlv.TypeDateTime),
+		req(TagBatchCount, ttlv.TypeInteger),
+	)
+	registry.RegisterStructure(TagProtocolVersion,
+		req(TagProtocolVersionMajor, ttlv.TypeInteger),
+		req(TagProtocolVersionMinor, ttlv.TypeInteger),
+	)
+	registry.RegisterStructure(TagAuthentication,
+		req(TagCredential, ttlv.TypeStructure),
+	)
+	registry.RegisterStructure(TagCredential,
+		req(TagCredentialType, ttlv.TypeEnumeration),
+		req(TagCredentialValue, 0),
+	)
+	registry.RegisterStructure(TagMessageExtension,
+		req(TagVendorIdentification, ttlv.TypeTextString),
+		req(TagCriticalityIndicator, ttlv.TypeBoolean),
+		req(TagVendorExtension, ttlv.TypeStructure),
+	)
+
+	// managed objects
+	for _, t := range []ttlv.Tag{TagSymmetricKey, TagPublicKey, TagPrivateKey, TagSplitKey, TagPGPKey} {
+		registry.RegisterStructure(t, req(TagKeyBlock, ttlv.TypeStructure))
+	}
+
+	registry.RegisterStructure(TagSecretData,
+		req(TagSecretDataType, ttlv.TypeEnumeration),
+		req(TagKeyBlock, ttlv.TypeStructure),
+	)
+	registry.RegisterStructure(TagCertificate,
+		req(TagCertificateType, ttlv.TypeEnumeration),
+		req(TagCertificateValue, ttlv.TypeByteString),
+	)
+	registry.RegisterStructure(TagOpaqueObject,
+		req(TagOpaqueDataType, ttlv.TypeEnumeration),
+		req(TagOpaqueDataValue, ttlv.TypeByteString),
+	)
+
+	// key blocks
+	registry.RegisterStructure(TagKeyBlock,
+		req(TagKeyFormatType, ttlv.TypeEnumeration),
+		req(TagKeyValue, 0),
+	)
+	registry.RegisterStructure(TagKeyValue,
+		req(TagKeyMaterial, 0),
+	)
+	registry.RegisterStructure(TagKeyWrappingData,
+		req(TagWrappingMethod, ttlv.TypeEnumeration),
+	)
+	registry.RegisterStructure(TagKeyWrappingSpecification,
+		req(TagWrappingMethod, ttlv.TypeEnumeration),
+	)
+	registry.RegisterStructure(TagEncryptionKeyInformation,
+		req(TagUniqueIdentifier, 0),
+	)
+	registry.RegisterStructure(TagMACSignatureKeyInformation,
+		req(TagUniqueIdentifier, 0),
+	)
+
+	// attributes
+	registry.RegisterStructure(TagAttribute,
+		req(TagAttributeName, ttlv.TypeTextString),
+	)
+	registry.RegisterStructure(TagName,
+		req(TagNameValue, ttlv.TypeTextString),
+		req(TagNameType, ttlv.TypeEnumeration),
+	)
+	registry.RegisterStructure(TagLink,
+		req(TagLinkType, ttlv.TypeEnumeration),
+		req(TagLinkedObjectIdentifier, 0),
+	)
+	registry.RegisterStructure(TagDigest,
+		req(TagHashingAlgorithm, ttlv.TypeEnumeration),
+	)
+	registry.RegisterStructure(TagUsageLimits,
+		req(TagUsageLimitsUnit, ttlv.TypeEnumeration),
+	)
+	registry.RegisterStructure(TagExtensionInformation,
+		req(TagExtensionName, ttlv.TypeTextString),
+	)
+	registry.RegisterStructure(TagProfileInformation,
+		req(TagProfileName, ttlv.TypeEnumeration),
+	)
+	registry.RegisterStructure(TagValidationInformation,
+		req(TagValidationAuthorityType, ttlv.TypeEnumeration),
+		req(TagValidationVersionMajor, ttlv.TypeInteger),
+		req(TagValidationType, ttlv.TypeEnumeration),
+		req(TagValidationLevel, ttlv.TypeInteger),
+	)
+}
@@ -1,6 +1,7 @@
 package kmip20
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/gemalto/kmip-go/kmip14"
@@ -123,3 +124,22 @@ func v(tag ttlv.Tag, val interface{}) ttlv.Value {
 func s(tag ttlv.Tag, vals ...ttlv.Value) ttlv.Value {
 	return ttlv.NewStruct(tag, vals...)
 }
+
+func TestRecommendedCurve_names(t *testing.T) {
+	for _, c := range []RecommendedCurve{RecommendedCurveP_256, RecommendedCurveCURVE25519, RecommendedCurveCURVE448} {
+		b, err := ttlv.Marshal(ttlv.Value{Tag: kmip14.TagRecommendedCurve, Value: c})
+		require.NoError(t, err)
+
+		j, err := json.Marshal(b)
+		require.NoError(t, err)
+		require.Contains(t, string(j), `"value":"`+ttlv.DefaultRegistry.FormatEnum(kmip14.TagRecommendedCurve, uint32(c))+`"`)
+
+		var out ttlv.TTLV
+		require.NoError(t, json.Unmarshal(j, &out))
+		require.Equal(t, b, out)
+	}
+
+	v, err := ttlv.DefaultRegistry.ParseEnum(kmip14.TagRecommendedCurve, "CURVE25519")
+	require.NoError(t, err)
+	require.Equal(t, uint32(RecommendedCurveCURVE25519), v)
+}
@@ -59,6 +59,14 @@ type SecretData struct {
 
 // 2.2.8
 
+// OpaqueObject holds data the key management system doesn't interpret, like a vendor blob.
+// The spec doesn't define any Opaque Data Type values, only the extension range, so vendors
+// should register names for their values on the Opaque Data Type enum, e.g.:
+//
+//	ttlv.DefaultRegistry.EnumForTag(kmip14.TagOpaqueDataType).(*ttlv.Enum).RegisterValue(0x80000001, "MyBlob")
+//
+// Registered names are used by the registry when printing and encoding TTLV as JSON or XML.
+// Unregistered values are formatted as hex.
 type OpaqueObject struct {
 	OpaqueDataType  kmip14.OpaqueDataType
 	OpaqueDataValue []byte
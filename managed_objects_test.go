@@ -0,0 +1,43 @@
+package kmip
+
+import (
+	"testing"
+
+	"github.com/gemalto/kmip-go/kmip14"
+	"github.com/gemalto/kmip-go/ttlv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpaqueObject(t *testing.T) {
+	var r ttlv.Registry
+	kmip14.Register(&r)
+
+	enum, ok := r.EnumForTag(kmip14.TagOpaqueDataType).(*ttlv.Enum)
+	require.True(t, ok)
+	enum.RegisterValue(0x80000001, "VendorBlob")
+	assert.Equal(t, "VendorBlob", r.FormatEnum(kmip14.TagOpaqueDataType, 0x80000001))
+	assert.Equal(t, "0x80000002", r.FormatEnum(kmip14.TagOpaqueDataType, 0x80000002))
+
+	obj := &OpaqueObject{
+		OpaqueDataType:  0x80000001,
+		OpaqueDataValue: []byte{0x01, 0x02, 0x03},
+	}
+
+	b, err := ttlv.Marshal(ttlv.Value{Tag: kmip14.TagResponsePayload, Value: GetResponsePayload{
+		ObjectType:       kmip14.ObjectTypeOpaqueObject,
+		UniqueIdentifier: "1",
+		OpaqueObject:     obj,
+	}})
+	require.NoError(t, err)
+
+	var get GetResponsePayload
+	require.NoError(t, ttlv.Unmarshal(b, &get))
+	assert.Equal(t, obj, get.Object())
+
+	reg := RegisterRequestPayload{ObjectType: kmip14.ObjectTypeOpaqueObject, OpaqueObject: obj}
+	assert.Equal(t, obj, reg.Object())
+
+	reg.ObjectType = kmip14.ObjectTypeSymmetricKey
+	assert.Nil(t, reg.Object())
+}
@@ -0,0 +1,44 @@
+package kmip
+
+import (
+	"context"
+)
+
+// 4.22
+//
+// This operation is used to specify that a Managed Object MAY be archived.  The actual time when the
+// object is archived, the location of the archive, or level of protection of the archive, are server
+// dependent.  When the object is archived, its Storage Status becomes Archival Storage, and a Locate
+// request will only find it if the request's Storage Status Mask includes Archival Storage.
+
+// ArchiveRequestPayload ////////////////////////////////////////
+type ArchiveRequestPayload struct {
+	UniqueIdentifier string `ttlv:",omitempty"`
+}
+
+// ArchiveResponsePayload
+type ArchiveResponsePayload struct {
+	UniqueIdentifier string
+}
+
+type ArchiveHandler struct {
+	Archive func(ctx context.Context, payload *ArchiveRequestPayload) (*ArchiveResponsePayload, error)
+}
+
+func (h *ArchiveHandler) HandleItem(ctx context.Context, req *Request) (*ResponseBatchItem, error) {
+	var payload ArchiveRequestPayload
+
+	err := req.DecodePayload(&payload)
+	if err != nil {
+		return nil, err
+	}
+
+	respPayload, err := h.Archive(ctx, &payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ResponseBatchItem{
+		ResponsePayload: respPayload,
+	}, nil
+}
@@ -0,0 +1,89 @@
+package kmip
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/gemalto/kmip-go/kmip14"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArchiveRecoverHandlers(t *testing.T) {
+	archived := map[string]bool{"1": false, "2": false}
+
+	handlers := map[kmip14.Operation]ItemHandler{}
+	handlers[kmip14.OperationArchive] = &ArchiveHandler{
+		Archive: func(ctx context.Context, payload *ArchiveRequestPayload) (*ArchiveResponsePayload, error) {
+			if _, ok := archived[payload.UniqueIdentifier]; !ok {
+				return nil, WithResultReason(errors.New("not found"), kmip14.ResultReasonItemNotFound)
+			}
+
+			archived[payload.UniqueIdentifier] = true
+
+			return &ArchiveResponsePayload{UniqueIdentifier: payload.UniqueIdentifier}, nil
+		},
+	}
+	handlers[kmip14.OperationRecover] = &RecoverHandler{
+		Recover: func(ctx context.Context, payload *RecoverRequestPayload) (*RecoverResponsePayload, error) {
+			archived[payload.UniqueIdentifier] = false
+
+			return &RecoverResponsePayload{UniqueIdentifier: payload.UniqueIdentifier}, nil
+		},
+	}
+	handlers[kmip14.OperationLocate] = &LocateHandler{
+		Locate: func(ctx context.Context, payload *LocateRequestPayload) (*LocateResponsePayload, error) {
+			mask := payload.StorageStatusMask
+			if mask == 0 {
+				mask = kmip14.StorageStatusMaskOnLineStorage
+			}
+
+			resp := LocateResponsePayload{}
+
+			for _, id := range []string{"1", "2"} {
+				if (archived[id] && mask&kmip14.StorageStatusMaskArchivalStorage != 0) ||
+					(!archived[id] && mask&kmip14.StorageStatusMaskOnLineStorage != 0) {
+					resp.UniqueIdentifier = append(resp.UniqueIdentifier, id)
+				}
+			}
+
+			return &resp, nil
+		},
+	}
+
+	call := func(op kmip14.Operation, p, respPayload interface{}) error {
+		bi := roundTripItem(t, op, handlers[op], p)
+
+		return bi.DecodePayload(respPayload)
+	}
+
+	var archiveResp ArchiveResponsePayload
+	require.NoError(t, call(kmip14.OperationArchive, ArchiveRequestPayload{UniqueIdentifier: "1"}, &archiveResp))
+	assert.Equal(t, "1", archiveResp.UniqueIdentifier)
+
+	err := call(kmip14.OperationArchive, ArchiveRequestPayload{UniqueIdentifier: "3"}, &archiveResp)
+
+	var itemErr *ItemError
+
+	require.True(t, errors.As(err, &itemErr), "got %v", err)
+	assert.Equal(t, kmip14.ResultReasonItemNotFound, itemErr.ResultReason)
+
+	var locateResp LocateResponsePayload
+	require.NoError(t, call(kmip14.OperationLocate, LocateRequestPayload{}, &locateResp))
+	assert.Equal(t, []string{"2"}, locateResp.UniqueIdentifier)
+
+	locateResp = LocateResponsePayload{}
+	require.NoError(t, call(kmip14.OperationLocate, LocateRequestPayload{
+		StorageStatusMask: kmip14.StorageStatusMaskOnLineStorage | kmip14.StorageStatusMaskArchivalStorage,
+	}, &locateResp))
+	assert.Equal(t, []string{"1", "2"}, locateResp.UniqueIdentifier)
+
+	var recoverResp RecoverResponsePayload
+	require.NoError(t, call(kmip14.OperationRecover, RecoverRequestPayload{UniqueIdentifier: "1"}, &recoverResp))
+	assert.Equal(t, "1", recoverResp.UniqueIdentifier)
+
+	locateResp = LocateResponsePayload{}
+	require.NoError(t, call(kmip14.OperationLocate, LocateRequestPayload{}, &locateResp))
+	assert.Equal(t, []string{"1", "2"}, locateResp.UniqueIdentifier)
+}
@@ -0,0 +1,52 @@
+package kmip
+
+import (
+	"context"
+
+	"github.com/gemalto/kmip-go/kmip14"
+)
+
+// 4.27
+//
+// This operation requests the server to cancel an outstanding asynchronous operation. The correlation
+// value (see 6.8) of the original operation SHALL be specified in the request. The server SHALL respond
+// with a Cancellation Result that contains the status of the cancellation.
+
+// CancelRequestPayload 4.27
+type CancelRequestPayload struct {
+	AsynchronousCorrelationValue []byte
+}
+
+// CancelResponsePayload 4.27
+type CancelResponsePayload struct {
+	AsynchronousCorrelationValue []byte
+	CancellationResult           kmip14.CancellationResult
+}
+
+// CancelHandler handles Cancel requests.  If the Cancel function returns a response without an
+// Asynchronous Correlation Value, the value from the request is used.
+type CancelHandler struct {
+	Cancel func(ctx context.Context, payload *CancelRequestPayload) (*CancelResponsePayload, error)
+}
+
+func (h *CancelHandler) HandleItem(ctx context.Context, req *Request) (*ResponseBatchItem, error) {
+	var payload CancelRequestPayload
+
+	err := req.DecodePayload(&payload)
+	if err != nil {
+		return nil, err
+	}
+
+	respPayload, err := h.Cancel(ctx, &payload)
+	if err != nil {
+		return nil, err
+	}
+
+	if respPayload.AsynchronousCorrelationValue == nil {
+		respPayload.AsynchronousCorrelationValue = payload.AsynchronousCorrelationValue
+	}
+
+	return &ResponseBatchItem{
+		ResponsePayload: respPayload,
+	}, nil
+}
@@ -0,0 +1,30 @@
+package kmip
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gemalto/kmip-go/kmip14"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCancelHandler(t *testing.T) {
+	handler := &CancelHandler{
+		Cancel: func(ctx context.Context, payload *CancelRequestPayload) (*CancelResponsePayload, error) {
+			assert.Equal(t, []byte{1, 2, 3}, payload.AsynchronousCorrelationValue)
+
+			return &CancelResponsePayload{CancellationResult: kmip14.CancellationResultCanceled}, nil
+		},
+	}
+
+	bi := roundTripItem(t, kmip14.OperationCancel, handler, CancelRequestPayload{AsynchronousCorrelationValue: []byte{1, 2, 3}})
+
+	// the correlation value is echoed, and the result decodes to the typed enum
+	var respPayload CancelResponsePayload
+	require.NoError(t, bi.DecodePayload(&respPayload))
+	assert.Equal(t, CancelResponsePayload{
+		AsynchronousCorrelationValue: []byte{1, 2, 3},
+		CancellationResult:           kmip14.CancellationResultCanceled,
+	}, respPayload)
+}
@@ -0,0 +1,94 @@
+package kmip
+
+import (
+	"context"
+	"time"
+
+	"github.com/gemalto/kmip-go/kmip14"
+	"github.com/gemalto/kmip-go/ttlv"
+)
+
+// 4.10
+//
+// This operation requests that the server check for the use of a Managed Object according to values
+// specified in the request. This operation SHOULD only be used when placed in a batched set of operations,
+// usually following a Locate, Create, Create Pair, Derive Key, Certify, Re-Certify, Re-key or Re-key Key
+// Pair operation, and followed by a Get operation.
+//
+// If the server determines that the client is allowed to use the object according to the specified
+// attributes, then the server returns the Unique Identifier of the object.  If not, the server returns the
+// fields from the request which could not be satisfied.
+
+// CheckRequestPayload 4.10 Table 182
+//
+// The constraint fields are optional: only the ones which are set are checked.
+type CheckRequestPayload struct {
+	UniqueIdentifier       string                        `ttlv:",omitempty"`
+	UsageLimitsCount       int64                         `ttlv:",omitempty"`
+	CryptographicUsageMask kmip14.CryptographicUsageMask `ttlv:",omitempty"`
+	LeaseTime              time.Duration                 `ttlv:",omitempty"`
+}
+
+// CheckResponsePayload 4.10 Table 183
+//
+// The constraint fields are only set if the corresponding constraint in the request
+// could not be satisfied.
+type CheckResponsePayload struct {
+	UniqueIdentifier       string
+	UsageLimitsCount       int64                         `ttlv:",omitempty"`
+	CryptographicUsageMask kmip14.CryptographicUsageMask `ttlv:",omitempty"`
+	LeaseTime              time.Duration                 `ttlv:",omitempty"`
+}
+
+// FailedConstraints returns the tags of the constraints which could not be satisfied, or
+// nil if the check passed.
+func (p *CheckResponsePayload) FailedConstraints() []ttlv.Tag {
+	var tags []ttlv.Tag
+
+	if p.UsageLimitsCount != 0 {
+		tags = append(tags, kmip14.TagUsageLimitsCount)
+	}
+
+	if p.CryptographicUsageMask != 0 {
+		tags = append(tags, kmip14.TagCryptographicUsageMask)
+	}
+
+	if p.LeaseTime != 0 {
+		tags = append(tags, kmip14.TagLeaseTime)
+	}
+
+	return tags
+}
+
+// CheckHandler handles Check requests.  The Check function should set the fields of
+// the response for each constraint which can't be satisfied.  If any are set, the
+// batch item is returned with Result Status Operation Failed, and Result Reason
+// Permission Denied, along with the response payload.
+type CheckHandler struct {
+	Check func(ctx context.Context, payload *CheckRequestPayload) (*CheckResponsePayload, error)
+}
+
+func (h *CheckHandler) HandleItem(ctx context.Context, req *Request) (*ResponseBatchItem, error) {
+	var payload CheckRequestPayload
+
+	err := req.DecodePayload(&payload)
+	if err != nil {
+		return nil, err
+	}
+
+	respPayload, err := h.Check(ctx, &payload)
+	if err != nil {
+		return nil, err
+	}
+
+	item := &ResponseBatchItem{
+		ResponsePayload: respPayload,
+	}
+
+	if respPayload != nil && len(respPayload.FailedConstraints()) > 0 {
+		item.ResultStatus = kmip14.ResultStatusOperationFailed
+		item.ResultReason = kmip14.ResultReasonPermissionDenied
+	}
+
+	return item, nil
+}
@@ -0,0 +1,58 @@
+package kmip
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gemalto/kmip-go/kmip14"
+	"github.com/gemalto/kmip-go/ttlv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckHandler(t *testing.T) {
+	handler := &CheckHandler{
+		Check: func(ctx context.Context, payload *CheckRequestPayload) (*CheckResponsePayload, error) {
+			if payload.UniqueIdentifier == "" {
+				return nil, nil
+			}
+
+			resp := CheckResponsePayload{UniqueIdentifier: payload.UniqueIdentifier}
+			if payload.UsageLimitsCount > 10 {
+				resp.UsageLimitsCount = payload.UsageLimitsCount
+			}
+
+			return &resp, nil
+		},
+	}
+
+	check := func(p CheckRequestPayload) (*CheckResponsePayload, error) {
+		bi := roundTripItem(t, kmip14.OperationCheck, handler, p)
+
+		var respPayload CheckResponsePayload
+		err := bi.DecodePayload(&respPayload)
+
+		return &respPayload, err
+	}
+
+	resp, err := check(CheckRequestPayload{UniqueIdentifier: "1", UsageLimitsCount: 5, LeaseTime: time.Hour})
+	require.NoError(t, err)
+	assert.Equal(t, "1", resp.UniqueIdentifier)
+	assert.Empty(t, resp.FailedConstraints())
+
+	resp, err = check(CheckRequestPayload{UniqueIdentifier: "1", UsageLimitsCount: 20})
+
+	var itemErr *ItemError
+
+	require.True(t, errors.As(err, &itemErr))
+	assert.Equal(t, kmip14.ResultReasonPermissionDenied, itemErr.ResultReason)
+	assert.Equal(t, []ttlv.Tag{kmip14.TagUsageLimitsCount}, resp.FailedConstraints())
+	assert.Equal(t, int64(20), resp.UsageLimitsCount)
+
+	// a nil response payload is passed through
+	resp, err = check(CheckRequestPayload{})
+	require.NoError(t, err)
+	assert.Equal(t, &CheckResponsePayload{}, resp)
+}
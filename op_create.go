@@ -2,10 +2,14 @@ package kmip
 
 import (
 	"context"
+	"reflect"
+	"strconv"
+	"strings"
 
 	"github.com/ansel1/merry"
 
 	"github.com/gemalto/kmip-go/kmip14"
+	"github.com/gemalto/kmip-go/ttlv"
 )
 
 // TODO: should request and response payloads implement validation?
@@ -35,6 +39,152 @@ type CreateRequestPayload struct {
 	TemplateAttribute TemplateAttribute
 }
 
+// symmetricKeyLengths lists the valid key lengths, in bits, for symmetric key algorithms
+// which only support a fixed set of key lengths.
+var symmetricKeyLengths = map[kmip14.CryptographicAlgorithm][]int{
+	kmip14.CryptographicAlgorithmDES:      {56},
+	kmip14.CryptographicAlgorithmDES3:     {112, 168},
+	kmip14.CryptographicAlgorithmAES:      {128, 192, 256},
+	kmip14.CryptographicAlgorithmCamellia: {128, 192, 256},
+	kmip14.CryptographicAlgorithmSKIPJACK: {80},
+	kmip14.CryptographicAlgorithmTwofish:  {128, 192, 256},
+	kmip14.CryptographicAlgorithmChaCha20: {256},
+}
+
+// Validate checks that the payload contains the attributes required to create an object
+// with the given protocol version, and fills in defaults where the spec allows.
+//
+//   - ObjectType defaults to SymmetricKey, the only type of object the Create operation creates.
+//   - CryptographicAlgorithm and CryptographicUsageMask are required.
+//   - If the algorithm only supports certain key lengths (e.g. AES requires 128, 192, or 256),
+//     CryptographicLength must be one of them.  If the algorithm only supports a single
+//     key length, CryptographicLength defaults to that length.
+//
+// This payload is the KMIP 1.x form of the request, so Validate returns an error for
+// protocol versions 2.0 and later.
+//
+// Validate doesn't check that the attributes apply to symmetric keys, since servers often accept
+// more than the spec allows.  Call TemplateAttribute.ValidateObjectAttributes() to check that too.
+//
+// All missing attributes are listed in the error.  Errors carry a ResultReason (see GetResultReason()), so
+// handlers may also use Validate to check requests.
+func (p *CreateRequestPayload) Validate(version ProtocolVersion) error {
+	if version.ProtocolVersionMajor != 1 {
+		return WithResultReason(merry.UserErrorf("Create payload with a Template-Attribute is not supported in protocol version %d.%d", version.ProtocolVersionMajor, version.ProtocolVersionMinor), kmip14.ResultReasonInvalidMessage)
+	}
+
+	if p.ObjectType == 0 {
+		p.ObjectType = kmip14.ObjectTypeSymmetricKey
+	}
+
+	if p.ObjectType != kmip14.ObjectTypeSymmetricKey {
+		return WithResultReason(merry.UserErrorf("Create does not support Object Type %s", p.ObjectType.String()), kmip14.ResultReasonInvalidField)
+	}
+
+	var missing []string
+
+	alg, algOK, err := attributeIntValue(kmip14.TagCryptographicAlgorithm, p.TemplateAttribute.GetTag(kmip14.TagCryptographicAlgorithm))
+	if err != nil {
+		return err
+	}
+
+	if !algOK {
+		missing = append(missing, kmip14.TagCryptographicAlgorithm.CanonicalName())
+	}
+
+	_, maskOK, err := attributeIntValue(kmip14.TagCryptographicUsageMask, p.TemplateAttribute.GetTag(kmip14.TagCryptographicUsageMask))
+	if err != nil {
+		return err
+	}
+
+	if !maskOK {
+		missing = append(missing, kmip14.TagCryptographicUsageMask.CanonicalName())
+	}
+
+	lengths := symmetricKeyLengths[kmip14.CryptographicAlgorithm(alg)]
+	l, lenOK, err := attributeIntValue(kmip14.TagCryptographicLength, p.TemplateAttribute.GetTag(kmip14.TagCryptographicLength))
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case !algOK || lenOK || len(lengths) == 0:
+	case len(lengths) == 1:
+		p.TemplateAttribute.Append(kmip14.TagCryptographicLength, lengths[0])
+		l, lenOK = int64(lengths[0]), true
+	default:
+		missing = append(missing, kmip14.TagCryptographicLength.CanonicalName())
+	}
+
+	if len(missing) > 0 {
+		return WithResultReason(merry.UserErrorf("missing required attributes: %s", strings.Join(missing, ", ")), kmip14.ResultReasonMissingData)
+	}
+
+	if lenOK && len(lengths) > 0 && !containsInt(lengths, l) {
+		strs := make([]string, len(lengths))
+		for i, v := range lengths {
+			strs[i] = strconv.Itoa(v)
+		}
+
+		return WithResultReason(merry.UserErrorf("invalid Cryptographic Length %d for %s, must be one of: %s", l, kmip14.CryptographicAlgorithm(alg).String(), strings.Join(strs, ", ")), kmip14.ResultReasonInvalidField)
+	}
+
+	return nil
+}
+
+// attributeIntValue returns the value of the attribute with tag, if it has an integer or enumeration
+// value.  Strings, like the values EncodeAttributesMap() accepts, are parsed as names or numbers with
+// the DefaultRegistry, e.g. "AES" or "Encrypt|Decrypt".  Returns false if the attribute is nil, or
+// an error with ResultReasonInvalidField if it has another type of value, or a string which can't
+// be parsed.
+func attributeIntValue(tag ttlv.Tag, a *Attribute) (int64, bool, error) {
+	if a == nil || a.AttributeValue == nil {
+		return 0, false, nil
+	}
+
+	v := reflect.ValueOf(a.AttributeValue)
+
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), true, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(v.Uint()), true, nil
+	case reflect.String:
+		var (
+			i   int64
+			err error
+		)
+
+		if ttlv.DefaultRegistry.IsEnum(tag) {
+			var u uint32
+			u, err = ttlv.DefaultRegistry.ParseEnum(tag, v.String())
+			i = int64(u)
+		} else {
+			var i32 int32
+			i32, err = ttlv.DefaultRegistry.ParseInt(tag, v.String())
+			i = int64(i32)
+		}
+
+		if err != nil {
+			return 0, false, WithResultReason(merry.UserErrorf("invalid %s value %q", tag.CanonicalName(), v.String()), kmip14.ResultReasonInvalidField)
+		}
+
+		return i, true, nil
+	default:
+		return 0, false, WithResultReason(merry.UserErrorf("invalid %s value type: %T", tag.CanonicalName(), a.AttributeValue), kmip14.ResultReasonInvalidField)
+	}
+}
+
+func containsInt(values []int, v int64) bool {
+	for _, i := range values {
+		if int64(i) == v {
+			return true
+		}
+	}
+
+	return false
+}
+
 // CreateResponsePayload 4.1 Table 164
 type CreateResponsePayload struct {
 	ObjectType        kmip14.ObjectType
@@ -0,0 +1,127 @@
+package kmip
+
+import (
+	"testing"
+
+	"github.com/gemalto/kmip-go/kmip14"
+	"github.com/gemalto/kmip-go/ttlv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateRequestPayload_Validate(t *testing.T) {
+	v14 := ProtocolVersion{ProtocolVersionMajor: 1, ProtocolVersionMinor: 4}
+
+	newPayload := func(alg interface{}, length interface{}) *CreateRequestPayload {
+		p := &CreateRequestPayload{}
+		if alg != nil {
+			p.TemplateAttribute.Append(kmip14.TagCryptographicAlgorithm, alg)
+		}
+
+		if length != nil {
+			p.TemplateAttribute.Append(kmip14.TagCryptographicLength, length)
+		}
+
+		p.TemplateAttribute.Append(kmip14.TagCryptographicUsageMask, kmip14.CryptographicUsageMaskEncrypt)
+
+		return p
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		p := newPayload(kmip14.CryptographicAlgorithmAES, 256)
+		require.NoError(t, p.Validate(v14))
+		assert.Equal(t, kmip14.ObjectTypeSymmetricKey, p.ObjectType)
+	})
+
+	t.Run("decodedvalues", func(t *testing.T) {
+		// values decoded from TTLV have the generic ttlv types
+		p := newPayload(ttlv.EnumValue(kmip14.CryptographicAlgorithmAES), int32(128))
+		require.NoError(t, p.Validate(v14))
+	})
+
+	t.Run("stringvalues", func(t *testing.T) {
+		// names and numbers, as accepted by EncodeAttributesMap
+		p := &CreateRequestPayload{}
+		p.TemplateAttribute.Append(kmip14.TagCryptographicAlgorithm, "AES")
+		p.TemplateAttribute.Append(kmip14.TagCryptographicLength, "192")
+		p.TemplateAttribute.Append(kmip14.TagCryptographicUsageMask, "Encrypt|Decrypt")
+		require.NoError(t, p.Validate(v14))
+
+		p = newPayload(kmip14.CryptographicAlgorithmAES, "100")
+		err := p.Validate(v14)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid Cryptographic Length 100")
+	})
+
+	t.Run("wrongvaluetype", func(t *testing.T) {
+		err := newPayload("Juggling", 256).Validate(v14)
+		require.Error(t, err)
+		assert.Equal(t, kmip14.ResultReasonInvalidField, GetResultReason(err))
+		assert.Contains(t, err.Error(), `invalid Cryptographic Algorithm value "Juggling"`)
+
+		err = newPayload(kmip14.CryptographicAlgorithmAES, 256.0).Validate(v14)
+		require.Error(t, err)
+		assert.Equal(t, kmip14.ResultReasonInvalidField, GetResultReason(err))
+		assert.NotContains(t, err.Error(), "missing")
+	})
+
+	t.Run("invalidlength", func(t *testing.T) {
+		err := newPayload(kmip14.CryptographicAlgorithmAES, 100).Validate(v14)
+		require.EqualError(t, err, "invalid Cryptographic Length 100 for AES, must be one of: 128, 192, 256")
+		assert.Equal(t, kmip14.ResultReasonInvalidField, GetResultReason(err))
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		p := &CreateRequestPayload{}
+		p.TemplateAttribute.Append(kmip14.TagCryptographicAlgorithm, kmip14.CryptographicAlgorithmAES)
+
+		err := p.Validate(v14)
+		require.EqualError(t, err, "missing required attributes: Cryptographic Usage Mask, Cryptographic Length")
+		assert.Equal(t, kmip14.ResultReasonMissingData, GetResultReason(err))
+
+		err = (&CreateRequestPayload{}).Validate(v14)
+		require.EqualError(t, err, "missing required attributes: Cryptographic Algorithm, Cryptographic Usage Mask")
+	})
+
+	t.Run("defaultlength", func(t *testing.T) {
+		p := newPayload(kmip14.CryptographicAlgorithmChaCha20, nil)
+		require.NoError(t, p.Validate(v14))
+
+		attr := p.TemplateAttribute.GetTag(kmip14.TagCryptographicLength)
+		require.NotNil(t, attr)
+		assert.Equal(t, 256, attr.AttributeValue)
+	})
+
+	t.Run("variablelength", func(t *testing.T) {
+		p := newPayload(kmip14.CryptographicAlgorithmHMAC_SHA256, nil)
+		require.NoError(t, p.Validate(v14))
+		assert.Nil(t, p.TemplateAttribute.GetTag(kmip14.TagCryptographicLength))
+	})
+
+	t.Run("objecttype", func(t *testing.T) {
+		p := newPayload(kmip14.CryptographicAlgorithmAES, 256)
+		p.ObjectType = kmip14.ObjectTypePrivateKey
+		err := p.Validate(v14)
+		require.Error(t, err)
+		assert.Equal(t, kmip14.ResultReasonInvalidField, GetResultReason(err))
+	})
+
+	t.Run("inapplicableattribute", func(t *testing.T) {
+		p := newPayload(kmip14.CryptographicAlgorithmAES, 256)
+		p.TemplateAttribute.Append(kmip14.TagCertificateType, kmip14.CertificateTypeX_509)
+		p.TemplateAttribute.Append(kmip14.TagCryptographicDomainParameters, CryptographicDomainParameters{Qlength: 256})
+
+		// checking the attributes apply to the object type is opt-in
+		require.NoError(t, p.Validate(v14))
+
+		err := p.TemplateAttribute.ValidateObjectAttributes(p.ObjectType)
+		require.EqualError(t, err, "attributes do not apply to SymmetricKey: Certificate Type, Cryptographic Domain Parameters")
+		assert.Equal(t, kmip14.ResultReasonInvalidField, GetResultReason(err))
+	})
+
+	t.Run("version2", func(t *testing.T) {
+		err := newPayload(kmip14.CryptographicAlgorithmAES, 256).Validate(ProtocolVersion{ProtocolVersionMajor: 2})
+		require.Error(t, err)
+		assert.Equal(t, kmip14.ResultReasonInvalidMessage, GetResultReason(err))
+	})
+}
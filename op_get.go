@@ -13,10 +13,40 @@ type GetRequestPayload struct {
 }
 
 // GetResponsePayload
+//
+// The field which corresponds to ObjectType should be set to the retrieved object.
 type GetResponsePayload struct {
 	ObjectType       kmip14.ObjectType
 	UniqueIdentifier string
 	Key              string
+	Certificate      *Certificate
+	SymmetricKey     *SymmetricKey
+	PrivateKey       *PrivateKey
+	PublicKey        *PublicKey
+	SplitKey         *SplitKey
+	Template         *Template
+	SecretData       *SecretData
+	OpaqueObject     *OpaqueObject
+	PGPKey           *PGPKey
+}
+
+// Object returns the managed object field which corresponds to ObjectType, or nil.
+// See RegisterRequestPayload.Object().
+func (p *GetResponsePayload) Object() interface{} {
+	r := RegisterRequestPayload{
+		ObjectType:   p.ObjectType,
+		Certificate:  p.Certificate,
+		SymmetricKey: p.SymmetricKey,
+		PrivateKey:   p.PrivateKey,
+		PublicKey:    p.PublicKey,
+		SplitKey:     p.SplitKey,
+		Template:     p.Template,
+		SecretData:   p.SecretData,
+		OpaqueObject: p.OpaqueObject,
+		PGPKey:       p.PGPKey,
+	}
+
+	return r.Object()
 }
 
 type GetHandler struct {
@@ -0,0 +1,289 @@
+package kmip
+
+import (
+	"context"
+	"sort"
+
+	"github.com/ansel1/merry"
+	"github.com/gemalto/kmip-go/kmip14"
+	"github.com/gemalto/kmip-go/ttlv"
+)
+
+// Tags introduced in KMIP 2.0, which this package needs to encode and decode the 2.0 forms.
+// They are declared here rather than imported from kmip20, so importing this package doesn't
+// register the 2.0 definitions in the DefaultRegistry as a side effect.
+const (
+	tagAttributes           ttlv.Tag = 0x420125
+	tagCommonAttributes     ttlv.Tag = 0x420126
+	tagPrivateKeyAttributes ttlv.Tag = 0x420127
+	tagPublicKeyAttributes  ttlv.Tag = 0x420128
+	tagAttributeReference   ttlv.Tag = 0x42013b
+	tagProfileVersion       ttlv.Tag = 0x420142
+	tagProfileVersionMajor  ttlv.Tag = 0x420143
+	tagProfileVersionMinor  ttlv.Tag = 0x420144
+)
+
+// GetAttributesRequestPayload 4.12 (KMIP 1.x), 6.1.21 (KMIP 2.0)
+//
+// The attributes to retrieve are specified by their canonical names, e.g. "Cryptographic Algorithm",
+// regardless of the protocol version.  ProtocolVersion selects the encoding: in KMIP 1.x, each name is
+// encoded as an Attribute Name text string.  In KMIP 2.0 and later, each name is encoded as an Attribute
+// Reference, which is an enumeration of the attribute's tag, so the names must be registered tags.
+//
+// When unmarshaling, either form is accepted, and Attribute References are converted back to canonical names.
+// ProtocolVersion isn't part of the payload, so it is not set by unmarshaling.
+type GetAttributesRequestPayload struct {
+	ProtocolVersion  ProtocolVersion
+	UniqueIdentifier string
+	AttributeName    []string
+}
+
+func (p *GetAttributesRequestPayload) MarshalTTLV(e *ttlv.Encoder, tag ttlv.Tag) error {
+	return e.EncodeStructure(tag, func(e *ttlv.Encoder) error {
+		if p.UniqueIdentifier != "" {
+			e.EncodeTextString(kmip14.TagUniqueIdentifier, p.UniqueIdentifier)
+		}
+
+		for _, name := range p.AttributeName {
+			if p.ProtocolVersion.ProtocolVersionMajor < 2 {
+				e.EncodeTextString(kmip14.TagAttributeName, name)
+				continue
+			}
+
+			t, err := ttlv.DefaultRegistry.ParseTag(name)
+			if err != nil {
+				return merry.Prependf(err, "attribute name %q cannot be encoded as an Attribute Reference", name)
+			}
+
+			e.EncodeEnumeration(tagAttributeReference, uint32(t))
+		}
+
+		return nil
+	})
+}
+
+func (p *GetAttributesRequestPayload) UnmarshalTTLV(_ *ttlv.Decoder, v ttlv.TTLV) error {
+	if len(v) == 0 {
+		return nil
+	}
+
+	if v.Type() != ttlv.TypeStructure {
+		return merry.Errorf("invalid type for GetAttributesRequestPayload: %s", v.Type().String())
+	}
+
+	p.UniqueIdentifier = ""
+	p.AttributeName = nil
+
+	for n := v.ValueStructure(); len(n) > 0; n = n.Next() {
+		switch n.Tag() {
+		case kmip14.TagUniqueIdentifier:
+			s, err := n.AsString()
+			if err != nil {
+				return err
+			}
+
+			p.UniqueIdentifier = s
+		case kmip14.TagAttributeName:
+			s, err := n.AsString()
+			if err != nil {
+				return err
+			}
+
+			p.AttributeName = append(p.AttributeName, s)
+		case tagAttributeReference:
+			e, err := n.AsEnum()
+			if err != nil {
+				return err
+			}
+
+			p.AttributeName = append(p.AttributeName, ttlv.Tag(e).CanonicalName())
+		}
+	}
+
+	return nil
+}
+
+// GetAttributesResponsePayload 4.12 (KMIP 1.x), 6.1.21 (KMIP 2.0)
+//
+// As with the request, the Go representation is the same for all protocol versions.  In KMIP 1.x, the
+// attributes are encoded as a list of Attribute structures.  In KMIP 2.0 and later, they are encoded as the
+// members of an Attributes structure, each tagged with the attribute's tag.  Unmarshaling accepts either form.
+type GetAttributesResponsePayload struct {
+	ProtocolVersion  ProtocolVersion
+	UniqueIdentifier string
+	Attribute        []Attribute
+}
+
+func (p *GetAttributesResponsePayload) MarshalTTLV(e *ttlv.Encoder, tag ttlv.Tag) error {
+	return e.EncodeStructure(tag, func(e *ttlv.Encoder) error {
+		e.EncodeTextString(kmip14.TagUniqueIdentifier, p.UniqueIdentifier)
+
+		if p.ProtocolVersion.ProtocolVersionMajor < 2 {
+			for i := range p.Attribute {
+				if err := e.EncodeValue(kmip14.TagAttribute, &p.Attribute[i]); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}
+
+		return e.EncodeValue(tagAttributes, &Attributes{Attributes: p.Attribute})
+	})
+}
+
+func (p *GetAttributesResponsePayload) UnmarshalTTLV(d *ttlv.Decoder, v ttlv.TTLV) error {
+	if len(v) == 0 {
+		return nil
+	}
+
+	if v.Type() != ttlv.TypeStructure {
+		return merry.Errorf("invalid type for GetAttributesResponsePayload: %s", v.Type().String())
+	}
+
+	p.UniqueIdentifier = ""
+	p.Attribute = nil
+
+	for n := v.ValueStructure(); len(n) > 0; n = n.Next() {
+		switch n.Tag() {
+		case kmip14.TagUniqueIdentifier:
+			s, err := n.AsString()
+			if err != nil {
+				return err
+			}
+
+			p.UniqueIdentifier = s
+		case kmip14.TagAttribute:
+			var a Attribute
+			if err := d.DecodeValue(&a, n); err != nil {
+				return err
+			}
+
+			p.Attribute = append(p.Attribute, a)
+		case tagAttributes:
+			// KMIP 2.0 has no Attribute Index: the instances of each attribute are numbered
+			// in the order the server returned them.
+			var attrs Attributes
+			if err := d.DecodeValue(&attrs, n); err != nil {
+				return err
+			}
+
+			p.Attribute = append(p.Attribute, attrs.Attributes...)
+		}
+	}
+
+	return nil
+}
+
+// Get returns a reference to the first Attribute in the list matching the name.
+// Returns nil if not found.
+func (p *GetAttributesResponsePayload) Get(s string) *Attribute {
+	if p == nil {
+		return nil
+	}
+
+	for i := range p.Attribute {
+		if p.Attribute[i].AttributeName == s {
+			return &p.Attribute[i]
+		}
+	}
+
+	return nil
+}
+
+// GetAll returns all the instances of the named attribute, in the order the server returned them.
+func (p *GetAttributesResponsePayload) GetAll(s string) []Attribute {
+	if p == nil {
+		return nil
+	}
+
+	var ret []Attribute
+
+	for i := range p.Attribute {
+		if p.Attribute[i].AttributeName == s {
+			ret = append(ret, p.Attribute[i])
+		}
+	}
+
+	return ret
+}
+
+// Attributes returns the attributes as an Attributes, for its accessors, e.g. ActivationDate().
+// The returned value shares the Attribute slice with the payload.
+func (p *GetAttributesResponsePayload) Attributes() *Attributes {
+	if p == nil {
+		return nil
+	}
+
+	return &Attributes{Attributes: p.Attribute}
+}
+
+// Names returns the values of all the Name attributes, in the order the server returned them.
+func (p *GetAttributesResponsePayload) Names() ([]Name, error) {
+	var names []Name
+
+	for _, a := range p.GetAll(kmip14.TagName.CanonicalName()) {
+		a := a
+
+		n, err := NameFromAttribute(&a)
+		if err != nil {
+			return nil, err
+		}
+
+		names = append(names, n)
+	}
+
+	return names, nil
+}
+
+// Sorted returns a copy of the attributes, sorted by name, and then by index, so the attributes
+// can be iterated in a deterministic order regardless of the order the server returned them.
+// Instances of a multi-instance attribute with the same index keep the server's order.
+// The Attribute field itself is not modified, and keeps the server's order.
+func (p *GetAttributesResponsePayload) Sorted() []Attribute {
+	if p == nil {
+		return nil
+	}
+
+	sorted := append([]Attribute(nil), p.Attribute...)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].AttributeName != sorted[j].AttributeName {
+			return sorted[i].AttributeName < sorted[j].AttributeName
+		}
+
+		return sorted[i].AttributeIndex < sorted[j].AttributeIndex
+	})
+
+	return sorted
+}
+
+type GetAttributesHandler struct {
+	GetAttributes func(ctx context.Context, payload *GetAttributesRequestPayload) (*GetAttributesResponsePayload, error)
+}
+
+func (h *GetAttributesHandler) HandleItem(ctx context.Context, req *Request) (*ResponseBatchItem, error) {
+	var payload GetAttributesRequestPayload
+
+	err := req.DecodePayload(&payload)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Message != nil {
+		payload.ProtocolVersion = req.Message.RequestHeader.ProtocolVersion
+	}
+
+	respPayload, err := h.GetAttributes(ctx, &payload)
+	if err != nil {
+		return nil, err
+	}
+
+	if respPayload.ProtocolVersion == (ProtocolVersion{}) {
+		respPayload.ProtocolVersion = payload.ProtocolVersion
+	}
+
+	return &ResponseBatchItem{
+		ResponsePayload: respPayload,
+	}, nil
+}
@@ -0,0 +1,124 @@
+package kmip
+
+import (
+	"testing"
+
+	"github.com/gemalto/kmip-go/kmip14"
+	"github.com/gemalto/kmip-go/ttlv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetAttributesRequestPayload_marshal(t *testing.T) {
+	names := []string{"Cryptographic Algorithm", "Cryptographic Length"}
+
+	tests := []struct {
+		name     string
+		version  ProtocolVersion
+		expected ttlv.Value
+	}{
+		{
+			name:    "v1",
+			version: ProtocolVersion{ProtocolVersionMajor: 1, ProtocolVersionMinor: 4},
+			expected: ttlv.Value{Tag: kmip14.TagRequestPayload, Value: ttlv.Values{
+				ttlv.Value{Tag: kmip14.TagUniqueIdentifier, Value: "1"},
+				ttlv.Value{Tag: kmip14.TagAttributeName, Value: "Cryptographic Algorithm"},
+				ttlv.Value{Tag: kmip14.TagAttributeName, Value: "Cryptographic Length"},
+			}},
+		},
+		{
+			name:    "v2",
+			version: ProtocolVersion{ProtocolVersionMajor: 2},
+			expected: ttlv.Value{Tag: kmip14.TagRequestPayload, Value: ttlv.Values{
+				ttlv.Value{Tag: kmip14.TagUniqueIdentifier, Value: "1"},
+				ttlv.Value{Tag: tagAttributeReference, Value: ttlv.EnumValue(kmip14.TagCryptographicAlgorithm)},
+				ttlv.Value{Tag: tagAttributeReference, Value: ttlv.EnumValue(kmip14.TagCryptographicLength)},
+			}},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			p := GetAttributesRequestPayload{
+				ProtocolVersion:  tc.version,
+				UniqueIdentifier: "1",
+				AttributeName:    names,
+			}
+
+			b, err := ttlv.Marshal(ttlv.Value{Tag: kmip14.TagRequestPayload, Value: &p})
+			require.NoError(t, err)
+
+			expected, err := ttlv.Marshal(tc.expected)
+			require.NoError(t, err)
+			assert.Equal(t, expected, b)
+
+			var decoded GetAttributesRequestPayload
+			require.NoError(t, ttlv.Unmarshal(b, &decoded))
+			assert.Equal(t, "1", decoded.UniqueIdentifier)
+			assert.Equal(t, names, decoded.AttributeName)
+		})
+	}
+
+	t.Run("unregistered", func(t *testing.T) {
+		p := GetAttributesRequestPayload{
+			ProtocolVersion: ProtocolVersion{ProtocolVersionMajor: 2},
+			AttributeName:   []string{"x-custom"},
+		}
+		_, err := ttlv.Marshal(&p)
+		require.Error(t, err)
+	})
+}
+
+func TestGetAttributesResponsePayload_marshal(t *testing.T) {
+	attrs := []Attribute{
+		{AttributeName: "Cryptographic Algorithm", AttributeValue: ttlv.EnumValue(kmip14.CryptographicAlgorithmAES)},
+		{AttributeName: "Cryptographic Length", AttributeValue: int32(256)},
+	}
+
+	for _, version := range []ProtocolVersion{{ProtocolVersionMajor: 1, ProtocolVersionMinor: 4}, {ProtocolVersionMajor: 2}} {
+		p := GetAttributesResponsePayload{
+			ProtocolVersion:  version,
+			UniqueIdentifier: "1",
+			Attribute:        attrs,
+		}
+
+		b, err := ttlv.Marshal(ttlv.Value{Tag: kmip14.TagResponsePayload, Value: &p})
+		require.NoError(t, err)
+
+		var decoded GetAttributesResponsePayload
+		require.NoError(t, ttlv.Unmarshal(b, &decoded))
+		assert.Equal(t, "1", decoded.UniqueIdentifier)
+		assert.Equal(t, attrs, decoded.Attribute)
+	}
+}
+
+func TestGetAttributesResponsePayload_order(t *testing.T) {
+	// the server's order, with a multi-instance attribute split up
+	attrs := []Attribute{
+		{AttributeName: "Object Group", AttributeValue: "b"},
+		{AttributeName: "Cryptographic Length", AttributeValue: int32(256)},
+		{AttributeName: "Object Group", AttributeIndex: 1, AttributeValue: "a"},
+		{AttributeName: "Cryptographic Algorithm", AttributeValue: ttlv.EnumValue(kmip14.CryptographicAlgorithmAES)},
+	}
+
+	for _, version := range []ProtocolVersion{{ProtocolVersionMajor: 1, ProtocolVersionMinor: 4}, {ProtocolVersionMajor: 2}} {
+		b, err := ttlv.Marshal(ttlv.Value{Tag: kmip14.TagResponsePayload, Value: &GetAttributesResponsePayload{
+			ProtocolVersion:  version,
+			UniqueIdentifier: "1",
+			Attribute:        attrs,
+		}})
+		require.NoError(t, err)
+
+		var p GetAttributesResponsePayload
+		require.NoError(t, ttlv.Unmarshal(b, &p))
+
+		// the server's order and indexes are kept
+		assert.Equal(t, attrs, p.Attribute)
+		assert.Equal(t, []Attribute{attrs[0], attrs[2]}, p.GetAll("Object Group"))
+		assert.Equal(t, &attrs[1], p.Get("Cryptographic Length"))
+		assert.Nil(t, p.Get("State"))
+
+		assert.Equal(t, []Attribute{attrs[3], attrs[1], attrs[0], attrs[2]}, p.Sorted())
+		assert.Equal(t, attrs, p.Attribute)
+	}
+}
@@ -0,0 +1,55 @@
+package kmip
+
+import (
+	"context"
+
+	"github.com/gemalto/kmip-go/kmip14"
+)
+
+// 4.9
+//
+// This operation requests that the server search for one or more Managed Objects, depending on the
+// attributes specified in the request.  All attributes are allowed to be used.  The request MAY contain
+// a Maximum Items field, which specifies the maximum number of objects to be returned, and an Offset
+// Items field, which specifies the number of matching objects to skip.
+
+// LocateRequestPayload 4.9
+//
+// StorageStatusMask selects whether on-line objects, archived objects, or both are searched.  If it
+// is omitted, only on-line objects are searched, so it must include StorageStatusMaskArchivalStorage
+// to find objects which have been archived with the Archive operation.
+type LocateRequestPayload struct {
+	MaximumItems      int                      `ttlv:",omitempty"`
+	OffsetItems       int                      `ttlv:",omitempty"`
+	StorageStatusMask kmip14.StorageStatusMask `ttlv:",omitempty"`
+	ObjectGroupMember kmip14.ObjectGroupMember `ttlv:",omitempty"`
+	Attribute         []Attribute
+}
+
+// LocateResponsePayload 4.9
+type LocateResponsePayload struct {
+	LocatedItems     int `ttlv:",omitempty"`
+	UniqueIdentifier []string
+}
+
+type LocateHandler struct {
+	Locate func(ctx context.Context, payload *LocateRequestPayload) (*LocateResponsePayload, error)
+}
+
+func (h *LocateHandler) HandleItem(ctx context.Context, req *Request) (*ResponseBatchItem, error) {
+	var payload LocateRequestPayload
+
+	err := req.DecodePayload(&payload)
+	if err != nil {
+		return nil, err
+	}
+
+	respPayload, err := h.Locate(ctx, &payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ResponseBatchItem{
+		ResponsePayload: respPayload,
+	}, nil
+}
@@ -0,0 +1,42 @@
+package kmip
+
+import (
+	"context"
+)
+
+// 5.1
+//
+// This operation is used to notify a client of events that resulted in changes to attributes of an
+// object. This operation is only ever sent by a server to a client via unsolicited messages after the
+// client and server have agreed, e.g. by registering the client for notifications, that the server
+// may push messages to the client.
+//
+// Since Notify is sent from the server to the client as a RequestMessage, a client receiving
+// notifications handles them the same way a server handles requests, e.g. by registering
+// a NotifyHandler with an OperationMux.  The client responds with an empty response payload.
+
+// NotifyRequestPayload 5.1 Table 288
+type NotifyRequestPayload struct {
+	UniqueIdentifier string
+	Attribute        []Attribute
+}
+
+type NotifyHandler struct {
+	Notify func(ctx context.Context, payload *NotifyRequestPayload) error
+}
+
+func (h *NotifyHandler) HandleItem(ctx context.Context, req *Request) (*ResponseBatchItem, error) {
+	var payload NotifyRequestPayload
+
+	err := req.DecodePayload(&payload)
+	if err != nil {
+		return nil, err
+	}
+
+	err = h.Notify(ctx, &payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ResponseBatchItem{}, nil
+}
@@ -0,0 +1,104 @@
+package kmip
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/gemalto/kmip-go/kmip14"
+	"github.com/gemalto/kmip-go/ttlv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotifyPutHandlers(t *testing.T) {
+	notify := NotifyRequestPayload{
+		UniqueIdentifier: "1",
+		Attribute: []Attribute{
+			NewAttributeFromTag(kmip14.TagState, 0, kmip14.StateDeactivated),
+			NewAttributeFromTag(kmip14.TagObjectGroup, 0, "group1"),
+		},
+	}
+
+	put := PutRequestPayload{
+		UniqueIdentifier:         "2",
+		PutFunction:              kmip14.PutFunctionReplace,
+		ReplacedUniqueIdentifier: "1",
+		OpaqueObject:             &OpaqueObject{OpaqueDataType: kmip14.OpaqueDataType(0x80000001), OpaqueDataValue: []byte{1, 2, 3}},
+		Attribute: []Attribute{
+			NewAttributeFromTag(kmip14.TagObjectGroup, 0, "group1"),
+		},
+	}
+
+	// payloads round trip
+	b, err := ttlv.Marshal(ttlv.Value{Tag: kmip14.TagRequestPayload, Value: &notify})
+	require.NoError(t, err)
+
+	var notifyDecoded NotifyRequestPayload
+	require.NoError(t, ttlv.Unmarshal(b, &notifyDecoded))
+	assert.Equal(t, "1", notifyDecoded.UniqueIdentifier)
+	require.Len(t, notifyDecoded.Attribute, 2)
+	assert.Equal(t, ttlv.EnumValue(kmip14.StateDeactivated), notifyDecoded.Attribute[0].AttributeValue)
+	assert.Equal(t, "group1", notifyDecoded.Attribute[1].AttributeValue)
+
+	b, err = ttlv.Marshal(ttlv.Value{Tag: kmip14.TagRequestPayload, Value: &put})
+	require.NoError(t, err)
+
+	var putDecoded PutRequestPayload
+	require.NoError(t, ttlv.Unmarshal(b, &putDecoded))
+	assert.Equal(t, put, putDecoded)
+
+	// a new object has no replaced unique identifier
+	b, err = ttlv.Marshal(ttlv.Value{Tag: kmip14.TagRequestPayload, Value: &PutRequestPayload{
+		UniqueIdentifier: "3",
+		PutFunction:      kmip14.PutFunctionNew,
+		OpaqueObject:     put.OpaqueObject,
+	}})
+	require.NoError(t, err)
+	assert.Nil(t, b.Get(kmip14.TagReplacedUniqueIdentifier))
+
+	// clients handle pushed messages like servers handle requests
+	var notified []NotifyRequestPayload
+
+	var putReceived []PutRequestPayload
+
+	notifyHandler := &NotifyHandler{
+		Notify: func(ctx context.Context, payload *NotifyRequestPayload) error {
+			notified = append(notified, *payload)
+
+			return nil
+		},
+	}
+	putHandler := &PutHandler{
+		Put: func(ctx context.Context, payload *PutRequestPayload) error {
+			if payload.PutFunction == kmip14.PutFunctionReplace && payload.ReplacedUniqueIdentifier == "" {
+				return WithResultReason(errors.New("missing replaced unique identifier"), kmip14.ResultReasonMissingData)
+			}
+
+			putReceived = append(putReceived, *payload)
+
+			return nil
+		},
+	}
+
+	bi := roundTripItem(t, kmip14.OperationNotify, notifyHandler, notify)
+	require.NoError(t, bi.Err())
+	assert.Equal(t, kmip14.OperationNotify, bi.Operation)
+	require.Len(t, notified, 1)
+	assert.Equal(t, notifyDecoded, notified[0])
+
+	bi = roundTripItem(t, kmip14.OperationPut, putHandler, put)
+	require.NoError(t, bi.Err())
+	assert.Equal(t, kmip14.OperationPut, bi.Operation)
+	require.Len(t, putReceived, 1)
+	assert.Equal(t, put, putReceived[0])
+
+	put.ReplacedUniqueIdentifier = ""
+	bi = roundTripItem(t, kmip14.OperationPut, putHandler, put)
+
+	var itemErr *ItemError
+
+	require.True(t, errors.As(bi.Err(), &itemErr), "got %v", bi.Err())
+	assert.Equal(t, kmip14.ResultReasonMissingData, itemErr.ResultReason)
+	assert.Len(t, putReceived, 1)
+}
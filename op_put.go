@@ -0,0 +1,60 @@
+package kmip
+
+import (
+	"context"
+
+	"github.com/gemalto/kmip-go/kmip14"
+)
+
+// 5.2
+//
+// This operation is used to "push" Managed Objects to clients. This operation is only ever sent by a
+// server to a client via unsolicited messages after the client and server have agreed on the use of this
+// operation.
+//
+// The Put Function field indicates whether the object being "pushed" is a new object, or is a replacement
+// for an object already known to the client (e.g., when pushing a certificate to replace one that is about
+// to expire, the Put Function field would be set to indicate replacement, and the Unique Identifier of the
+// expiring certificate would be placed in the Replaced Unique Identifier field).
+//
+// Like Notify, Put is sent from the server to the client as a RequestMessage, and can be handled
+// by registering a PutHandler with an OperationMux.
+
+// PutRequestPayload 5.2 Table 290
+//
+// Exactly one of the object fields should be set.
+type PutRequestPayload struct {
+	UniqueIdentifier         string
+	PutFunction              kmip14.PutFunction
+	ReplacedUniqueIdentifier string `ttlv:",omitempty"`
+	Certificate              *Certificate
+	SymmetricKey             *SymmetricKey
+	PrivateKey               *PrivateKey
+	PublicKey                *PublicKey
+	SplitKey                 *SplitKey
+	Template                 *Template
+	SecretData               *SecretData
+	OpaqueObject             *OpaqueObject
+	PGPKey                   *PGPKey
+	Attribute                []Attribute
+}
+
+type PutHandler struct {
+	Put func(ctx context.Context, payload *PutRequestPayload) error
+}
+
+func (h *PutHandler) HandleItem(ctx context.Context, req *Request) (*ResponseBatchItem, error) {
+	var payload PutRequestPayload
+
+	err := req.DecodePayload(&payload)
+	if err != nil {
+		return nil, err
+	}
+
+	err = h.Put(ctx, &payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ResponseBatchItem{}, nil
+}
@@ -0,0 +1,92 @@
+package kmip
+
+import (
+	"context"
+
+	"github.com/gemalto/kmip-go/kmip14"
+	"github.com/gemalto/kmip-go/ttlv"
+)
+
+// 4.25
+//
+// This operation is used by the client to interrogate the server to determine its capabilities and/or
+// protocol mechanisms. The Query operation SHOULD be invocable by unauthenticated clients to interrogate
+// server features and functions.
+//
+// The Query Function field in the request SHALL contain one or more of the following items:
+//
+// · Query Operations
+// · Query Objects
+// · Query Server Information
+// · Query Application Namespaces
+// · Query Extension List
+// · Query Extension Map
+// · Query Attestation Types
+// · Query RNGs
+// · Query Validations
+// · Query Profiles
+// · Query Capabilities
+// · Query Client Registration Methods
+
+// QueryRequestPayload 4.25
+type QueryRequestPayload struct {
+	QueryFunction []kmip14.QueryFunction
+}
+
+// QueryResponsePayload 4.25
+//
+// Fields of the response which aren't modeled here yet are ignored when unmarshaling.
+//
+// The contents of the Server Information structure are defined by the vendor, so it is kept
+// as the raw TTLV value, whatever it contains, and re-encoded as is.  See DecodeServerInformation().
+type QueryResponsePayload struct {
+	Operation             []kmip14.Operation
+	ObjectType            []kmip14.ObjectType
+	VendorIdentification  string    `ttlv:",omitempty"`
+	ServerInformation     ttlv.TTLV `ttlv:",omitempty"`
+	ApplicationNamespace  []string
+	ExtensionInformation  []ExtensionInformation
+	AttestationType       []kmip14.AttestationType
+	ProfileInformation    []ProfileInformation
+	ValidationInformation []ValidationInformation
+	CapabilityInformation []CapabilityInformation
+}
+
+// RegisterExtensions registers the tags described by the ExtensionInformation in the response
+// with the registry, so those tags can be formatted and parsed by name.  See RegisterExtensions().
+func (p *QueryResponsePayload) RegisterExtensions(registry *ttlv.Registry) {
+	RegisterExtensions(registry, p.ExtensionInformation)
+}
+
+// DecodeServerInformation unmarshals the vendor-defined Server Information structure into v,
+// e.g. a pointer to a vendor-specific struct.  v is left unchanged if the response has no
+// Server Information.
+func (p *QueryResponsePayload) DecodeServerInformation(v interface{}) error {
+	if len(p.ServerInformation) == 0 {
+		return nil
+	}
+
+	return ttlv.Unmarshal(p.ServerInformation, v)
+}
+
+type QueryHandler struct {
+	Query func(ctx context.Context, payload *QueryRequestPayload) (*QueryResponsePayload, error)
+}
+
+func (h *QueryHandler) HandleItem(ctx context.Context, req *Request) (*ResponseBatchItem, error) {
+	var payload QueryRequestPayload
+
+	err := req.DecodePayload(&payload)
+	if err != nil {
+		return nil, err
+	}
+
+	respPayload, err := h.Query(ctx, &payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ResponseBatchItem{
+		ResponsePayload: respPayload,
+	}, nil
+}
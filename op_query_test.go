@@ -0,0 +1,208 @@
+package kmip
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gemalto/kmip-go/kmip14"
+	"github.com/gemalto/kmip-go/ttlv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterExtensions(t *testing.T) {
+	resp, err := ttlv.Marshal(ttlv.NewStruct(kmip14.TagResponsePayload,
+		ttlv.NewStruct(kmip14.TagExtensionInformation,
+			ttlv.NewValue(kmip14.TagExtensionName, "ACME Widget"),
+			ttlv.NewValue(kmip14.TagExtensionTag, 0x540001),
+			ttlv.NewValue(kmip14.TagExtensionType, int(ttlv.TypeTextString)),
+		),
+		ttlv.NewStruct(kmip14.TagExtensionInformation,
+			ttlv.NewValue(kmip14.TagExtensionName, "ACME Listed Only"),
+		),
+	))
+	require.NoError(t, err)
+
+	var payload QueryResponsePayload
+	err = ttlv.Unmarshal(resp, &payload)
+	require.NoError(t, err)
+
+	require.Len(t, payload.ExtensionInformation, 2)
+	assert.Equal(t, ExtensionInformation{
+		ExtensionName: "ACME Widget",
+		ExtensionTag:  ttlv.Tag(0x540001),
+		ExtensionType: ttlv.TypeTextString,
+	}, payload.ExtensionInformation[0])
+
+	var r ttlv.Registry
+	payload.RegisterExtensions(&r)
+
+	assert.Equal(t, "ACMEWidget", r.FormatTag(0x540001))
+	assert.Equal(t, "ACME Widget", r.FormatTagCanonical(0x540001))
+
+	tag, err := r.ParseTag("ACMEWidget")
+	require.NoError(t, err)
+	assert.Equal(t, ttlv.Tag(0x540001), tag)
+
+	assert.Len(t, r.Tags().Values(), 1)
+}
+
+func TestQueryResponsePayload_profilesAndCapabilities(t *testing.T) {
+	resp, err := ttlv.Marshal(s(kmip14.TagResponsePayload,
+		s(kmip14.TagProfileInformation,
+			v(kmip14.TagProfileName, kmip14.ProfileNameBaselineServerBasicKMIPV1_2),
+		),
+		s(kmip14.TagProfileInformation,
+			v(kmip14.TagProfileName, kmip14.ProfileNameCompleteServerTLSV1_2KMIPV1_2),
+			v(kmip14.TagServerURI, "https://kmip.example.com"),
+			v(kmip14.TagServerPort, 5696),
+			s(tagProfileVersion,
+				v(tagProfileVersionMajor, 2),
+				v(tagProfileVersionMinor, 0),
+			),
+		),
+		s(kmip14.TagCapabilityInformation,
+			v(kmip14.TagStreamingCapability, true),
+			v(kmip14.TagBatchUndoCapability, true),
+			v(kmip14.TagUnwrapMode, kmip14.UnwrapModeProcessed),
+			v(kmip14.TagRNGMode, kmip14.RNGModeSharedInstantiation),
+		),
+	))
+	require.NoError(t, err)
+
+	var payload QueryResponsePayload
+	err = ttlv.Unmarshal(resp, &payload)
+	require.NoError(t, err)
+
+	assert.Equal(t, []ProfileInformation{
+		{ProfileName: kmip14.ProfileNameBaselineServerBasicKMIPV1_2},
+		{
+			ProfileName:    kmip14.ProfileNameCompleteServerTLSV1_2KMIPV1_2,
+			ServerURI:      "https://kmip.example.com",
+			ServerPort:     5696,
+			ProfileVersion: &ProfileVersion{ProfileVersionMajor: 2},
+		},
+	}, payload.ProfileInformation)
+	assert.Equal(t, []CapabilityInformation{
+		{
+			StreamingCapability: true,
+			BatchUndoCapability: true,
+			UnwrapMode:          kmip14.UnwrapModeProcessed,
+			RNGMode:             kmip14.RNGModeSharedInstantiation,
+		},
+	}, payload.CapabilityInformation)
+
+	b, err := ttlv.Marshal(ttlv.Value{Tag: kmip14.TagResponsePayload, Value: &payload})
+	require.NoError(t, err)
+	assert.Equal(t, resp, b)
+}
+
+func TestQueryResponsePayload_validations(t *testing.T) {
+	resp, err := ttlv.Marshal(s(kmip14.TagResponsePayload,
+		s(kmip14.TagValidationInformation,
+			v(kmip14.TagValidationAuthorityType, kmip14.ValidationAuthorityTypeNISTCMVP),
+			v(kmip14.TagValidationAuthorityCountry, "US"),
+			v(kmip14.TagValidationVersionMajor, 140),
+			v(kmip14.TagValidationVersionMinor, 2),
+			v(kmip14.TagValidationType, kmip14.ValidationTypeHardware),
+			v(kmip14.TagValidationLevel, 3),
+			v(kmip14.TagValidationCertificateIdentifier, "1234"),
+			v(kmip14.TagValidationProfile, "FIPS 140-2"),
+			v(kmip14.TagValidationProfile, "FIPS 140-2 Level 3"),
+		),
+		s(kmip14.TagValidationInformation,
+			v(kmip14.TagValidationAuthorityType, kmip14.ValidationAuthorityTypeCommonCriteria),
+			v(kmip14.TagValidationVersionMajor, 3),
+			v(kmip14.TagValidationType, kmip14.ValidationTypeSoftware),
+			v(kmip14.TagValidationLevel, 4),
+		),
+	))
+	require.NoError(t, err)
+	require.NoError(t, ttlv.DefaultRegistry.ValidateStructure(resp))
+	require.NoError(t, ttlv.DefaultRegistry.ValidateOrder(resp))
+
+	var payload QueryResponsePayload
+	require.NoError(t, ttlv.Unmarshal(resp, &payload))
+
+	assert.Equal(t, []ValidationInformation{
+		{
+			ValidationAuthorityType:         kmip14.ValidationAuthorityTypeNISTCMVP,
+			ValidationAuthorityCountry:      "US",
+			ValidationVersionMajor:          140,
+			ValidationVersionMinor:          2,
+			ValidationType:                  kmip14.ValidationTypeHardware,
+			ValidationLevel:                 3,
+			ValidationCertificateIdentifier: "1234",
+			ValidationProfile:               []string{"FIPS 140-2", "FIPS 140-2 Level 3"},
+		},
+		{
+			ValidationAuthorityType: kmip14.ValidationAuthorityTypeCommonCriteria,
+			ValidationVersionMajor:  3,
+			ValidationType:          kmip14.ValidationTypeSoftware,
+			ValidationLevel:         4,
+		},
+	}, payload.ValidationInformation)
+
+	b, err := ttlv.Marshal(ttlv.Value{Tag: kmip14.TagResponsePayload, Value: &payload})
+	require.NoError(t, err)
+	assert.Equal(t, resp, b)
+
+	// the required values are registered
+	missing, err := ttlv.Marshal(s(kmip14.TagValidationInformation,
+		v(kmip14.TagValidationAuthorityType, kmip14.ValidationAuthorityTypeNISTCMVP),
+	))
+	require.NoError(t, err)
+
+	err = ttlv.DefaultRegistry.ValidateStructure(missing)
+	require.True(t, errors.Is(err, ttlv.ErrMissingRequiredValue), Details(err))
+}
+
+func TestQueryResponsePayload_serverInformation(t *testing.T) {
+	resp, err := ttlv.Marshal(s(kmip14.TagResponsePayload,
+		v(kmip14.TagOperation, kmip14.OperationQuery),
+		v(kmip14.TagVendorIdentification, "acme"),
+		s(kmip14.TagServerInformation,
+			v(ttlv.Tag(0x540001), "HSM 9000"),
+			s(ttlv.Tag(0x540002),
+				v(ttlv.Tag(0x540003), 7),
+			),
+			v(ttlv.Tag(0x540004), []byte{1, 2, 3}),
+		),
+		s(kmip14.TagValidationInformation,
+			v(kmip14.TagValidationAuthorityType, kmip14.ValidationAuthorityTypeCommonCriteria),
+			v(kmip14.TagValidationVersionMajor, 3),
+			v(kmip14.TagValidationType, kmip14.ValidationTypeSoftware),
+			v(kmip14.TagValidationLevel, 4),
+		),
+	))
+	require.NoError(t, err)
+
+	var payload QueryResponsePayload
+	require.NoError(t, ttlv.Unmarshal(resp, &payload))
+
+	require.Equal(t, kmip14.TagServerInformation, payload.ServerInformation.Tag())
+	require.NoError(t, payload.ServerInformation.Valid())
+	require.Len(t, payload.ValidationInformation, 1)
+
+	var info struct {
+		Model  string `ttlv:"0x540001"`
+		Status struct {
+			Code int `ttlv:"0x540003"`
+		} `ttlv:"0x540002"`
+	}
+
+	require.NoError(t, payload.DecodeServerInformation(&info))
+	assert.Equal(t, "HSM 9000", info.Model)
+	assert.Equal(t, 7, info.Status.Code)
+
+	// re-encoded as is
+	b, err := ttlv.Marshal(ttlv.Value{Tag: kmip14.TagResponsePayload, Value: &payload})
+	require.NoError(t, err)
+	assert.Equal(t, resp, b)
+
+	// absent
+	payload = QueryResponsePayload{}
+	info.Model = "unchanged"
+	require.NoError(t, payload.DecodeServerInformation(&info))
+	assert.Equal(t, "unchanged", info.Model)
+}
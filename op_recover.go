@@ -0,0 +1,43 @@
+package kmip
+
+import (
+	"context"
+)
+
+// 4.23
+//
+// This operation is used to obtain access to a Managed Object that has been archived.  The request
+// may need asynchronous polling to obtain the response, due to delays caused by retrieving the
+// object from the archive.  Once recovered, the object's Storage Status is On-line Storage again.
+
+// RecoverRequestPayload ////////////////////////////////////////
+type RecoverRequestPayload struct {
+	UniqueIdentifier string `ttlv:",omitempty"`
+}
+
+// RecoverResponsePayload
+type RecoverResponsePayload struct {
+	UniqueIdentifier string
+}
+
+type RecoverHandler struct {
+	Recover func(ctx context.Context, payload *RecoverRequestPayload) (*RecoverResponsePayload, error)
+}
+
+func (h *RecoverHandler) HandleItem(ctx context.Context, req *Request) (*ResponseBatchItem, error) {
+	var payload RecoverRequestPayload
+
+	err := req.DecodePayload(&payload)
+	if err != nil {
+		return nil, err
+	}
+
+	respPayload, err := h.Recover(ctx, &payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ResponseBatchItem{
+		ResponsePayload: respPayload,
+	}, nil
+}
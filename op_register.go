@@ -2,9 +2,11 @@ package kmip
 
 import (
 	"context"
+	"strings"
 
 	"github.com/ansel1/merry"
 	"github.com/gemalto/kmip-go/kmip14"
+	"github.com/gemalto/kmip-go/ttlv"
 )
 
 // 4.3
@@ -22,6 +24,108 @@ type RegisterRequestPayload struct {
 	Template          *Template
 	SecretData        *SecretData
 	OpaqueObject      *OpaqueObject
+	PGPKey            *PGPKey
+}
+
+// Object returns the managed object field which corresponds to ObjectType, e.g. SymmetricKey
+// if ObjectType is ObjectTypeSymmetricKey.  Returns nil if that field isn't set, or the
+// ObjectType is not recognized.
+func (p *RegisterRequestPayload) Object() interface{} {
+	switch p.ObjectType {
+	case kmip14.ObjectTypeCertificate:
+		if p.Certificate != nil {
+			return p.Certificate
+		}
+	case kmip14.ObjectTypeSymmetricKey:
+		if p.SymmetricKey != nil {
+			return p.SymmetricKey
+		}
+	case kmip14.ObjectTypePrivateKey:
+		if p.PrivateKey != nil {
+			return p.PrivateKey
+		}
+	case kmip14.ObjectTypePublicKey:
+		if p.PublicKey != nil {
+			return p.PublicKey
+		}
+	case kmip14.ObjectTypeSplitKey:
+		if p.SplitKey != nil {
+			return p.SplitKey
+		}
+	case kmip14.ObjectTypeTemplate:
+		if p.Template != nil {
+			return p.Template
+		}
+	case kmip14.ObjectTypeSecretData:
+		if p.SecretData != nil {
+			return p.SecretData
+		}
+	case kmip14.ObjectTypeOpaqueObject:
+		if p.OpaqueObject != nil {
+			return p.OpaqueObject
+		}
+	case kmip14.ObjectTypePGPKey:
+		if p.PGPKey != nil {
+			return p.PGPKey
+		}
+	}
+
+	return nil
+}
+
+// Validate checks that the payload is consistent before it's sent:
+//
+//   - ObjectType is required, and the managed object field which corresponds to it must be set.
+//
+// As with CreateRequestPayload.Validate, the attributes aren't checked against the ObjectType
+// unless TemplateAttribute.ValidateObjectAttributes() is called too.
+//
+// Errors carry a ResultReason (see GetResultReason()), so handlers may also use Validate to
+// check requests.  The protocol version is accepted for symmetry with CreateRequestPayload.Validate,
+// but the checks are the same for all versions.
+func (p *RegisterRequestPayload) Validate(_ ProtocolVersion) error {
+	if p.ObjectType == 0 {
+		return WithResultReason(merry.UserError("missing Object Type"), kmip14.ResultReasonMissingData)
+	}
+
+	if p.Object() == nil {
+		return WithResultReason(merry.UserErrorf("missing %s object", p.ObjectType.String()), kmip14.ResultReasonMissingData)
+	}
+
+	return nil
+}
+
+// ValidateObjectAttributes checks that each of the attributes applies to objectType, according
+// to the DefaultRegistry (see ttlv.Registry.AttributeApplies()).  Attributes whose names aren't
+// registered tags, like custom attributes, are not checked.  The error carries ResultReasonInvalidField.
+//
+// Payloads' Validate methods don't make this check, so it's opt-in, e.g.:
+//
+//	err := p.TemplateAttribute.ValidateObjectAttributes(p.ObjectType)
+func (t *TemplateAttribute) ValidateObjectAttributes(objectType kmip14.ObjectType) error {
+	if t == nil {
+		return nil
+	}
+
+	var invalid []string
+
+	for _, a := range t.Attribute {
+		tag, err := ttlv.DefaultRegistry.ParseTag(ttlv.NormalizeName(a.AttributeName))
+		if err != nil {
+			continue
+		}
+
+		if !ttlv.DefaultRegistry.AttributeApplies(uint32(objectType), tag) {
+			invalid = append(invalid, a.AttributeName)
+		}
+	}
+
+	if len(invalid) > 0 {
+		return WithResultReason(merry.UserErrorf("attributes do not apply to %s: %s", objectType.String(), strings.Join(invalid, ", ")),
+			kmip14.ResultReasonInvalidField)
+	}
+
+	return nil
 }
 
 // Table 170
@@ -45,30 +149,15 @@ func (h *RegisterHandler) HandleItem(ctx context.Context, req *Request) (item *R
 	}
 
 	if !h.SkipValidation {
-		var payloadPresent bool
-
 		switch payload.ObjectType {
 		default:
 			return nil, WithResultReason(merry.UserError("Object Type is not recognized"), kmip14.ResultReasonInvalidField)
-		case kmip14.ObjectTypeCertificate:
-			payloadPresent = payload.Certificate != nil
-		case kmip14.ObjectTypeSymmetricKey:
-			payloadPresent = payload.SymmetricKey != nil
-		case kmip14.ObjectTypePrivateKey:
-			payloadPresent = payload.PrivateKey != nil
-		case kmip14.ObjectTypePublicKey:
-			payloadPresent = payload.PublicKey != nil
-		case kmip14.ObjectTypeSplitKey:
-			payloadPresent = payload.SplitKey != nil
-		case kmip14.ObjectTypeTemplate:
-			payloadPresent = payload.Template != nil
-		case kmip14.ObjectTypeSecretData:
-			payloadPresent = payload.SecretData != nil
-		case kmip14.ObjectTypeOpaqueObject:
-			payloadPresent = payload.OpaqueObject != nil
+		case kmip14.ObjectTypeCertificate, kmip14.ObjectTypeSymmetricKey, kmip14.ObjectTypePrivateKey,
+			kmip14.ObjectTypePublicKey, kmip14.ObjectTypeSplitKey, kmip14.ObjectTypeTemplate,
+			kmip14.ObjectTypeSecretData, kmip14.ObjectTypeOpaqueObject, kmip14.ObjectTypePGPKey:
 		}
 
-		if !payloadPresent {
+		if payload.Object() == nil {
 			return nil, WithResultReason(merry.UserErrorf("Object Type %s does not match type of cryptographic object provided", payload.ObjectType.String()), kmip14.ResultReasonInvalidField)
 		}
 	}
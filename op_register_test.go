@@ -0,0 +1,45 @@
+package kmip
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gemalto/kmip-go/kmip14"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterRequestPayload_Validate(t *testing.T) {
+	v14 := ProtocolVersion{ProtocolVersionMajor: 1, ProtocolVersionMinor: 4}
+
+	p := RegisterRequestPayload{
+		ObjectType:  kmip14.ObjectTypeCertificate,
+		Certificate: &Certificate{CertificateType: kmip14.CertificateTypeX_509, CertificateValue: []byte{1}},
+	}
+	p.TemplateAttribute.Append(kmip14.TagCryptographicUsageMask, kmip14.CryptographicUsageMaskVerify)
+	p.TemplateAttribute.Append(kmip14.TagName, NewName("cert"))
+	p.TemplateAttribute.Attribute = append(p.TemplateAttribute.Attribute, Attribute{AttributeName: "x-custom", AttributeValue: "a"})
+	require.NoError(t, p.Validate(v14))
+	require.NoError(t, p.TemplateAttribute.ValidateObjectAttributes(p.ObjectType))
+
+	p.TemplateAttribute.Append(kmip14.TagProcessStartDate, time.Now())
+	p.TemplateAttribute.Append(kmip14.TagCryptographicDomainParameters, CryptographicDomainParameters{Qlength: 256})
+	require.NoError(t, p.Validate(v14))
+
+	err := p.TemplateAttribute.ValidateObjectAttributes(p.ObjectType)
+	require.EqualError(t, err, "attributes do not apply to Certificate: Process Start Date, Cryptographic Domain Parameters")
+	assert.Equal(t, kmip14.ResultReasonInvalidField, GetResultReason(err))
+
+	// templates hold attributes for any type of object
+	p.ObjectType, p.Template = kmip14.ObjectTypeTemplate, &Template{}
+	require.NoError(t, p.TemplateAttribute.ValidateObjectAttributes(p.ObjectType))
+
+	// secret data may have cryptographic parameters
+	secret := TemplateAttribute{}
+	secret.Append(kmip14.TagCryptographicParameters, CryptographicParameters{BlockCipherMode: kmip14.BlockCipherModeGCM})
+	require.NoError(t, secret.ValidateObjectAttributes(kmip14.ObjectTypeSecretData))
+
+	err = (&RegisterRequestPayload{ObjectType: kmip14.ObjectTypeSymmetricKey}).Validate(v14)
+	require.EqualError(t, err, "missing SymmetricKey object")
+	assert.Equal(t, kmip14.ResultReasonMissingData, GetResultReason(err))
+}
@@ -0,0 +1,40 @@
+package kmip
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gemalto/kmip-go/kmip14"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRevokeHandler(t *testing.T) {
+	occurred := time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)
+	reason := RevocationReason{
+		RevocationReasonCode: kmip14.RevocationReasonCodeKeyCompromise,
+		RevocationMessage:    "leaked",
+	}
+
+	handler := &RevokeHandler{
+		Revoke: func(ctx context.Context, payload *RevokeRequestPayload) (*RevokeResponsePayload, error) {
+			assert.Equal(t, "1", payload.UniqueIdentifier)
+			assert.Equal(t, reason, payload.RevocationReason)
+			assert.True(t, payload.RevocationReason.Compromised())
+			assert.True(t, occurred.Equal(payload.CompromiseOccurrenceDate))
+
+			return &RevokeResponsePayload{UniqueIdentifier: payload.UniqueIdentifier}, nil
+		},
+	}
+
+	bi := roundTripItem(t, kmip14.OperationRevoke, handler, RevokeRequestPayload{
+		UniqueIdentifier:         "1",
+		RevocationReason:         reason,
+		CompromiseOccurrenceDate: occurred,
+	})
+
+	var respPayload RevokeResponsePayload
+	require.NoError(t, bi.DecodePayload(&respPayload))
+	assert.Equal(t, "1", respPayload.UniqueIdentifier)
+}
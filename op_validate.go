@@ -0,0 +1,54 @@
+package kmip
+
+import (
+	"context"
+	"time"
+
+	"github.com/gemalto/kmip-go/kmip14"
+)
+
+// 4.24
+//
+// This operation requests that the server validate a certificate chain and return information on its
+// validity.  Only a single certificate chain SHALL be included in each request.  The certificate chain
+// may be given as certificates in the request, as the Unique Identifiers of certificates managed by the
+// server, or both.  The request may also include a date and time at which the chain should be valid.
+// If it is omitted, the current date and time is assumed.
+//
+// The Validity Indicator in the response is Valid, Invalid, or Unknown.
+
+// ValidateRequestPayload 4.24
+type ValidateRequestPayload struct {
+	Certificate      []Certificate
+	UniqueIdentifier []string
+	ValidityDate     time.Time `ttlv:",omitempty"`
+}
+
+// ValidateResponsePayload 4.24
+type ValidateResponsePayload struct {
+	ValidityIndicator kmip14.ValidityIndicator
+}
+
+// ValidateHandler handles Validate requests.  It is named after the operation: it doesn't
+// validate the request itself.
+type ValidateHandler struct {
+	Validate func(ctx context.Context, payload *ValidateRequestPayload) (*ValidateResponsePayload, error)
+}
+
+func (h *ValidateHandler) HandleItem(ctx context.Context, req *Request) (*ResponseBatchItem, error) {
+	var payload ValidateRequestPayload
+
+	err := req.DecodePayload(&payload)
+	if err != nil {
+		return nil, err
+	}
+
+	respPayload, err := h.Validate(ctx, &payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ResponseBatchItem{
+		ResponsePayload: respPayload,
+	}, nil
+}
@@ -0,0 +1,36 @@
+package kmip
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gemalto/kmip-go/kmip14"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateHandler(t *testing.T) {
+	validityDate := time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	handler := &ValidateHandler{
+		Validate: func(ctx context.Context, payload *ValidateRequestPayload) (*ValidateResponsePayload, error) {
+			assert.Equal(t, []string{"1", "2"}, payload.UniqueIdentifier)
+			assert.Equal(t, []Certificate{{CertificateType: kmip14.CertificateTypeX_509, CertificateValue: []byte{1, 2}}}, payload.Certificate)
+			assert.True(t, validityDate.Equal(payload.ValidityDate))
+
+			return &ValidateResponsePayload{ValidityIndicator: kmip14.ValidityIndicatorInvalid}, nil
+		},
+	}
+
+	bi := roundTripItem(t, kmip14.OperationValidate, handler, ValidateRequestPayload{
+		Certificate:      []Certificate{{CertificateType: kmip14.CertificateTypeX_509, CertificateValue: []byte{1, 2}}},
+		UniqueIdentifier: []string{"1", "2"},
+		ValidityDate:     validityDate,
+	})
+
+	var respPayload ValidateResponsePayload
+	require.NoError(t, bi.DecodePayload(&respPayload))
+	assert.Equal(t, kmip14.ValidityIndicatorInvalid, respPayload.ValidityIndicator)
+	assert.Equal(t, "Invalid", respPayload.ValidityIndicator.String())
+}
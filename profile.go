@@ -0,0 +1,374 @@
+package kmip
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/ansel1/merry"
+	"github.com/gemalto/kmip-go/kmip14"
+	"github.com/gemalto/kmip-go/ttlv"
+)
+
+// Profile is a machine-readable description of a KMIP profile, e.g. "Baseline Server", listing
+// the protocol versions, operations, object types, and attributes which conformant messages may
+// use.  Values are named as DefaultRegistry parses them, e.g. "Create Key Pair" or "CreateKeyPair",
+// and protocol versions as "1.4".  An empty list doesn't constrain messages.  For example:
+//
+//	{
+//	  "name": "Baseline Server",
+//	  "protocolVersions": ["1.0", "1.1", "1.2", "1.3", "1.4"],
+//	  "operations": ["Locate", "Get", "Get Attributes", "Destroy", "Query", "Discover Versions"],
+//	  "objectTypes": ["Symmetric Key"],
+//	  "attributes": ["Unique Identifier", "Name", "Object Type", "Cryptographic Algorithm"]
+//	}
+type Profile struct {
+	Name             string   `json:"name"`
+	ProtocolVersions []string `json:"protocolVersions,omitempty"`
+	Operations       []string `json:"operations,omitempty"`
+	ObjectTypes      []string `json:"objectTypes,omitempty"`
+	Attributes       []string `json:"attributes,omitempty"`
+}
+
+// ProfileChecker checks that encoded messages conform to a Profile.  Create one with
+// NewProfileChecker() or LoadProfileJSON().
+type ProfileChecker struct {
+	Profile Profile
+
+	versions    map[ProtocolVersion]bool
+	operations  map[kmip14.Operation]bool
+	objectTypes map[kmip14.ObjectType]bool
+	attributes  map[string]bool
+}
+
+// NewProfileChecker returns a ProfileChecker for p.  It returns an error if any of the
+// names in p can't be parsed.  Attribute names must be registered tags, or custom attribute
+// names, which start with "x-" or "y-", otherwise an error wrapping ErrUnknownAttribute is
+// returned.
+func NewProfileChecker(p Profile) (*ProfileChecker, error) {
+	c := ProfileChecker{Profile: p}
+
+	for _, s := range p.ProtocolVersions {
+		v, err := parseProtocolVersion(s)
+		if err != nil {
+			return nil, err
+		}
+
+		if c.versions == nil {
+			c.versions = map[ProtocolVersion]bool{}
+		}
+
+		c.versions[v] = true
+	}
+
+	for _, s := range p.Operations {
+		v, err := ttlv.DefaultRegistry.ParseEnum(kmip14.TagOperation, s)
+		if err != nil {
+			return nil, merry.Prependf(err, "invalid operation %q", s)
+		}
+
+		if c.operations == nil {
+			c.operations = map[kmip14.Operation]bool{}
+		}
+
+		c.operations[kmip14.Operation(v)] = true
+	}
+
+	for _, s := range p.ObjectTypes {
+		v, err := ttlv.DefaultRegistry.ParseEnum(kmip14.TagObjectType, s)
+		if err != nil {
+			return nil, merry.Prependf(err, "invalid object type %q", s)
+		}
+
+		if c.objectTypes == nil {
+			c.objectTypes = map[kmip14.ObjectType]bool{}
+		}
+
+		c.objectTypes[kmip14.ObjectType(v)] = true
+	}
+
+	for _, s := range p.Attributes {
+		name := s
+
+		if !strings.HasPrefix(s, "x-") && !strings.HasPrefix(s, "y-") {
+			t, err := ttlv.DefaultRegistry.ParseTag(ttlv.NormalizeName(s))
+			if err != nil || t == ttlv.TagNone {
+				return nil, merry.Appendf(ErrUnknownAttribute, "%q", s)
+			}
+
+			name = t.CanonicalName()
+		}
+
+		if c.attributes == nil {
+			c.attributes = map[string]bool{}
+		}
+
+		c.attributes[name] = true
+	}
+
+	return &c, nil
+}
+
+// parseProtocolVersion parses a protocol version like "1.4".  Unlike fmt.Sscanf, it rejects
+// anything after the minor version, like "1.4x".
+func parseProtocolVersion(s string) (ProtocolVersion, error) {
+	parts := strings.Split(s, ".")
+	if len(parts) != 2 {
+		return ProtocolVersion{}, merry.Errorf("invalid protocol version %q", s)
+	}
+
+	major, err := strconv.ParseUint(parts[0], 10, 31)
+	if err != nil {
+		return ProtocolVersion{}, merry.Prependf(err, "invalid protocol version %q", s)
+	}
+
+	minor, err := strconv.ParseUint(parts[1], 10, 31)
+	if err != nil {
+		return ProtocolVersion{}, merry.Prependf(err, "invalid protocol version %q", s)
+	}
+
+	return ProtocolVersion{ProtocolVersionMajor: int(major), ProtocolVersionMinor: int(minor)}, nil
+}
+
+// LoadProfileJSON reads a JSON encoded Profile, and returns a ProfileChecker for it.
+func LoadProfileJSON(rd io.Reader) (*ProfileChecker, error) {
+	var p Profile
+
+	if err := json.NewDecoder(rd).Decode(&p); err != nil {
+		return nil, merry.Prepend(err, "decoding profile JSON")
+	}
+
+	return NewProfileChecker(p)
+}
+
+// Check checks an encoded Request Message or Response Message against the profile, and returns
+// every problem found, or nil if there are none:
+//
+//   - The Protocol Version in the header must be one of the profile's.
+//   - The Operation of each batch item must be one of the profile's.
+//   - Object Types, in payload fields or Object Type attributes, must be among the profile's.
+//   - The names of attributes, in either the KMIP 1.x or 2.0 form, must be among the profile's.
+//     See RangeAttributes().
+//   - The header and batch items must pass the DefaultRegistry's ValidateTypes(), ValidateStructure(),
+//     and ValidateOrder() checks.
+//
+// Violations of the profile wrap ErrProfileViolation.  Errors found in a batch item are prefixed
+// with its index, e.g. "BatchItem[1]: ...".  If msg isn't valid TTLV, only the error from Valid()
+// is returned.
+func (c *ProfileChecker) Check(msg ttlv.TTLV) []error {
+	if err := msg.Valid(); err != nil {
+		return []error{err}
+	}
+
+	if msg.Type() != ttlv.TypeStructure {
+		return []error{merry.Errorf("expected a Request Message or Response Message, got %s", msg.Type().String())}
+	}
+
+	var errs []error
+
+	var item int
+
+	for n := msg.ValueStructure(); len(n) > 0; n = n.Next() {
+		n := n[:n.FullLen()]
+
+		switch n.Tag() {
+		case kmip14.TagRequestHeader, kmip14.TagResponseHeader:
+			errs = append(errs, c.checkHeader(n)...)
+		case kmip14.TagBatchItem:
+			for _, err := range c.checkBatchItem(n) {
+				errs = append(errs, merry.Prependf(err, "BatchItem[%d]", item))
+			}
+
+			item++
+		}
+	}
+
+	return errs
+}
+
+func (c *ProfileChecker) checkHeader(t ttlv.TTLV) []error {
+	errs := validateRegistry(t)
+
+	for n := t.ValueStructure(); len(n) > 0; n = n.Next() {
+		if n.Tag() != kmip14.TagProtocolVersion || c.versions == nil {
+			continue
+		}
+
+		var v ProtocolVersion
+		if err := ttlv.Unmarshal(n[:n.FullLen()], &v); err != nil {
+			errs = append(errs, merry.Prepend(err, t.Tag().String()))
+			continue
+		}
+
+		if !c.versions[v] {
+			err := merry.Appendf(ErrProfileViolation, "Protocol Version %d.%d is not in the %s profile",
+				v.ProtocolVersionMajor, v.ProtocolVersionMinor, c.Profile.Name)
+			errs = append(errs, merry.Prepend(err, t.Tag().String()))
+		}
+	}
+
+	return errs
+}
+
+func (c *ProfileChecker) checkBatchItem(t ttlv.TTLV) []error {
+	errs := validateRegistry(t)
+
+	for n := t.ValueStructure(); len(n) > 0; n = n.Next() {
+		if n.Tag() == kmip14.TagOperation && n.Type() == ttlv.TypeEnumeration && c.operations != nil {
+			if op := kmip14.Operation(n.ValueEnumeration()); !c.operations[op] {
+				errs = append(errs, merry.Appendf(ErrProfileViolation, "Operation %s is not in the %s profile",
+					op.String(), c.Profile.Name))
+			}
+		}
+	}
+
+	// the same Object Type may be found in a payload field and an attribute, so each is only reported once
+	var objectTypes []kmip14.ObjectType
+
+	seen := map[kmip14.ObjectType]bool{}
+	addObjectType := func(v ttlv.TTLV) {
+		if v.Type() != ttlv.TypeEnumeration {
+			return
+		}
+
+		if ot := kmip14.ObjectType(v.ValueEnumeration()); !seen[ot] {
+			seen[ot] = true
+			objectTypes = append(objectTypes, ot)
+		}
+	}
+
+	var walk func(t ttlv.TTLV)
+
+	walk = func(t ttlv.TTLV) {
+		for n := t.ValueStructure(); len(n) > 0; n = n.Next() {
+			switch {
+			case n.Tag() == kmip14.TagObjectType:
+				addObjectType(n)
+			case n.Type() == ttlv.TypeStructure:
+				walk(n)
+			}
+		}
+	}
+
+	walk(t)
+
+	objectTypeName := kmip14.TagObjectType.CanonicalName()
+
+	_ = RangeAttributes(t, func(name string, value ttlv.TTLV) bool {
+		if name == objectTypeName {
+			addObjectType(value)
+		}
+
+		if c.attributes != nil && !c.attributes[name] {
+			errs = append(errs, merry.Appendf(ErrProfileViolation, "attribute %q is not in the %s profile", name, c.Profile.Name))
+		}
+
+		return true
+	})
+
+	if c.objectTypes != nil {
+		for _, ot := range objectTypes {
+			if !c.objectTypes[ot] {
+				errs = append(errs, merry.Appendf(ErrProfileViolation, "Object Type %s is not in the %s profile",
+					ot.String(), c.Profile.Name))
+			}
+		}
+	}
+
+	return errs
+}
+
+// validateRegistry returns the errors from the DefaultRegistry's checks of t.
+func validateRegistry(t ttlv.TTLV) []error {
+	var errs []error
+
+	for _, err := range []error{
+		ttlv.DefaultRegistry.ValidateTypes(t),
+		ttlv.DefaultRegistry.ValidateStructure(t),
+		ttlv.DefaultRegistry.ValidateOrder(t),
+	} {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errs
+}
+
+// Exchange is an encoded request message and the server's response to it.
+type Exchange struct {
+	Request  ttlv.TTLV
+	Response ttlv.TTLV
+}
+
+// CheckExchange checks a request message and the server's response to it against the profile.
+// In addition to the checks Check() makes of each message, the response must have a batch item for
+// each item in the request, and the server must not respond to an operation in the profile with
+// Operation Not Supported.  Errors are prefixed with "Request" or "Response".
+func (c *ProfileChecker) CheckExchange(req, resp ttlv.TTLV) []error {
+	var errs []error
+
+	for _, err := range c.Check(req) {
+		errs = append(errs, merry.Prepend(err, "Request"))
+	}
+
+	respErrs := c.Check(resp)
+	for _, err := range respErrs {
+		errs = append(errs, merry.Prepend(err, "Response"))
+	}
+
+	if req.Valid() != nil || resp.Valid() != nil {
+		// already reported by Check()
+		return errs
+	}
+
+	var reqMsg RequestMessage
+	if err := ttlv.Unmarshal(req, &reqMsg); err != nil {
+		return append(errs, merry.Prepend(err, "Request"))
+	}
+
+	var respMsg ResponseMessage
+	if err := ttlv.Unmarshal(resp, &respMsg); err != nil {
+		return append(errs, merry.Prepend(err, "Response"))
+	}
+
+	if len(respMsg.BatchItem) != len(reqMsg.BatchItem) {
+		errs = append(errs, merry.Prepend(merry.Appendf(ErrProfileViolation, "there are %d batch items, but the request has %d",
+			len(respMsg.BatchItem), len(reqMsg.BatchItem)), "Response"))
+	}
+
+	for i := range respMsg.BatchItem {
+		bi := &respMsg.BatchItem[i]
+		if bi.ResultReason != kmip14.ResultReasonOperationNotSupported {
+			continue
+		}
+
+		op := bi.Operation
+		if op == 0 && i < len(reqMsg.BatchItem) {
+			op = reqMsg.BatchItem[i].Operation
+		}
+
+		if c.operations[op] {
+			err := merry.Appendf(ErrProfileViolation, "Operation %s is in the %s profile, but the server doesn't support it",
+				op.String(), c.Profile.Name)
+			errs = append(errs, merry.Prependf(err, "Response: BatchItem[%d]", i))
+		}
+	}
+
+	return errs
+}
+
+// CheckExchanges checks a sequence of exchanges, e.g. a recorded session, against the profile with
+// CheckExchange().  Errors are prefixed with the exchange's index, e.g. "Exchange[2]: Request: ...".
+func (c *ProfileChecker) CheckExchanges(exchanges []Exchange) []error {
+	var errs []error
+
+	for i, ex := range exchanges {
+		for _, err := range c.CheckExchange(ex.Request, ex.Response) {
+			errs = append(errs, merry.Prependf(err, "Exchange[%d]", i))
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,187 @@
+package kmip
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/gemalto/kmip-go/kmip14"
+	"github.com/gemalto/kmip-go/ttlv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProfileChecker(t *testing.T) {
+	checker, err := LoadProfileJSON(strings.NewReader(`{
+		"name": "Test Server",
+		"protocolVersions": ["1.3", "1.4"],
+		"operations": ["Create", "Get", "CreateKeyPair"],
+		"objectTypes": ["Symmetric Key"],
+		"attributes": ["Cryptographic Algorithm", "CryptographicLength", "x-custom"]
+	}`))
+	require.NoError(t, err)
+
+	request := func(version ProtocolVersion, op kmip14.Operation, payload interface{}) ttlv.TTLV {
+		b, err := ttlv.Marshal(RequestMessage{
+			RequestHeader: RequestHeader{ProtocolVersion: version, BatchCount: 1},
+			BatchItem:     []RequestBatchItem{{Operation: op, RequestPayload: payload}},
+		})
+		require.NoError(t, err)
+
+		return b
+	}
+
+	v14 := ProtocolVersion{ProtocolVersionMajor: 1, ProtocolVersionMinor: 4}
+
+	create := request(v14, kmip14.OperationCreate, &CreateRequestPayload{
+		ObjectType: kmip14.ObjectTypeSymmetricKey,
+		TemplateAttribute: TemplateAttribute{Attribute: []Attribute{
+			NewAttributeFromTag(kmip14.TagCryptographicAlgorithm, 0, kmip14.CryptographicAlgorithmAES),
+			NewAttributeFromTag(kmip14.TagCryptographicLength, 0, 256),
+			{AttributeName: "x-custom", AttributeValue: "hello"},
+		}},
+	})
+	assert.Empty(t, checker.Check(create))
+
+	// every violation is reported
+	register := request(ProtocolVersion{ProtocolVersionMajor: 1, ProtocolVersionMinor: 2}, kmip14.OperationRegister,
+		&RegisterRequestPayload{
+			ObjectType: kmip14.ObjectTypeCertificate,
+			TemplateAttribute: TemplateAttribute{Attribute: []Attribute{
+				NewAttributeFromTag(kmip14.TagObjectGroup, 0, "group1"),
+			}},
+			Certificate: &Certificate{CertificateType: kmip14.CertificateTypeX_509, CertificateValue: []byte{1}},
+		})
+
+	errs := checker.Check(register)
+	require.Len(t, errs, 4)
+
+	for _, err := range errs {
+		require.True(t, errors.Is(err, ErrProfileViolation), Details(err))
+	}
+
+	assert.Equal(t, "RequestHeader: profile violation: Protocol Version 1.2 is not in the Test Server profile", errs[0].Error())
+	assert.Equal(t, "BatchItem[0]: profile violation: Operation Register is not in the Test Server profile", errs[1].Error())
+	assert.Equal(t, `BatchItem[0]: profile violation: attribute "Object Group" is not in the Test Server profile`, errs[2].Error())
+	assert.Equal(t, "BatchItem[0]: profile violation: Object Type Certificate is not in the Test Server profile", errs[3].Error())
+
+	// the KMIP 2.0 form of attributes, and the Object Type attribute
+	b, err := ttlv.Marshal(s(kmip14.TagRequestMessage,
+		s(kmip14.TagRequestHeader,
+			s(kmip14.TagProtocolVersion,
+				v(kmip14.TagProtocolVersionMajor, 1),
+				v(kmip14.TagProtocolVersionMinor, 4),
+			),
+			v(kmip14.TagBatchCount, 1),
+		),
+		s(kmip14.TagBatchItem,
+			v(kmip14.TagOperation, kmip14.OperationCreateKeyPair),
+			s(kmip14.TagRequestPayload,
+				s(tagCommonAttributes,
+					v(kmip14.TagCryptographicAlgorithm, kmip14.CryptographicAlgorithmRSA),
+					v(kmip14.TagObjectType, kmip14.ObjectTypePrivateKey),
+				),
+				s(tagPublicKeyAttributes,
+					v(kmip14.TagCryptographicUsageMask, kmip14.CryptographicUsageMaskVerify),
+				),
+			),
+		),
+	))
+	require.NoError(t, err)
+
+	errs = checker.Check(b)
+	require.Len(t, errs, 3)
+	assert.Equal(t, `BatchItem[0]: profile violation: attribute "Object Type" is not in the Test Server profile`, errs[0].Error())
+	assert.Equal(t, `BatchItem[0]: profile violation: attribute "Cryptographic Usage Mask" is not in the Test Server profile`, errs[1].Error())
+	assert.Equal(t, "BatchItem[0]: profile violation: Object Type PrivateKey is not in the Test Server profile", errs[2].Error())
+
+	// schema errors are reported too
+	errs = checker.Check(request(v14, kmip14.OperationGet, s(kmip14.TagRequestPayload,
+		s(kmip14.TagKeyWrappingSpecification),
+	)))
+	require.Len(t, errs, 1)
+	require.True(t, errors.Is(errs[0], ttlv.ErrMissingRequiredValue), Details(errs[0]))
+	assert.Contains(t, errs[0].Error(), "BatchItem[0]: ")
+
+	// including the order of the batch item's own values
+	b, err = ttlv.Marshal(s(kmip14.TagRequestMessage,
+		s(kmip14.TagRequestHeader,
+			s(kmip14.TagProtocolVersion,
+				v(kmip14.TagProtocolVersionMajor, 1),
+				v(kmip14.TagProtocolVersionMinor, 4),
+			),
+			v(kmip14.TagBatchCount, 1),
+		),
+		s(kmip14.TagBatchItem,
+			s(kmip14.TagRequestPayload, v(kmip14.TagUniqueIdentifier, "1")),
+			v(kmip14.TagOperation, kmip14.OperationGet),
+		),
+	))
+	require.NoError(t, err)
+
+	errs = checker.Check(b)
+	require.Len(t, errs, 1)
+	require.True(t, errors.Is(errs[0], ttlv.ErrInvalidOrder), Details(errs[0]))
+	assert.Contains(t, errs[0].Error(), "BatchItem[0]: ")
+
+	// invalid messages
+	errs = checker.Check(create[:len(create)-1])
+	require.Len(t, errs, 1)
+	require.False(t, errors.Is(errs[0], ErrProfileViolation))
+
+	// exchanges
+	response := func(items ...ResponseBatchItem) ttlv.TTLV {
+		b, err := ttlv.Marshal(ResponseMessage{
+			ResponseHeader: ResponseHeader{ProtocolVersion: v14, BatchCount: len(items)},
+			BatchItem:      items,
+		})
+		require.NoError(t, err)
+
+		return b
+	}
+
+	get := request(v14, kmip14.OperationGet, &GetRequestPayload{UniqueIdentifier: "1"})
+
+	errs = checker.CheckExchanges([]Exchange{
+		{
+			Request: create,
+			Response: response(ResponseBatchItem{
+				Operation:       kmip14.OperationCreate,
+				ResultStatus:    kmip14.ResultStatusSuccess,
+				ResponsePayload: &CreateResponsePayload{ObjectType: kmip14.ObjectTypeSymmetricKey, UniqueIdentifier: "1"},
+			}),
+		},
+		{
+			Request: get,
+			Response: response(ResponseBatchItem{
+				ResultStatus: kmip14.ResultStatusOperationFailed,
+				ResultReason: kmip14.ResultReasonOperationNotSupported,
+			}),
+		},
+		{
+			Request:  get,
+			Response: response(),
+		},
+	})
+	require.Len(t, errs, 2)
+
+	for _, err := range errs {
+		require.True(t, errors.Is(err, ErrProfileViolation), Details(err))
+	}
+
+	assert.Equal(t, "Exchange[1]: Response: BatchItem[0]: profile violation: Operation Get is in the Test Server profile, "+
+		"but the server doesn't support it", errs[0].Error())
+	assert.Equal(t, "Exchange[2]: Response: profile violation: there are 0 batch items, but the request has 1", errs[1].Error())
+
+	// invalid profiles
+	_, err = NewProfileChecker(Profile{Attributes: []string{"Favorite Color"}})
+	require.True(t, errors.Is(err, ErrUnknownAttribute), Details(err))
+
+	_, err = NewProfileChecker(Profile{Operations: []string{"Juggle"}})
+	require.Error(t, err)
+
+	for _, version := range []string{"one", "1.4x", "1.4.1", "1", "-1.4"} {
+		_, err = NewProfileChecker(Profile{ProtocolVersions: []string{version}})
+		require.Error(t, err, version)
+	}
+}
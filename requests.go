@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"reflect"
 	"runtime"
 	"sync"
 	"sync/atomic"
@@ -611,6 +612,85 @@ func (f ItemHandlerFunc) HandleItem(ctx context.Context, req *Request) (item *Re
 	return f(ctx, req)
 }
 
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// TypedItemHandler adapts a function which takes and returns payload structs into an ItemHandler.
+// fn must have the signature:
+//
+//	func(ctx context.Context, payload ReqPayload) (RespPayload, error)
+//
+// ReqPayload may be a struct or a pointer to a struct.  The handler decodes the batch item's request
+// payload into a new ReqPayload, calls fn, and returns a batch item with fn's result as the
+// response payload, which is encoded with the rest of the response.  Errors returned by fn are
+// returned as is, so they are converted to failed batch items by the OperationMux's ErrorHandler.
+//
+// For example:
+//
+//	mux.Handle(kmip14.OperationCreate, TypedItemHandler(
+//		func(ctx context.Context, payload CreateRequestPayload) (CreateResponsePayload, error) {
+//			...
+//		},
+//	))
+//
+// The signature is checked with reflection, and TypedItemHandler panics if fn doesn't match it.
+func TypedItemHandler(fn interface{}) ItemHandler {
+	fv := reflect.ValueOf(fn)
+
+	if fv.Kind() != reflect.Func {
+		panic(fmt.Sprintf("kmip: TypedItemHandler requires a func, got %T", fn))
+	}
+
+	ft := fv.Type()
+	if ft.NumIn() != 2 || ft.NumOut() != 2 || ft.In(0) != contextType || ft.Out(1) != errorType ||
+		indirectStructType(ft.In(1)) == nil {
+		panic(fmt.Sprintf("kmip: TypedItemHandler requires a func(context.Context, ReqPayload) (RespPayload, error), got %v", ft))
+	}
+
+	return &typedItemHandler{fn: fv, reqType: ft.In(1)}
+}
+
+func indirectStructType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	return t
+}
+
+type typedItemHandler struct {
+	fn      reflect.Value
+	reqType reflect.Type
+}
+
+func (h *typedItemHandler) HandleItem(ctx context.Context, req *Request) (*ResponseBatchItem, error) {
+	payload := reflect.New(indirectStructType(h.reqType))
+
+	err := req.DecodePayload(payload.Interface())
+	if err != nil {
+		return nil, err
+	}
+
+	if h.reqType.Kind() != reflect.Ptr {
+		payload = payload.Elem()
+	}
+
+	out := h.fn.Call([]reflect.Value{reflect.ValueOf(ctx), payload})
+	if err, _ := out[1].Interface().(error); err != nil {
+		return nil, err
+	}
+
+	return &ResponseBatchItem{
+		ResponsePayload: out[0].Interface(),
+	}, nil
+}
+
 var DefaultProtocolHandler = &StandardProtocolHandler{
 	MessageHandler: DefaultOperationMux,
 	ProtocolVersion: ProtocolVersion{
@@ -626,9 +706,14 @@ var DefaultOperationMux = &OperationMux{}
 // level tasks like version negotiation and correlation values.
 //
 // It delegates handling of the request to a MessageHandler.
+//
+// If MaxBatchItems is greater than zero, requests whose Batch Count, or number of batch
+// items, exceeds it are rejected with Result Reason Invalid Message, before the
+// message is decoded or any of its items are handled.
 type StandardProtocolHandler struct {
 	ProtocolVersion ProtocolVersion
 	MessageHandler  MessageHandler
+	MaxBatchItems   int
 
 	LogTraffic bool
 }
@@ -643,6 +728,12 @@ func (h *StandardProtocolHandler) parseMessage(ctx context.Context, req *Request
 		return merry.Errorf("invalid tag: expected RequestMessage, was %s", ttlvV.Tag().String())
 	}
 
+	if h.MaxBatchItems > 0 {
+		if err := checkMaxBatchItems(ttlvV, h.MaxBatchItems); err != nil {
+			return err
+		}
+	}
+
 	var message RequestMessage
 	err := ttlv.Unmarshal(ttlvV, &message)
 	if err != nil {
@@ -739,6 +830,13 @@ func (h *StandardProtocolHandler) handleRequest(ctx context.Context, req *Reques
 		return
 	}
 
+	// reject requests whose header disagrees with the body about how many items
+	// there are, rather than guessing which is right
+	if err := req.Message.ValidateBatchCount(); err != nil {
+		resp.errorResponse(kmip14.ResultReasonInvalidMessage, err.Error())
+		return
+	}
+
 	// set a flag hinting to handlers that extra fields should not be tolerated when
 	// unmarshaling payloads.  According to spec, if server and client protocol version
 	// minor versions match, then extra fields should cause an error.  Not sure how to enforce
@@ -889,6 +987,12 @@ func (m *OperationMux) Handle(op kmip14.Operation, handler ItemHandler) {
 	m.handlers[op] = handler
 }
 
+// HandleFunc registers a function which takes and returns payload structs as the handler
+// for op.  See TypedItemHandler.
+func (m *OperationMux) HandleFunc(op kmip14.Operation, fn interface{}) {
+	m.Handle(op, TypedItemHandler(fn))
+}
+
 func (m *OperationMux) handlerForOp(op kmip14.Operation) ItemHandler {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
@@ -0,0 +1,216 @@
+package kmip
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/gemalto/kmip-go/kmip14"
+	"github.com/gemalto/kmip-go/ttlv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStandardProtocolHandler_batchCount(t *testing.T) {
+	h := &StandardProtocolHandler{
+		ProtocolVersion: ProtocolVersion{ProtocolVersionMajor: 1, ProtocolVersionMinor: 4},
+		MessageHandler: MessageHandlerFunc(func(ctx context.Context, req *Request, resp *Response) {
+			for range req.Message.BatchItem {
+				resp.BatchItem = append(resp.BatchItem, ResponseBatchItem{ResultStatus: kmip14.ResultStatusSuccess})
+			}
+		}),
+	}
+
+	handle := func(batchCount int) *Response {
+		msg := RequestMessage{
+			RequestHeader: RequestHeader{
+				ProtocolVersion: h.ProtocolVersion,
+				BatchCount:      batchCount,
+			},
+			BatchItem: []RequestBatchItem{
+				{Operation: kmip14.OperationQuery},
+				{Operation: kmip14.OperationQuery},
+			},
+		}
+
+		b, err := ttlv.Marshal(msg)
+		require.NoError(t, err)
+
+		resp := newResponse()
+		h.handleRequest(context.Background(), &Request{TTLV: b}, resp)
+
+		return resp
+	}
+
+	resp := handle(2)
+	require.Len(t, resp.BatchItem, 2)
+	require.NoError(t, resp.ValidateBatchCount())
+
+	resp = handle(3)
+	require.Len(t, resp.BatchItem, 1)
+	assert.Equal(t, kmip14.ResultStatusOperationFailed, resp.BatchItem[0].ResultStatus)
+	assert.Equal(t, kmip14.ResultReasonInvalidMessage, resp.BatchItem[0].ResultReason)
+	assert.Contains(t, resp.BatchItem[0].ResultMessage, "Batch Count is 3, but there are 2 batch items")
+
+	m := ResponseMessage{ResponseHeader: ResponseHeader{BatchCount: 1}}
+	err := m.ValidateBatchCount()
+	require.True(t, errors.Is(err, ErrBatchCountMismatch), Details(err))
+}
+
+// roundTripItem marshals a request with a single batch item, handles it with a
+// StandardProtocolHandler routing op to handler, and returns the response batch item.
+func roundTripItem(t *testing.T, op kmip14.Operation, handler ItemHandler, reqPayload interface{}) ResponseBatchItem {
+	t.Helper()
+
+	mux := &OperationMux{}
+	mux.Handle(op, handler)
+
+	h := &StandardProtocolHandler{
+		ProtocolVersion: ProtocolVersion{ProtocolVersionMajor: 1, ProtocolVersionMinor: 4},
+		MessageHandler:  mux,
+	}
+
+	b, err := ttlv.Marshal(RequestMessage{
+		RequestHeader: RequestHeader{ProtocolVersion: h.ProtocolVersion, BatchCount: 1},
+		BatchItem:     []RequestBatchItem{{Operation: op, RequestPayload: reqPayload}},
+	})
+	require.NoError(t, err)
+
+	resp := newResponse()
+	h.handleRequest(context.Background(), &Request{TTLV: b}, resp)
+
+	var msg ResponseMessage
+	require.NoError(t, ttlv.Unmarshal(resp.Bytes(), &msg))
+	require.Len(t, msg.BatchItem, 1)
+
+	return msg.BatchItem[0]
+}
+
+func TestTypedItemHandler(t *testing.T) {
+	destroy := TypedItemHandler(func(ctx context.Context, payload DestroyRequestPayload) (DestroyResponsePayload, error) {
+		if payload.UniqueIdentifier != "1" {
+			return DestroyResponsePayload{}, WithResultReason(errors.New("not found"), kmip14.ResultReasonItemNotFound)
+		}
+
+		return DestroyResponsePayload{UniqueIdentifier: payload.UniqueIdentifier}, nil
+	})
+	archive := TypedItemHandler(func(ctx context.Context, payload *ArchiveRequestPayload) (*ArchiveResponsePayload, error) {
+		return &ArchiveResponsePayload{UniqueIdentifier: payload.UniqueIdentifier}, nil
+	})
+
+	var destroyResp DestroyResponsePayload
+
+	bi := roundTripItem(t, kmip14.OperationDestroy, destroy, DestroyRequestPayload{UniqueIdentifier: "1"})
+	require.NoError(t, bi.DecodePayload(&destroyResp))
+	assert.Equal(t, "1", destroyResp.UniqueIdentifier)
+
+	bi = roundTripItem(t, kmip14.OperationDestroy, destroy, DestroyRequestPayload{UniqueIdentifier: "2"})
+	err := bi.DecodePayload(&destroyResp)
+
+	var itemErr *ItemError
+
+	require.True(t, errors.As(err, &itemErr), "got %v", err)
+	assert.Equal(t, kmip14.ResultReasonItemNotFound, itemErr.ResultReason)
+
+	var archiveResp ArchiveResponsePayload
+
+	bi = roundTripItem(t, kmip14.OperationArchive, archive, ArchiveRequestPayload{UniqueIdentifier: "3"})
+	require.NoError(t, bi.DecodePayload(&archiveResp))
+	assert.Equal(t, "3", archiveResp.UniqueIdentifier)
+
+	// HandleFunc registers the typed handler
+	mux := &OperationMux{}
+	mux.HandleFunc(kmip14.OperationArchive, func(ctx context.Context, payload *ArchiveRequestPayload) (*ArchiveResponsePayload, error) {
+		return &ArchiveResponsePayload{UniqueIdentifier: payload.UniqueIdentifier}, nil
+	})
+	assert.NotNil(t, mux.handlerForOp(kmip14.OperationArchive))
+
+	for _, fn := range []interface{}{
+		nil,
+		"notafunc",
+		func(ctx context.Context, payload DestroyRequestPayload) DestroyResponsePayload {
+			return DestroyResponsePayload{}
+		},
+		func(payload DestroyRequestPayload) (DestroyResponsePayload, error) {
+			return DestroyResponsePayload{}, nil
+		},
+		func(ctx context.Context, id string) (DestroyResponsePayload, error) {
+			return DestroyResponsePayload{}, nil
+		},
+	} {
+		assert.Panics(t, func() { TypedItemHandler(fn) }, "%T", fn)
+	}
+}
+
+func TestStandardProtocolHandler_MaxBatchItems(t *testing.T) {
+	var handled int
+
+	mux := &OperationMux{}
+	mux.Handle(kmip14.OperationDestroy, &DestroyHandler{
+		Destroy: func(ctx context.Context, payload *DestroyRequestPayload) (*DestroyResponsePayload, error) {
+			handled++
+
+			return &DestroyResponsePayload{UniqueIdentifier: payload.UniqueIdentifier}, nil
+		},
+	})
+
+	h := &StandardProtocolHandler{
+		ProtocolVersion: ProtocolVersion{ProtocolVersionMajor: 1, ProtocolVersionMinor: 4},
+		MessageHandler:  mux,
+		MaxBatchItems:   2,
+	}
+
+	request := func(batchCount, items int) ttlv.TTLV {
+		msg := RequestMessage{
+			RequestHeader: RequestHeader{ProtocolVersion: h.ProtocolVersion, BatchCount: batchCount},
+		}
+		for i := 0; i < items; i++ {
+			msg.BatchItem = append(msg.BatchItem, RequestBatchItem{
+				Operation:      kmip14.OperationDestroy,
+				RequestPayload: DestroyRequestPayload{UniqueIdentifier: "1"},
+			})
+		}
+
+		b, err := ttlv.Marshal(msg)
+		require.NoError(t, err)
+
+		return b
+	}
+
+	tests := []struct {
+		name       string
+		batchCount int
+		items      int
+		errMsg     string
+	}{
+		{name: "withinlimit", batchCount: 2, items: 2},
+		{name: "toomanyitems", batchCount: 3, items: 3, errMsg: "too many batch items: Batch Count 3 exceeds the limit of 2"},
+		{name: "batchcountexceeds", batchCount: 1000000, items: 1, errMsg: "too many batch items: Batch Count 1000000 exceeds the limit of 2"},
+		{name: "itemsexceed", batchCount: 1, items: 3, errMsg: "too many batch items: message has more than the limit of 2 batch items"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			handled = 0
+
+			resp := newResponse()
+			h.handleRequest(context.Background(), &Request{TTLV: request(tc.batchCount, tc.items)}, resp)
+
+			var msg ResponseMessage
+			require.NoError(t, ttlv.Unmarshal(resp.Bytes(), &msg))
+
+			if tc.errMsg == "" {
+				assert.Len(t, msg.BatchItem, tc.items)
+				assert.Equal(t, tc.items, handled)
+
+				return
+			}
+
+			require.Len(t, msg.BatchItem, 1)
+			assert.Equal(t, kmip14.ResultStatusOperationFailed, msg.BatchItem[0].ResultStatus)
+			assert.Equal(t, kmip14.ResultReasonInvalidMessage, msg.BatchItem[0].ResultReason)
+			assert.Equal(t, tc.errMsg, msg.BatchItem[0].ResultMessage)
+			assert.Zero(t, handled)
+		})
+	}
+}
@@ -6,11 +6,19 @@ import (
 	"errors"
 	"io"
 	"reflect"
+	"strconv"
+	"sync"
+	"unicode/utf8"
 
 	"github.com/ansel1/merry"
 )
 
-var ErrUnexpectedValue = errors.New("no field was found to unmarshal value into")
+var (
+	ErrUnexpectedValue = errors.New("no field was found to unmarshal value into")
+	ErrDuplicateValue  = errors.New("value repeated for a field which can only hold one value")
+	ErrMaxLenExceeded  = errors.New("value exceeds the maximum message length")
+	ErrInvalidCoercion = errors.New("value can't be reinterpreted as the coerced type")
+)
 
 // Unmarshal parses TTLV encoded data and stores the result
 // in the value pointed to by v.
@@ -20,8 +28,8 @@ var ErrUnexpectedValue = errors.New("no field was found to unmarshal value into"
 //
 // Unmarshal will allocate values to store the result in, similar to the
 // json.Marshal.  Generally, the destination value can be a pointer or
-// or a direct value.  Currently, Unmarshal does not support anonymous fields.
-// They will be ignored.  Private fields are ignored.
+// or a direct value.  Private fields are ignored.  Embedded structs are
+// described below.
 //
 // Unmarshal maps TTLV values to golang values according to the following
 // rules:
@@ -116,9 +124,20 @@ var ErrUnexpectedValue = errors.New("no field was found to unmarshal value into"
 // *cannot* map to the same KMIP tag.  If they do, an ErrTagConflict will
 // be returned.
 //
+// The fields of embedded structs are treated as fields of the enclosing struct, unless
+// the embedded field's struct tag specifies a TTLV tag.  See Marshal.  Nil pointers to
+// embedded structs are allocated if one of their fields is set.
+//
 // Each value in the Structure will be matched against the first field
 // in the struct with the same inferred tag.
 //
+// KMIP allows a tag to be repeated in a Structure.  If the matching field is a slice
+// (other than []byte), each occurrence is appended to the slice, in order.  Otherwise,
+// the field takes the first occurrence, and later occurrences are ignored, unless
+// the Decoder's DisallowDuplicateScalars option is set, in which case an
+// *UnmarshalerError with cause ErrDuplicateValue is returned.  Fields whose types
+// implement Unmarshaler are passed every occurrence, and decide for themselves.
+//
 // If the value cannot be matched with a field, Unmarshal will look for
 // the first field with the "any" struct flag set and unmarshal into that:
 //
@@ -131,10 +150,92 @@ var ErrUnexpectedValue = errors.New("no field was found to unmarshal value into"
 //     }
 //
 // If after applying these rules no destination field is found, the KMIP value is ignored.
+//
+// Deferring Decoding
+//
+// A field of type TTLV or RawTTLV receives a copy of the encoded value, without decoding it.
+// This can be used to decode a message in two phases, e.g. to decode a batch item's Operation
+// first, then decode its payload into the payload type for that operation:
+//
+//     type BatchItem struct {
+//         Operation       Operation
+//         ResponsePayload RawTTLV
+//     }
+//
+//     var bi BatchItem
+//     err := Unmarshal(b, &bi)
+//     // ... choose a payload type based on bi.Operation
+//     err = bi.ResponsePayload.Decode(&payload)
 func Unmarshal(ttlv TTLV, v interface{}) error {
 	return NewDecoder(bytes.NewReader(ttlv)).Decode(v)
 }
 
+// ReadValue reads a single TTLV value from r, and unmarshals it into v.  See Unmarshal
+// for decoding rules.
+//
+// Unlike a Decoder, which buffers its reader, ReadValue reads exactly the bytes of
+// the value from r, so r is positioned at the start of the next value afterwards.
+// Errors reading the value are returned as a *DecodeError.
+//
+// If maxBytes is greater than zero, a value whose full length exceeds it is rejected with
+// ErrMaxLenExceeded, after reading only its header, like Decoder.MaxMessageBytes.  In any case,
+// the buffer for the value grows as its bytes are read, rather than being allocated up front
+// from the length in the header, so a peer can't force a large allocation without sending the
+// bytes.
+func ReadValue(r io.Reader, v interface{}, maxBytes int) error {
+	header := make(TTLV, lenHeader)
+
+	if n, err := io.ReadFull(r, header); err != nil {
+		if n == 0 {
+			return merry.Wrap(err)
+		}
+
+		return merry.Wrap(newDecodeError(0, header[:n], err))
+	}
+
+	if err := header.ValidHeader(); err != nil {
+		return merry.Prependf(newDecodeError(0, header, err), "invalid header: %v", header)
+	}
+
+	fullLen := header.FullLen()
+	if maxBytes > 0 && fullLen > maxBytes {
+		return merry.Wrap(newDecodeError(0, header, ErrMaxLenExceeded)).
+			Appendf("%d bytes exceeds limit of %d bytes", fullLen, maxBytes)
+	}
+
+	buf, err := readFull(r, header, fullLen)
+	if err != nil {
+		return merry.Wrap(newDecodeError(0, header, err))
+	}
+
+	if o, err := buf.firstInvalid(); err != nil {
+		return merry.Wrap(newDecodeError(int64(o), buf[o:], err))
+	}
+
+	return NewDecoder(nil).DecodeValue(v, buf)
+}
+
+// readFull reads the rest of a value with the given header and full length from r, and
+// returns the whole value.  The buffer grows as bytes are read, so its size is bounded by
+// the bytes actually received, not by the length in the header.  If r ends before the value
+// is complete, the bytes read so far are returned with io.ErrUnexpectedEOF.
+func readFull(r io.Reader, header TTLV, fullLen int) (TTLV, error) {
+	var buf bytes.Buffer
+
+	buf.Write(header)
+
+	n, err := io.CopyN(&buf, r, int64(fullLen-len(header)))
+	if err != nil && n < int64(fullLen-len(header)) {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+
+		return buf.Bytes(), err
+	}
+
+	return buf.Bytes(), nil
+}
+
 // Unmarshaler knows how to unmarshal a ttlv value into itself.
 // The decoder argument may be used to decode the ttlv value into
 // intermediary values if needed.
@@ -148,13 +249,56 @@ type Unmarshaler interface {
 //
 // If DisallowExtraValues is true, the decoder will return an error when decoding
 // Structures into structs and a matching field can't get found for every value.
+//
+// If DisallowDuplicateScalars is true, the decoder will return an error when decoding
+// Structures into structs and a tag is repeated, but the matching field can only hold
+// a single value.  By default, the first value is kept.
+//
+// If MaxMessageBytes is greater than zero, the decoder rejects values read from the stream
+// whose full length, which includes all the values nested inside them, exceeds it.  The
+// length is checked against the header, before any memory is allocated for the value, and
+// ErrMaxLenExceeded is returned.  This caps the memory a single message can make the decoder
+// allocate, even if each nested value is within MaxFullLen.
+//
+// TypeCoercions works around peers which encode some values with the wrong type, e.g. an
+// Enumeration where the spec says Integer.  Values with a tag in the map are decoded as if
+// their header had the mapped type.  Only reinterpretations which don't change the layout
+// of the value's bytes are supported:
+//
+//   - Integer, Enumeration, and Interval, which are all 4 bytes
+//   - LongInteger, DateTime, and DateTimeExtended, which are all 8 bytes
+//   - TextString and ByteString, though a ByteString is only reinterpreted as a TextString
+//     if it contains valid UTF-8
+//
+// Values which can't be reinterpreted return an error with cause ErrInvalidCoercion.  The
+// coercions apply to values with the tag at any depth, and when decoding into interface{} values.
+//
+// If MaxBigIntegerBytes is greater than zero, decoding a BigInteger whose value is longer
+// than that many bytes returns an error with cause ErrMaxLenExceeded, before the value is
+// converted to a big.Int.  This bounds the cost of the arithmetic a peer can cause with a
+// huge value, e.g. in a key's Modulus.  Converting a BigInteger is linear in its length, so
+// this is mostly a bound on memory and on the arithmetic the caller does with the result.
+//
+// Defaults fills in values which are absent from a Structure.  When decoding a Structure into
+// a struct, each single-valued field whose tag is absent from the Structure, and is a key in the
+// map, is set to the mapped value, as if the value had been encoded with the field's tag.  Fields
+// of values which are present, even if they are zero, aren't changed.  Defaults aren't applied
+// to slice fields, "any" fields, or to structs with an Unmarshaler.
 type Decoder struct {
-	r                   io.Reader
-	bufr                *bufio.Reader
-	DisallowExtraValues bool
+	r                        io.Reader
+	bufr                     *bufio.Reader
+	DisallowExtraValues      bool
+	DisallowDuplicateScalars bool
+	MaxMessageBytes          int
+	TypeCoercions            map[Tag]Type
+	Defaults                 map[Tag]interface{}
+	MaxBigIntegerBytes       int
 
 	currStruct reflect.Type
 	currField  string
+
+	// offset is the number of bytes NextTTLV() has consumed from bufr
+	offset int64
 }
 
 func NewDecoder(r io.Reader) *Decoder {
@@ -173,14 +317,33 @@ func (dec *Decoder) Reset(r io.Reader) {
 	dec.bufr.Reset(r)
 }
 
+// InputOffset returns the number of bytes the decoder has consumed from
+// the input stream.  After a value is read successfully, this is the offset in
+// the stream of the next value.  Bytes which have been buffered from the
+// underlying reader, but not yet consumed as part of a value, are not counted.
+// If reading a value fails part way through, the offset includes the bytes of
+// the partial value which were consumed.
+func (dec *Decoder) InputOffset() int64 {
+	return dec.offset
+}
+
 // Decode the first KMIP value from the reader into v.
 // See Unmarshal for decoding rules.
+//
+// If the value is not valid TTLV, a *DecodeError is returned, with the offset in
+// the stream of the invalid value.  This may be a value nested inside the value read.
 func (dec *Decoder) Decode(v interface{}) error {
+	start := dec.offset
+
 	ttlv, err := dec.NextTTLV()
 	if err != nil {
 		return err
 	}
 
+	if o, err := ttlv.firstInvalid(); err != nil {
+		return merry.Wrap(newDecodeError(start+int64(o), ttlv[o:], err))
+	}
+
 	return dec.DecodeValue(v, ttlv)
 }
 
@@ -201,6 +364,11 @@ func (dec *Decoder) unmarshal(val reflect.Value, ttlv TTLV) error {
 		return nil
 	}
 
+	ttlv, err := dec.coerce(ttlv)
+	if err != nil {
+		return dec.newUnmarshalerError(ttlv, val.Type(), err)
+	}
+
 	// Load value from interface, but only if the result will be
 	// usefully addressable.
 	if val.Kind() == reflect.Interface && !val.IsNil() {
@@ -236,6 +404,10 @@ func (dec *Decoder) unmarshal(val reflect.Value, ttlv TTLV) error {
 			// as the value.
 			val.Set(reflect.ValueOf(ttlv))
 		} else {
+			if err := dec.checkBigInteger(ttlv, val.Type()); err != nil {
+				return err
+			}
+
 			// set blank interface equal to the TTLV.Value()
 			val.Set(reflect.ValueOf(ttlv.Value()))
 		}
@@ -263,6 +435,12 @@ func (dec *Decoder) unmarshal(val reflect.Value, ttlv TTLV) error {
 	default:
 	}
 
+	return dec.unmarshalValue(val, ttlv)
+}
+
+// unmarshalValue decodes ttlv into val, which must not be a pointer, interface,
+// or Unmarshaler, and must not be a slice, unless it is a []byte.
+func (dec *Decoder) unmarshalValue(val reflect.Value, ttlv TTLV) error {
 	typeMismatchErr := func() error {
 		e := &UnmarshalerError{
 			Struct: dec.currStruct,
@@ -372,6 +550,10 @@ func (dec *Decoder) unmarshal(val reflect.Value, ttlv TTLV) error {
 			return typeMismatchErr()
 		}
 
+		if err := dec.checkBigInteger(ttlv, val.Type()); err != nil {
+			return err
+		}
+
 		val.Set(reflect.ValueOf(*ttlv.ValueBigInteger()))
 	default:
 		return dec.newUnmarshalerError(ttlv, val.Type(), ErrInvalidType)
@@ -380,12 +562,24 @@ func (dec *Decoder) unmarshal(val reflect.Value, ttlv TTLV) error {
 	return nil
 }
 
+// checkBigInteger returns an error if ttlv is a BigInteger longer than MaxBigIntegerBytes.
+func (dec *Decoder) checkBigInteger(ttlv TTLV, valType reflect.Type) error {
+	if dec.MaxBigIntegerBytes <= 0 || ttlv.Type() != TypeBigInteger || ttlv.Len() <= dec.MaxBigIntegerBytes {
+		return nil
+	}
+
+	return dec.newUnmarshalerError(ttlv, valType, ErrMaxLenExceeded).
+		Appendf("BigInteger is %d bytes, the maximum is %d", ttlv.Len(), dec.MaxBigIntegerBytes)
+}
+
 func (dec *Decoder) unmarshalStructure(ttlv TTLV, val reflect.Value) error {
-	ti, err := getTypeInfo(val.Type())
+	sd, err := getStructDecoder(val.Type())
 	if err != nil {
 		return dec.newUnmarshalerError(ttlv, val.Type(), err)
 	}
 
+	ti := &sd.ti
+
 	if ti.tagField != nil && ti.tagField.ti.typ == tagType {
 		val.FieldByIndex(ti.tagField.index).Set(reflect.ValueOf(ttlv.Tag()))
 	}
@@ -395,30 +589,46 @@ func (dec *Decoder) unmarshalStructure(ttlv TTLV, val reflect.Value) error {
 	// push currStruct (caller will pop)
 	dec.currStruct = val.Type()
 
+	// track which single-valued fields have been set, to detect repeated tags
+	var seenBuf [64]bool
+
+	seen := seenBuf[:]
+	if len(fields) > len(seenBuf) {
+		seen = make([]bool, len(fields))
+	}
+
 	for n := ttlv.ValueStructure(); n != nil; n = n.Next() {
-		fldIdx := -1
-
-		for i := range fields {
-			if fields[i].flags.any() {
-				// if this is the first any field found, keep track
-				// of it as the current candidate match, but
-				// keep looking for a tag match
-				if fldIdx == -1 {
-					fldIdx = i
-				}
-			} else if fields[i].tag == n.Tag() {
-				// tag match found
-				// we can stop looking
-				fldIdx = i
-				break
-			}
+		fldIdx, ok := sd.fieldsByTag[n.Tag()]
+		if !ok {
+			fldIdx = sd.anyField
 		}
 
 		if fldIdx > -1 {
+			if !sd.repeatable[fldIdx] {
+				if seen[fldIdx] {
+					if dec.DisallowDuplicateScalars {
+						dec.currField = fields[fldIdx].name
+						return dec.newUnmarshalerError(n, fields[fldIdx].ti.typ, ErrDuplicateValue)
+					}
+
+					continue
+				}
+
+				seen[fldIdx] = true
+			}
+
 			// push currField
 			currField := dec.currField
 			dec.currField = fields[fldIdx].name
-			err := dec.unmarshal(val.FieldByIndex(fields[fldIdx].index), n)
+
+			fv, _ := fieldByIndex(val, fields[fldIdx].index, true)
+			if sd.direct[fldIdx] && n.Type() != TypeStructure && dec.TypeCoercions == nil {
+				// fast path: leaf values decoded into plain fields can skip
+				// the Unmarshaler, pointer, and slice handling in unmarshal()
+				err = dec.unmarshalValue(fv, n)
+			} else {
+				err = dec.unmarshal(fv, n)
+			}
 			// restore currField
 			dec.currField = currField
 
@@ -430,40 +640,351 @@ func (dec *Decoder) unmarshalStructure(ttlv TTLV, val reflect.Value) error {
 		}
 	}
 
+	if dec.Defaults == nil {
+		return nil
+	}
+
+	for i := range fields {
+		if seen[i] || sd.repeatable[i] || fields[i].flags.any() {
+			continue
+		}
+
+		def, ok := dec.Defaults[fields[i].tag]
+		if !ok {
+			continue
+		}
+
+		if err := dec.applyDefault(val, &fields[i], def); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
+// applyDefault sets the field to the default value, by encoding the default with
+// the field's tag and decoding it, so the default can be any value which encodes
+// to something the field can hold.
+func (dec *Decoder) applyDefault(val reflect.Value, fi *fieldInfo, def interface{}) error {
+	currField := dec.currField
+	dec.currField = fi.name
+
+	defer func() {
+		dec.currField = currField
+	}()
+
+	b, err := Marshal(Value{Tag: fi.tag, Value: def})
+	if err != nil {
+		return dec.newUnmarshalerError(nil, fi.ti.typ, err)
+	}
+
+	fv, _ := fieldByIndex(val, fi.index, true)
+
+	return dec.unmarshal(fv, b)
+}
+
+// coercibleTypes maps each type to the types its values can be reinterpreted as
+// without changing the value's bytes.
+var coercibleTypes = map[Type][]Type{
+	TypeInteger:          {TypeEnumeration, TypeInterval},
+	TypeEnumeration:      {TypeInteger, TypeInterval},
+	TypeInterval:         {TypeInteger, TypeEnumeration},
+	TypeLongInteger:      {TypeDateTime, TypeDateTimeExtended},
+	TypeDateTime:         {TypeLongInteger, TypeDateTimeExtended},
+	TypeDateTimeExtended: {TypeLongInteger, TypeDateTime},
+	TypeTextString:       {TypeByteString},
+	TypeByteString:       {TypeTextString},
+}
+
+// coerce returns ttlv with its type changed according to TypeCoercions.  The
+// returned value is a copy, so the original bytes aren't modified.
+func (dec *Decoder) coerce(ttlv TTLV) (TTLV, error) {
+	to, ok := dec.TypeCoercions[ttlv.Tag()]
+	if !ok || to == ttlv.Type() {
+		return ttlv, nil
+	}
+
+	from := ttlv.Type()
+
+	compatible := false
+
+	for _, t := range coercibleTypes[from] {
+		if t == to {
+			compatible = true
+			break
+		}
+	}
+
+	if !compatible {
+		return ttlv, merry.Appendf(ErrInvalidCoercion, "%s to %s", from, to)
+	}
+
+	if to == TypeTextString && !utf8.Valid(ttlv.ValueRaw()) {
+		return ttlv, merry.Appendf(ErrInvalidCoercion, "%s to %s: not valid UTF-8", from, to)
+	}
+
+	c := append(TTLV(nil), ttlv[:ttlv.FullLen()]...)
+	c[lenTag] = byte(to)
+
+	return c, nil
+}
+
+// structDecoder is a precomputed plan for decoding structures into a
+// particular struct type.
+type structDecoder struct {
+	ti typeInfo
+	// fieldsByTag maps tags to the index of the value field with that tag.
+	fieldsByTag map[Tag]int
+	// anyField is the index of the first "any" field, or -1.  Values which
+	// don't match any field's tag are decoded into this field.
+	anyField int
+	// direct is true for fields which can be decoded with unmarshalValue().
+	direct []bool
+	// repeatable is true for fields which accept repeated values with the same tag.
+	repeatable []bool
+}
+
+// structDecoders caches *structDecoders by reflect.Type.  It is cleared
+// along with typeInfoCache.
+var structDecoders sync.Map
+
+func getStructDecoder(typ reflect.Type) (*structDecoder, error) {
+	if sd, ok := structDecoders.Load(typ); ok {
+		return sd.(*structDecoder), nil //nolint:forcetypeassert
+	}
+
+	ti, err := getTypeInfo(typ)
+	if err != nil {
+		return nil, err
+	}
+
+	sd := &structDecoder{
+		ti:          ti,
+		fieldsByTag: make(map[Tag]int, len(ti.valueFields)),
+		anyField:    -1,
+		direct:      make([]bool, len(ti.valueFields)),
+		repeatable:  make([]bool, len(ti.valueFields)),
+	}
+
+	for i := range ti.valueFields {
+		fi := &ti.valueFields[i]
+
+		switch {
+		case fi.flags.any():
+			if sd.anyField == -1 {
+				sd.anyField = i
+			}
+		case fi.tag != TagNone:
+			sd.fieldsByTag[fi.tag] = i
+		}
+
+		sd.direct[i] = isDirectDecodeType(fi.ti.typ)
+		sd.repeatable[i] = isRepeatableType(fi.ti.typ)
+	}
+
+	actual, _ := structDecoders.LoadOrStore(typ, sd)
+
+	return actual.(*structDecoder), nil //nolint:forcetypeassert
+}
+
+// isDirectDecodeType returns true if values of typ can be decoded
+// by unmarshalValue() directly.
+func isDirectDecodeType(typ reflect.Type) bool {
+	if typ.Implements(unmarshalerType) || reflect.PtrTo(typ).Implements(unmarshalerType) {
+		return false
+	}
+
+	switch typ.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return false
+	case reflect.Slice:
+		return typ.Elem() == byteType
+	default:
+		return true
+	}
+}
+
+// isRepeatableType returns true if a field of type typ can hold repeated values
+// with the same tag: slices other than []byte, and Unmarshalers, which handle
+// repeated values themselves.
+func isRepeatableType(typ reflect.Type) bool {
+	if typ.Implements(unmarshalerType) || reflect.PtrTo(typ).Implements(unmarshalerType) {
+		return true
+	}
+
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	return typ.Kind() == reflect.Slice && typ.Elem() != byteType
+}
+
 // NextTTLV reads the next, full KMIP value off the reader.
+//
+// If the header is invalid, or the reader returns an error before the full value has been read,
+// a *DecodeError is returned, with the offset in the stream of the value's header.  The bytes
+// nested inside a Structure are not validated.  See Valid().
 func (dec *Decoder) NextTTLV() (TTLV, error) {
+	start := dec.offset
+
 	// first, read the header
-	header, err := dec.bufr.Peek(8)
+	header, err := dec.bufr.Peek(lenHeader)
 	if err != nil {
-		return nil, merry.Wrap(err)
+		if len(header) == 0 {
+			// clean end of stream
+			return nil, merry.Wrap(err)
+		}
+
+		return nil, merry.Wrap(newDecodeError(start, header, err))
 	}
 
 	if err := TTLV(header).ValidHeader(); err != nil {
 		// bad header, abort
-		return TTLV(header), merry.Prependf(err, "invalid header: %v", TTLV(header))
+		return TTLV(header), merry.Prependf(newDecodeError(start, header, err), "invalid header: %v", TTLV(header))
 	}
 
-	// allocate a buffer large enough for the entire message
 	fullLen := TTLV(header).FullLen()
+	if dec.MaxMessageBytes > 0 && fullLen > dec.MaxMessageBytes {
+		return TTLV(header), merry.Wrap(newDecodeError(start, header, ErrMaxLenExceeded)).
+			Appendf("%d bytes exceeds limit of %d bytes", fullLen, dec.MaxMessageBytes)
+	}
+
+	// allocate a buffer large enough for the entire message
 	buf := make([]byte, fullLen)
 
 	var totRead int
 
 	for {
 		n, err := dec.bufr.Read(buf[totRead:])
-		if err != nil {
-			return TTLV(buf), merry.Wrap(err)
-		}
-
+		dec.offset += int64(n)
 		totRead += n
+
 		if totRead >= fullLen {
 			// we've read off a single full message
 			return buf, nil
 		} // else keep reading
+
+		if err != nil {
+			return TTLV(buf[:totRead]), merry.Wrap(newDecodeError(start, header, err))
+		}
+	}
+}
+
+// ReadMessageBuffered reads exactly one full KMIP value from r.  It peeks at the
+// header to compute the value's full length, and then reads exactly that many bytes,
+// so afterward r is positioned at the first byte after the value.  This makes it safe to
+// read several messages from the same reader, or to mix it with other framing.  By
+// contrast, a Decoder wraps its reader in its own buffer, and may read ahead.
+//
+// If maxBytes is greater than zero, a value whose full length exceeds it is rejected with
+// ErrMaxLenExceeded, and nothing is consumed from r, like Decoder.MaxMessageBytes.  In any
+// case, the buffer for the value grows as its bytes are read, rather than being allocated up
+// front from the length in the header.
+//
+// If r is at the end of the stream, io.EOF is returned.  If the stream ends in the
+// middle of the value, the error wraps io.ErrUnexpectedEOF.  If the header is
+// invalid, nothing is consumed from r.
+func ReadMessageBuffered(r *bufio.Reader, maxBytes int) (TTLV, error) {
+	// Peek fills the buffer as needed, so this works even if the header
+	// straddles the end of the currently buffered bytes.
+	header, err := r.Peek(lenHeader)
+	if err != nil {
+		if len(header) == 0 {
+			return nil, merry.Wrap(err)
+		}
+
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+
+		return nil, merry.Prepend(err, "reading header")
+	}
+
+	if err := TTLV(header).ValidHeader(); err != nil {
+		return nil, merry.Prependf(err, "invalid header: %v", TTLV(header))
+	}
+
+	fullLen := TTLV(header).FullLen()
+	if maxBytes > 0 && fullLen > maxBytes {
+		return nil, merry.Here(ErrMaxLenExceeded).Appendf("%d bytes exceeds limit of %d bytes", fullLen, maxBytes)
+	}
+
+	buf, err := readFull(r, nil, fullLen)
+	if err != nil {
+		return buf, merry.Prependf(err, "read %d of %d bytes", len(buf), fullLen)
+	}
+
+	return buf, nil
+}
+
+// MessageReader accumulates TTLV encoded bytes which arrive in arbitrary
+// chunks, and splits them into complete, top-level TTLV values.  It is the
+// non-blocking counterpart to Decoder.NextTTLV(), for transports which deliver
+// bytes as they arrive, rather than through an io.Reader.
+//
+// Bytes are added with Feed().  Message() returns the next complete value, once
+// all its bytes have been fed.  Bytes fed after the end of one value are
+// retained for the next.
+//
+// If an invalid header is encountered, the stream can't be resynchronized,
+// so MessageReader stops returning values.  Err() returns the cause.
+//
+// A MessageReader is not safe for concurrent use.
+type MessageReader struct {
+	buf []byte
+	err error
+}
+
+// Feed appends b to the bytes buffered by the reader.  b is copied, so
+// the caller may reuse it.
+func (r *MessageReader) Feed(b []byte) {
+	if r.err != nil {
+		return
 	}
+
+	r.buf = append(r.buf, b...)
+}
+
+// Message returns the next complete TTLV value, and true, if all of its bytes
+// have been fed.  Otherwise, it returns nil, false.  The returned value is
+// not modified by subsequent calls to Feed().
+func (r *MessageReader) Message() (TTLV, bool) {
+	if r.err != nil || len(r.buf) < lenHeader {
+		return nil, false
+	}
+
+	header := TTLV(r.buf[:lenHeader])
+	if err := header.ValidHeader(); err != nil {
+		r.err = merry.Prependf(err, "invalid header: %v", header)
+		return nil, false
+	}
+
+	fullLen := header.FullLen()
+	if len(r.buf) < fullLen {
+		return nil, false
+	}
+
+	// cap the message so appends to it, or to the remaining buffer,
+	// can't overwrite each other.
+	msg := TTLV(r.buf[:fullLen:fullLen])
+
+	r.buf = r.buf[fullLen:]
+	if len(r.buf) == 0 {
+		r.buf = nil
+	}
+
+	return msg, true
+}
+
+// Buffered returns the number of bytes which have been fed, but not
+// yet returned in a message.
+func (r *MessageReader) Buffered() int {
+	return len(r.buf)
+}
+
+// Err returns the error which stopped the reader, if any.
+func (r *MessageReader) Err() error {
+	return r.err
 }
 
 func (dec *Decoder) newUnmarshalerError(ttlv TTLV, valType reflect.Type, cause error) merry.Error {
@@ -497,3 +1018,36 @@ func (e *UnmarshalerError) Error() string {
 
 	return msg
 }
+
+// DecodeError reports a value which could not be decoded, and where it is in the stream.
+// It wraps the underlying error, e.g. ErrInvalidLen or ErrValueTruncated, so it can be
+// tested with errors.Is().
+type DecodeError struct {
+	// Offset is the byte offset of the value's header, counted from the first byte the
+	// Decoder read from its reader.
+	Offset int64
+	// Tag is the tag of the value, or zero if the header was too short to contain a tag.
+	Tag Tag
+	Err error
+}
+
+func newDecodeError(offset int64, t TTLV, err error) *DecodeError {
+	return &DecodeError{
+		Offset: offset,
+		Tag:    t.Tag(),
+		Err:    err,
+	}
+}
+
+func (e *DecodeError) Error() string {
+	msg := "kmip: error decoding value at offset " + strconv.FormatInt(e.Offset, 10)
+	if e.Tag != 0 {
+		msg += " with tag " + e.Tag.String()
+	}
+
+	return msg + ": " + e.Err.Error()
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
@@ -1,13 +1,18 @@
 package ttlv_test
 
 import (
+	"bufio"
 	"bytes"
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"io"
 	"math"
 	"math/big"
 	"reflect"
+	"runtime"
 	"testing"
+	"testing/iotest"
 	"time"
 
 	"github.com/ansel1/merry"
@@ -580,3 +585,663 @@ func TestDecoder_DisallowUnknownFields(t *testing.T) {
 		})
 	}
 }
+
+func TestDecoder_DisallowDuplicateScalars(t *testing.T) {
+	type A struct {
+		Comment    string
+		BatchCount []int
+		NameValue  interface{}
+	}
+
+	b, err := Marshal(Value{TagAlternativeName, Values{
+		{TagComment, "red"},
+		{TagBatchCount, 1},
+		{TagNameValue, "green"},
+		{TagComment, "blue"},
+		{TagBatchCount, 2},
+		{TagNameValue, "yellow"},
+		{TagBatchCount, 3},
+	}})
+	require.NoError(t, err)
+
+	// scalar fields take the first occurrence, slices collect all of them
+	var a A
+	require.NoError(t, Unmarshal(b, &a))
+	assert.Equal(t, A{Comment: "red", BatchCount: []int{1, 2, 3}, NameValue: "green"}, a)
+
+	dec := NewDecoder(bytes.NewReader(b))
+	dec.DisallowDuplicateScalars = true
+	err = dec.Decode(&A{})
+	require.True(t, merry.Is(err, ErrDuplicateValue), Details(err))
+
+	var uerr *UnmarshalerError
+
+	require.True(t, errors.As(err, &uerr))
+	assert.Equal(t, "Comment", uerr.Field)
+
+	// no error without repeats in scalar fields
+	b, err = Marshal(Value{TagAlternativeName, Values{
+		{TagComment, "red"},
+		{TagBatchCount, 1},
+		{TagBatchCount, 2},
+	}})
+	require.NoError(t, err)
+
+	a = A{}
+	dec = NewDecoder(bytes.NewReader(b))
+	dec.DisallowDuplicateScalars = true
+	require.NoError(t, dec.Decode(&a))
+	assert.Equal(t, A{Comment: "red", BatchCount: []int{1, 2}}, a)
+}
+
+func TestMessageReader(t *testing.T) {
+	msg1, err := Marshal(Value{Tag: TagBatchItem, Value: Values{
+		{Tag: TagComment, Value: "red"},
+		{Tag: TagBatchCount, Value: 3},
+	}})
+	require.NoError(t, err)
+
+	msg2, err := Marshal(Value{Tag: TagComment, Value: "blue"})
+	require.NoError(t, err)
+
+	stream := append(append([]byte{}, msg1...), msg2...)
+
+	// feed the stream in every possible chunk size
+	for chunkSize := 1; chunkSize <= len(stream); chunkSize++ {
+		t.Run(fmt.Sprintf("chunk%d", chunkSize), func(t *testing.T) {
+			var r MessageReader
+
+			var msgs []TTLV
+
+			for i := 0; i < len(stream); i += chunkSize {
+				end := i + chunkSize
+				if end > len(stream) {
+					end = len(stream)
+				}
+
+				r.Feed(stream[i:end])
+
+				for {
+					msg, ok := r.Message()
+					if !ok {
+						break
+					}
+
+					msgs = append(msgs, msg)
+				}
+			}
+
+			require.NoError(t, r.Err())
+			assert.Equal(t, []TTLV{msg1, msg2}, msgs)
+			assert.Zero(t, r.Buffered())
+		})
+	}
+}
+
+func TestMessageReader_partial(t *testing.T) {
+	msg, err := Marshal(Value{Tag: TagComment, Value: "red"})
+	require.NoError(t, err)
+
+	var r MessageReader
+
+	// header, but no body
+	r.Feed(msg[:8])
+	_, ok := r.Message()
+	assert.False(t, ok)
+
+	// body, plus the start of the next message
+	r.Feed(msg[8:])
+	r.Feed(msg[:3])
+
+	m, ok := r.Message()
+	require.True(t, ok)
+	assert.Equal(t, msg, m)
+	assert.Equal(t, 3, r.Buffered())
+
+	// feeding more bytes doesn't modify the returned message
+	r.Feed(bytes.Repeat([]byte{0xff}, 20))
+	assert.Equal(t, msg, m)
+}
+
+func TestMessageReader_invalidHeader(t *testing.T) {
+	var r MessageReader
+
+	r.Feed([]byte{0x42, 0x00, 0x01, 0xff, 0x00, 0x00, 0x00, 0x00})
+
+	_, ok := r.Message()
+	assert.False(t, ok)
+	require.Error(t, r.Err())
+	assert.True(t, errors.Is(r.Err(), ErrInvalidType), Details(r.Err()))
+
+	// the reader stays failed
+	msg, err := Marshal(Value{Tag: TagComment, Value: "red"})
+	require.NoError(t, err)
+	r.Feed(msg)
+
+	_, ok = r.Message()
+	assert.False(t, ok)
+}
+
+func TestDecoder_DecodeError(t *testing.T) {
+	first, err := Marshal(Value{Tag: TagComment, Value: "red"})
+	require.NoError(t, err)
+	require.Len(t, first, 16)
+
+	second, err := Marshal(NewStruct(TagBatchItem,
+		NewValue(TagOperation, OperationGet),
+		NewValue(TagComment, "blue"),
+	))
+	require.NoError(t, err)
+
+	// the Comment claims to be longer than the enclosing BatchItem
+	binary.BigEndian.PutUint32(second[8+16+4:], 0x100)
+
+	assertDecodeError := func(t *testing.T, err error, offset int64, tag Tag, cause error) {
+		t.Helper()
+
+		var de *DecodeError
+
+		require.True(t, errors.As(err, &de), Details(err))
+		assert.Equal(t, offset, de.Offset)
+		assert.Equal(t, tag, de.Tag)
+		assert.True(t, errors.Is(err, cause), Details(err))
+	}
+
+	dec := NewDecoder(bytes.NewReader(append(append(TTLV{}, first...), second...)))
+
+	var s string
+	require.NoError(t, dec.Decode(&s))
+	assert.Equal(t, "red", s)
+	assert.EqualValues(t, 16, dec.InputOffset())
+
+	var v interface{}
+	err = dec.Decode(&v)
+	assertDecodeError(t, err, 16+8+16, TagComment, ErrValueTruncated)
+	assert.Contains(t, err.Error(), "offset 40 with tag Comment")
+
+	// invalid header
+	dec = NewDecoder(bytes.NewReader(append(append(TTLV{}, first...), 0x42, 0x00, 0x01, 0xff, 0x00, 0x00, 0x00, 0x00)))
+	require.NoError(t, dec.Decode(&s))
+
+	_, err = dec.NextTTLV()
+	assertDecodeError(t, err, 16, Tag(0x420001), ErrInvalidType)
+
+	// stream ends in the middle of a value
+	dec = NewDecoder(bytes.NewReader(append(append(TTLV{}, first...), first[:10]...)))
+	require.NoError(t, dec.Decode(&s))
+
+	_, err = dec.NextTTLV()
+	assertDecodeError(t, err, 16, TagComment, io.EOF)
+
+	// a clean end of stream is not a DecodeError
+	_, err = dec.NextTTLV()
+	assert.True(t, errors.Is(err, io.EOF), Details(err))
+
+	var de *DecodeError
+	assert.False(t, errors.As(err, &de))
+}
+
+func TestDecoder_InputOffset(t *testing.T) {
+	first, err := Marshal(Value{Tag: TagComment, Value: "red"})
+	require.NoError(t, err)
+
+	second, err := Marshal(NewStruct(TagBatchItem,
+		NewValue(TagOperation, OperationGet),
+		NewValue(TagComment, "blue"),
+	))
+	require.NoError(t, err)
+
+	in := append(append(append(TTLV{}, first...), second...), second[:20]...)
+
+	// the underlying reader returns a single byte at a time, so each value spans many
+	// reads, while the bufio.Reader inside the decoder reads ahead of what's been consumed
+	for name, r := range map[string]io.Reader{
+		"whole":   bytes.NewReader(in),
+		"onebyte": iotest.OneByteReader(bytes.NewReader(in)),
+	} {
+		t.Run(name, func(t *testing.T) {
+			dec := NewDecoder(r)
+			assert.EqualValues(t, 0, dec.InputOffset())
+
+			_, err := dec.NextTTLV()
+			require.NoError(t, err)
+			assert.EqualValues(t, len(first), dec.InputOffset())
+
+			_, err = dec.NextTTLV()
+			require.NoError(t, err)
+			assert.EqualValues(t, len(first)+len(second), dec.InputOffset())
+
+			// the stream ends part way through the third value
+			_, err = dec.NextTTLV()
+			var de *DecodeError
+			require.True(t, errors.As(err, &de), Details(err))
+			assert.EqualValues(t, len(first)+len(second), de.Offset)
+			assert.EqualValues(t, len(in), dec.InputOffset())
+		})
+	}
+}
+
+func TestDecoder_TypeCoercions(t *testing.T) {
+	type leaseInfo struct {
+		LeaseTime      time.Duration
+		ActivationDate time.Time
+		Comment        string
+	}
+
+	activation := time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	// a peer which encodes these with the wrong types
+	b, err := Marshal(NewStruct(TagRequestPayload,
+		NewValue(TagLeaseTime, int32(60)),
+		NewValue(TagActivationDate, activation.Unix()),
+		NewValue(TagComment, []byte("red")),
+	))
+	require.NoError(t, err)
+
+	orig := append(TTLV(nil), b...)
+
+	var v leaseInfo
+	err = NewDecoder(bytes.NewReader(b)).Decode(&v)
+	require.True(t, errors.Is(err, ErrUnsupportedTypeError), Details(err))
+
+	dec := NewDecoder(bytes.NewReader(b))
+	dec.TypeCoercions = map[Tag]Type{
+		TagLeaseTime:      TypeInterval,
+		TagActivationDate: TypeDateTime,
+		TagComment:        TypeTextString,
+	}
+	require.NoError(t, dec.Decode(&v))
+	assert.Equal(t, time.Minute, v.LeaseTime)
+	assert.True(t, activation.Equal(v.ActivationDate), v.ActivationDate)
+	assert.Equal(t, "red", v.Comment)
+	assert.Equal(t, orig, TTLV(b), "input should not be modified")
+
+	t.Run("interface", func(t *testing.T) {
+		b, err := Marshal(Value{Tag: TagBatchCount, Value: EnumValue(3)})
+		require.NoError(t, err)
+
+		var v interface{}
+
+		dec := NewDecoder(nil)
+		dec.TypeCoercions = map[Tag]Type{TagBatchCount: TypeInteger}
+		require.NoError(t, dec.DecodeValue(&v, b))
+		assert.Equal(t, int32(3), v)
+	})
+
+	t.Run("incompatible", func(t *testing.T) {
+		for name, tc := range map[string]struct {
+			in Value
+			to Type
+		}{
+			"sizediffers": {Value{Tag: TagBatchCount, Value: int32(3)}, TypeLongInteger},
+			"notutf8":     {Value{Tag: TagBatchCount, Value: []byte{0xff, 0xfe}}, TypeTextString},
+			"structure":   {NewStruct(TagBatchCount), TypeTextString},
+		} {
+			t.Run(name, func(t *testing.T) {
+				b, err := Marshal(tc.in)
+				require.NoError(t, err)
+
+				var v interface{}
+
+				dec := NewDecoder(nil)
+				dec.TypeCoercions = map[Tag]Type{TagBatchCount: tc.to}
+				err = dec.DecodeValue(&v, b)
+				require.True(t, errors.Is(err, ErrInvalidCoercion), Details(err))
+			})
+		}
+	})
+}
+
+func TestReadMessageBuffered(t *testing.T) {
+	first, err := Marshal(Value{Tag: TagComment, Value: "red"})
+	require.NoError(t, err)
+
+	second, err := Marshal(NewStruct(TagBatchItem,
+		NewValue(TagOperation, OperationGet),
+		NewValue(TagComment, "blue"),
+	))
+	require.NoError(t, err)
+
+	// a one byte preamble, so the headers don't line up with the 16 byte buffer,
+	// and some other framing after the messages
+	in := append(append(append([]byte{0xff}, first...), second...), "trailer"...)
+
+	for name, r := range map[string]io.Reader{
+		"whole":   bytes.NewReader(in),
+		"onebyte": iotest.OneByteReader(bytes.NewReader(in)),
+	} {
+		t.Run(name, func(t *testing.T) {
+			bufr := bufio.NewReaderSize(r, 16)
+
+			b, err := bufr.ReadByte()
+			require.NoError(t, err)
+			assert.EqualValues(t, 0xff, b)
+
+			msg, err := ReadMessageBuffered(bufr, 0)
+			require.NoError(t, err)
+			assert.Equal(t, TTLV(first), msg)
+
+			msg, err = ReadMessageBuffered(bufr, 0)
+			require.NoError(t, err)
+			assert.Equal(t, TTLV(second), msg)
+
+			// the reader is positioned right after the second message
+			rest, err := io.ReadAll(bufr)
+			require.NoError(t, err)
+			assert.Equal(t, "trailer", string(rest))
+
+			_, err = ReadMessageBuffered(bufr, 0)
+			assert.True(t, errors.Is(err, io.EOF), Details(err))
+		})
+	}
+
+	t.Run("truncated", func(t *testing.T) {
+		_, err := ReadMessageBuffered(bufio.NewReader(bytes.NewReader(second[:4])), 0)
+		assert.True(t, errors.Is(err, io.ErrUnexpectedEOF), Details(err))
+
+		msg, err := ReadMessageBuffered(bufio.NewReader(bytes.NewReader(second[:20])), 0)
+		assert.True(t, errors.Is(err, io.ErrUnexpectedEOF), Details(err))
+		assert.Equal(t, TTLV(second[:20]), msg)
+	})
+
+	t.Run("invalidheader", func(t *testing.T) {
+		bufr := bufio.NewReader(bytes.NewReader([]byte("not a ttlv value")))
+
+		_, err := ReadMessageBuffered(bufr, 0)
+		require.Error(t, err)
+
+		// nothing was consumed
+		assert.Equal(t, 16, bufr.Buffered())
+	})
+
+	t.Run("maxbytes", func(t *testing.T) {
+		bufr := bufio.NewReader(bytes.NewReader(second))
+
+		_, err := ReadMessageBuffered(bufr, len(second)-1)
+		require.True(t, errors.Is(err, ErrMaxLenExceeded), Details(err))
+
+		// nothing was consumed, so the message can still be read with a higher limit
+		msg, err := ReadMessageBuffered(bufr, len(second))
+		require.NoError(t, err)
+		assert.Equal(t, TTLV(second), msg)
+
+		// a header claiming a huge length doesn't allocate more than the bytes received
+		huge := []byte{0x42, 0x00, 0x41, 0x07, 0x7f, 0xff, 0xff, 0xf0, 'r', 'e', 'd'}
+		allocated := bytesAllocated(func() {
+			_, err = ReadMessageBuffered(bufio.NewReader(bytes.NewReader(huge)), 0)
+		})
+		require.True(t, errors.Is(err, io.ErrUnexpectedEOF), Details(err))
+		assert.Less(t, allocated, uint64(1<<20))
+	})
+}
+
+// flatStruct resembles a typical GetAttributes response payload: a single
+// Structure with a handful of leaf values and no nesting.
+type flatStruct struct {
+	UniqueIdentifier       string
+	ObjectType             ObjectType
+	CryptographicAlgorithm CryptographicAlgorithm
+	CryptographicLength    int
+	CryptographicUsageMask CryptographicUsageMask
+	State                  State
+	InitialDate            time.Time
+	ActivationDate         time.Time
+	LastChangeDate         time.Time
+	Fresh                  bool
+	Digest                 []byte
+}
+
+func BenchmarkUnmarshal_flatStruct(b *testing.B) {
+	now := time.Now().Truncate(time.Second).UTC()
+
+	v := flatStruct{
+		UniqueIdentifier:       "c4e3ec4a-8b8f-4d5c-9e0b-37a3c3f4ebd1",
+		ObjectType:             ObjectTypeSymmetricKey,
+		CryptographicAlgorithm: CryptographicAlgorithmAES,
+		CryptographicLength:    256,
+		CryptographicUsageMask: CryptographicUsageMaskEncrypt | CryptographicUsageMaskDecrypt,
+		State:                  StateActive,
+		InitialDate:            now,
+		ActivationDate:         now,
+		LastChangeDate:         now,
+		Fresh:                  true,
+		Digest:                 []byte("0123456789abcdef0123456789abcdef"),
+	}
+
+	buf, err := Marshal(NewStruct(TagResponsePayload,
+		NewValue(TagUniqueIdentifier, v.UniqueIdentifier),
+		NewValue(TagObjectType, v.ObjectType),
+		NewValue(TagCryptographicAlgorithm, v.CryptographicAlgorithm),
+		NewValue(TagCryptographicLength, v.CryptographicLength),
+		NewValue(TagCryptographicUsageMask, v.CryptographicUsageMask),
+		NewValue(TagState, v.State),
+		NewValue(TagInitialDate, v.InitialDate),
+		NewValue(TagActivationDate, v.ActivationDate),
+		NewValue(TagLastChangeDate, v.LastChangeDate),
+		NewValue(TagFresh, v.Fresh),
+		NewValue(TagDigest, v.Digest),
+	))
+	require.NoError(b, err)
+
+	var out flatStruct
+
+	require.NoError(b, Unmarshal(buf, &out))
+	require.Equal(b, v, out)
+
+	dec := NewDecoder(nil)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		out = flatStruct{}
+		if err := dec.DecodeValue(&out, buf); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestDecoder_MaxMessageBytes(t *testing.T) {
+	msg, err := Marshal(NewStruct(TagBatchItem,
+		NewValue(TagOperation, OperationGet),
+		NewStruct(TagRequestPayload,
+			NewValue(TagUniqueIdentifier, "1"),
+		),
+	))
+	require.NoError(t, err)
+
+	// a limit equal to the message length is fine
+	dec := NewDecoder(bytes.NewReader(msg))
+	dec.MaxMessageBytes = len(msg)
+
+	var v interface{}
+	require.NoError(t, dec.Decode(&v))
+
+	// the limit applies to the whole message, though each nested value is smaller
+	dec = NewDecoder(bytes.NewReader(msg))
+	dec.MaxMessageBytes = len(msg) - 8
+
+	err = dec.Decode(&v)
+	require.True(t, errors.Is(err, ErrMaxLenExceeded), Details(err))
+
+	var de *DecodeError
+	require.True(t, errors.As(err, &de))
+	assert.Equal(t, TagBatchItem, de.Tag)
+
+	// the header is checked before reading the value, so a header alone is enough
+	dec = NewDecoder(bytes.NewReader([]byte{0x42, 0x00, 0x0f, 0x01, 0x7f, 0xff, 0xff, 0xf0}))
+	dec.MaxMessageBytes = 1 << 20
+
+	_, err = dec.NextTTLV()
+	require.True(t, errors.Is(err, ErrMaxLenExceeded), Details(err))
+}
+
+func TestReadValue(t *testing.T) {
+	type batchItem struct {
+		Operation         Operation
+		UniqueBatchItemID []byte
+	}
+
+	var buf bytes.Buffer
+
+	in := Value{Tag: TagBatchItem, Value: batchItem{Operation: OperationGet, UniqueBatchItemID: []byte{1}}}
+	require.NoError(t, WriteValue(&buf, in))
+	require.NoError(t, WriteValue(&buf, Value{Tag: TagComment, Value: "red"}))
+
+	// each call reads exactly one value
+	var out batchItem
+	require.NoError(t, ReadValue(&buf, &out, 0))
+	assert.Equal(t, in.Value, out)
+
+	var s string
+	require.NoError(t, ReadValue(&buf, &s, 0))
+	assert.Equal(t, "red", s)
+
+	err := ReadValue(&buf, &s, 0)
+	require.True(t, errors.Is(err, io.EOF), Details(err))
+
+	// truncated value
+	b, err := Marshal(Value{Tag: TagComment, Value: "red"})
+	require.NoError(t, err)
+
+	err = ReadValue(bytes.NewReader(b[:10]), &s, 0)
+	require.True(t, errors.Is(err, io.ErrUnexpectedEOF), Details(err))
+
+	var de *DecodeError
+	require.True(t, errors.As(err, &de))
+	assert.Equal(t, TagComment, de.Tag)
+
+	// invalid header
+	err = ReadValue(bytes.NewReader([]byte{0x42, 0x00, 0x01, 0xff, 0x00, 0x00, 0x00, 0x00}), &s, 0)
+	require.True(t, errors.Is(err, ErrInvalidType), Details(err))
+
+	// values longer than the limit are rejected before they are read
+	buf.Reset()
+	require.NoError(t, WriteValue(&buf, Value{Tag: TagComment, Value: "red"}))
+	err = ReadValue(&buf, &s, 15)
+	require.True(t, errors.Is(err, ErrMaxLenExceeded), Details(err))
+	assert.Equal(t, 8, buf.Len())
+
+	buf.Reset()
+	require.NoError(t, WriteValue(&buf, Value{Tag: TagComment, Value: "red"}))
+	require.NoError(t, ReadValue(&buf, &s, 16))
+
+	// a header claiming a huge length doesn't allocate more than the bytes received
+	huge := []byte{0x42, 0x00, 0x41, 0x07, 0x7f, 0xff, 0xff, 0xf0, 'r', 'e', 'd'}
+	allocated := bytesAllocated(func() {
+		err = ReadValue(bytes.NewReader(huge), &s, 0)
+	})
+	require.True(t, errors.Is(err, io.ErrUnexpectedEOF), Details(err))
+	assert.Less(t, allocated, uint64(1<<20))
+}
+
+// bytesAllocated returns the number of bytes of heap memory allocated while running fn.
+func bytesAllocated(fn func()) uint64 {
+	var before, after runtime.MemStats
+
+	runtime.ReadMemStats(&before)
+	fn()
+	runtime.ReadMemStats(&after)
+
+	return after.TotalAlloc - before.TotalAlloc
+}
+
+func TestDecoder_Defaults(t *testing.T) {
+	type header struct {
+		BatchOrderOption bool `ttlv:",omitempty"`
+		BatchCount       int
+		Comment          string `ttlv:",omitempty"`
+		Operation        []Operation
+	}
+
+	defaults := map[Tag]interface{}{
+		TagBatchOrderOption: true,
+		TagBatchCount:       1,
+		TagComment:          "none",
+		TagOperation:        OperationGet,
+	}
+
+	b, err := Marshal(NewStruct(TagRequestHeader,
+		NewValue(TagBatchCount, 0),
+	))
+	require.NoError(t, err)
+
+	// defaults are opt-in
+	var v header
+	require.NoError(t, Unmarshal(b, &v))
+	assert.Equal(t, header{}, v)
+
+	// absent values are set to their defaults, but zero values which are present
+	// are kept, and slices aren't filled in
+	dec := NewDecoder(nil)
+	dec.Defaults = defaults
+	require.NoError(t, dec.DecodeValue(&v, b))
+	assert.Equal(t, header{BatchOrderOption: true, Comment: "none"}, v)
+
+	// the default must be decodable into the field
+	dec.Defaults = map[Tag]interface{}{TagComment: 5}
+	err = dec.DecodeValue(&v, b)
+	require.Error(t, err)
+	require.True(t, errors.Is(err, ErrUnsupportedTypeError), Details(err))
+}
+
+func TestDecoder_MaxBigIntegerBytes(t *testing.T) {
+	type key struct {
+		Modulus *big.Int
+	}
+
+	modulus := new(big.Int).Lsh(big.NewInt(1), 2047)
+
+	b, err := Marshal(Value{Tag: TagPrivateKey, Value: key{Modulus: modulus}})
+	require.NoError(t, err)
+
+	// no limit by default
+	var v key
+	require.NoError(t, Unmarshal(b, &v))
+	assert.Equal(t, modulus, v.Modulus)
+
+	// a 2048 bit value is 264 bytes long, since it needs a sign byte, padded
+	// to a multiple of 8
+	dec := NewDecoder(nil)
+	dec.MaxBigIntegerBytes = 264
+	require.NoError(t, dec.DecodeValue(&v, b))
+
+	dec.MaxBigIntegerBytes = 256
+	err = dec.DecodeValue(&v, b)
+	require.True(t, errors.Is(err, ErrMaxLenExceeded), Details(err))
+
+	// the limit applies when decoding into interface{} too
+	var i interface{}
+
+	b, err = Marshal(Value{Tag: TagModulus, Value: modulus})
+	require.NoError(t, err)
+
+	err = dec.DecodeValue(&i, b)
+	require.True(t, errors.Is(err, ErrMaxLenExceeded), Details(err))
+	assert.Nil(t, i)
+}
+
+func BenchmarkTTLV_ValueBigInteger(b *testing.B) {
+	for _, size := range []int{8, 256, 1 << 20} {
+		value := make([]byte, size)
+		value[0] = 0x40
+
+		// a negative value which is all padding, the worst case for unpadding
+		padded := bytes.Repeat([]byte{0xff}, size)
+
+		for name, v := range map[string][]byte{"value": value, "padding": padded} {
+			bi := make(TTLV, 8+size)
+			copy(bi, []byte{0x42, 0x00, 0x52, byte(TypeBigInteger)})
+			binary.BigEndian.PutUint32(bi[4:8], uint32(size))
+			copy(bi[8:], v)
+
+			b.Run(fmt.Sprintf("%s/%d", name, size), func(b *testing.B) {
+				b.SetBytes(int64(size))
+
+				for i := 0; i < b.N; i++ {
+					_ = bi.ValueBigInteger()
+				}
+			})
+		}
+	}
+}
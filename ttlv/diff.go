@@ -0,0 +1,129 @@
+package ttlv
+
+import (
+	"bytes"
+	"strings"
+)
+
+// Equal returns true if t and other encode the same value.  Both are compared in
+// their canonical form (see Canonical()), so encodings which differ only in pad
+// bytes, BigInteger sign extension, or Boolean representation are equal.  Only the
+// first value in each is compared.  If either value is invalid, they are only equal
+// if their bytes are identical.
+func (t TTLV) Equal(other TTLV) bool {
+	c1, err1 := t.Canonical()
+	c2, err2 := other.Canonical()
+
+	if err1 != nil || err2 != nil {
+		return bytes.Equal(t, other)
+	}
+
+	return bytes.Equal(c1, c2)
+}
+
+// Diff returns a human readable description of the differences between two TTLV
+// values, or "" if they are Equal().  Each value is printed in the format of PrintFlat(),
+// so each line is annotated with the path of tags leading to the value.  Lines only
+// in expected are prefixed with "- ", and lines only in actual with "+ ".  Lines
+// common to both are omitted.
+//
+// Finding the smallest diff takes memory proportional to the product of the number of
+// differing lines in each value, so if that exceeds maxDiffCells, every differing line is
+// listed instead.
+func Diff(expected, actual TTLV) string {
+	if expected.Equal(actual) {
+		return ""
+	}
+
+	expLines := flatLines(expected)
+	actLines := flatLines(actual)
+
+	var sb strings.Builder
+
+	// lines common to the start and end of both values are omitted, and don't need to
+	// be part of the (quadratic) search below
+	for len(expLines) > 0 && len(actLines) > 0 && expLines[0] == actLines[0] {
+		expLines, actLines = expLines[1:], actLines[1:]
+	}
+
+	for len(expLines) > 0 && len(actLines) > 0 && expLines[len(expLines)-1] == actLines[len(actLines)-1] {
+		expLines, actLines = expLines[:len(expLines)-1], actLines[:len(actLines)-1]
+	}
+
+	if (len(expLines)+1)*(len(actLines)+1) > maxDiffCells {
+		for _, l := range expLines {
+			sb.WriteString("- " + l + "\n")
+		}
+
+		for _, l := range actLines {
+			sb.WriteString("+ " + l + "\n")
+		}
+	} else {
+		writeLCSDiff(&sb, expLines, actLines)
+	}
+
+	if sb.Len() == 0 {
+		// the values differ in ways PrintFlat doesn't show, e.g. trailing bytes after an invalid value
+		sb.WriteString("- " + expected.String() + "\n")
+		sb.WriteString("+ " + actual.String() + "\n")
+	}
+
+	return sb.String()
+}
+
+// maxDiffCells limits the size of the table Diff uses to find the smallest diff, to
+// about 32MB.
+const maxDiffCells = 1 << 22
+
+// writeLCSDiff writes the lines which aren't in the longest common subsequence of
+// expLines and actLines to sb.
+func writeLCSDiff(sb *strings.Builder, expLines, actLines []string) {
+	// longest common subsequence of the lines, computed from the end backwards,
+	// so the diff can then be walked from the start
+	lcs := make([][]int, len(expLines)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(actLines)+1)
+	}
+
+	for i := len(expLines) - 1; i >= 0; i-- {
+		for j := len(actLines) - 1; j >= 0; j-- {
+			switch {
+			case expLines[i] == actLines[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(expLines) || j < len(actLines) {
+		switch {
+		case i < len(expLines) && j < len(actLines) && expLines[i] == actLines[j]:
+			i++
+			j++
+		case j == len(actLines) || (i < len(expLines) && lcs[i+1][j] >= lcs[i][j+1]):
+			sb.WriteString("- " + expLines[i] + "\n")
+			i++
+		default:
+			sb.WriteString("+ " + actLines[j] + "\n")
+			j++
+		}
+	}
+}
+
+func flatLines(t TTLV) []string {
+	if c, err := t.Canonical(); err == nil {
+		t = c
+	}
+
+	var buf bytes.Buffer
+
+	// PrintFlat only returns an error if t is invalid, in which case the error is
+	// printed in the output
+	_ = PrintFlat(&buf, t)
+
+	return strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+}
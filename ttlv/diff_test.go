@@ -0,0 +1,55 @@
+package ttlv_test
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/gemalto/kmip-go/kmip14"
+	. "github.com/gemalto/kmip-go/ttlv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+	msg := func(op Operation, comments ...string) TTLV {
+		item := NewStruct(TagBatchItem, NewValue(TagOperation, op))
+		for _, c := range comments {
+			item.Value = append(item.Value.(Values), NewValue(TagComment, c))
+		}
+
+		b, err := Marshal(NewStruct(TagRequestMessage, item))
+		require.NoError(t, err)
+
+		return b
+	}
+
+	expected := msg(OperationGet, "red", "green")
+
+	// identical, and differing only in pad bytes
+	padded := append(TTLV{}, expected...)
+	padded[len(padded)-1] = 0xff
+
+	assert.True(t, expected.Equal(padded))
+	assert.Empty(t, Diff(expected, padded))
+
+	diff := Diff(expected, msg(OperationCreate, "red", "blue", "green"))
+	assert.Equal(t, `- RequestMessage.BatchItem.Operation	Enumeration	Get
++ RequestMessage.BatchItem.Operation	Enumeration	Create
+- RequestMessage.BatchItem.Comment[1]	TextString	"green"
++ RequestMessage.BatchItem.Comment[1]	TextString	"blue"
++ RequestMessage.BatchItem.Comment[2]	TextString	"green"
+`, diff)
+
+	// large diffs list every differing line, rather than finding the smallest diff
+	var red, blue []string
+	for i := 0; i < 3000; i++ {
+		red = append(red, "red")
+		blue = append(blue, "blue")
+	}
+
+	diff = Diff(msg(OperationGet, red...), msg(OperationGet, blue...))
+	lines := strings.Split(strings.TrimSuffix(diff, "\n"), "\n")
+	require.Len(t, lines, 6000)
+	assert.Equal(t, `- RequestMessage.BatchItem.Comment[0]	TextString	"red"`, lines[0])
+	assert.Equal(t, `+ RequestMessage.BatchItem.Comment[2999]	TextString	"blue"`, lines[5999])
+}
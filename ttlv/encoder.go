@@ -5,11 +5,13 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"math"
 	"math/big"
 	"reflect"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/ansel1/merry"
@@ -23,13 +25,22 @@ var (
 	ErrUnsupportedTypeError     = errors.New("marshaling/unmarshaling is not supported for this type")
 	ErrNoTag                    = errors.New("unable to determine tag for field")
 	ErrTagConflict              = errors.New("tag conflict")
+	ErrIntervalOverflow         = errors.New("duration is negative or exceeds MaxInterval")
 )
 
+// MaxInterval is the longest duration which can be encoded as an Interval.  Intervals
+// are unsigned, 32 bit counts of seconds, so they can't hold negative durations, or
+// durations longer than about 136 years.  Fractions of seconds are truncated.
+const MaxInterval = time.Duration(math.MaxUint32) * time.Second
+
+func validInterval(d time.Duration) bool {
+	return d >= 0 && d/time.Second <= MaxInterval/time.Second
+}
+
 // Marshal encodes a golang value into a KMIP value.
 //
 // An error will be returned if v is an invalid pointer.
 //
-// Currently, Marshal does not support anonymous fields.
 // Private fields are ignored.
 //
 // Marshal maps the golang value to a KMIP tag, type, and value
@@ -39,20 +50,32 @@ var (
 // The appropriate type and encoding are inferred from the golang type
 // and from the inferred KMIP tag, according to these rules:
 //
-// 1. If the value is a TTLV, it is copied byte for byte
-// 2. If the value implements Marshaler, call that
-// 3. If the struct field has an "omitempty" flag, and the value is
-//    zero, skip the field:
+// 1. If the value is a TTLV, it is copied byte for byte.  If it is a struct
+//    field, it must be valid, and its tag must match the field's tag, unless
+//    the field has the "any" flag.  With the "omitempty" flag, it is skipped
+//    if TTLV.IsZeroValue() is true.
+// 2. If the value is a nil pointer, nil interface, or nil slice, nothing is
+//    encoded.  Non-nil pointers and interfaces are encoded as the value they
+//    point to.
+// 3. If the value implements Marshaler, call that
+// 4. If the struct field has an "omitempty" flag, and the value is
+//    zero, skip the field.  For pointer fields, this applies to the value
+//    pointed to.  Values are zero according to golang, e.g. a time.Time is zero
+//    if IsZero() is true, not if it is the epoch.  Only TTLV fields use the KMIP
+//    definition of zero in rule 1, in which the zero DateTime is the epoch.
+//    A struct value is zero if all its fields are zero:
 //
 //        type Foo struct {
 //            Comment string `ttlv:,omitempty`
 //        }
 //
-// 4. If the value is a slice (except []byte)  or array, marshal all
-//    values concatenated
-// 5. If a tag has not been inferred at this point, return *MarshalerError with
+// 5. If the value is a slice (except []byte)  or array, marshal all
+//    values concatenated.  An empty slice encodes nothing, with or
+//    without "omitempty".  Note that an empty, non-nil []byte encodes as an
+//    empty ByteString, unless the field has the "omitempty" flag.
+// 6. If a tag has not been inferred at this point, return *MarshalerError with
 //    cause ErrNoTag
-// 6. If the Tag is registered as an enum, or has the "enum" struct tag flag, attempt
+// 7. If the Tag is registered as an enum, or has the "enum" struct tag flag, attempt
 //    to marshal as an Enumeration.  int, int8, int16, int32, and their uint counterparts
 //    can be marshaled as an Enumeration.  A string can be marshaled to an Enumeration
 //    if the string contains a number, a 4 byte (8 char) hex string with the prefix "0x",
@@ -67,10 +90,22 @@ var (
 //                                          // as an enumeration.
 //         }
 //
+//   This applies to named integer types too, so constants of a type like
+//   "type KeyFormatType uint32" encode as Enumerations without any wrapping, and decode
+//   back into the named type.
+//
 //   If the string can't be interpreted as an enum value, it will be encoded as a TextString.  If
 //   the "enum" struct flag is set, the value *must* successfully encode to an Enumeration using
 //   above rules, or an error is returned.
-// 7. If the Tag is registered as a bitmask, or has the "bitmask" struct tag flag, attempt
+//
+//   The "integer" struct flag disables this rule and the next, so the value is encoded according
+//   to its golang type, e.g. an int field encodes as an Integer even if its tag is registered
+//   as an enum.  It can't be combined with the "enum" or "bitmask" flags:
+//
+//         type Foo struct {
+//             KeyFormatType KeyFormatType `ttlv:",integer"`  // encodes as an Integer
+//         }
+// 8. If the Tag is registered as a bitmask, or has the "bitmask" struct tag flag, attempt
 //    to marshal to an Integer, following the same rules as for Enumerations.  The ParseInt()
 //    function is used to parse string values.
 // 9. time.Time marshals to DateTime.  If the field has the "datetimeextended" struct flag,
@@ -81,7 +116,8 @@ var (
 //         }
 //
 // 10. big.Int marshals to BigInteger
-// 11. time.Duration marshals to Interval
+// 11. time.Duration marshals to Interval.  If the duration is negative or exceeds
+//     MaxInterval, *MarshalerError with cause ErrIntervalOverflow is returned
 // 12. string marshals to TextString
 // 13. []byte marshals to ByteString
 // 14. all int and uint variants except int64 and uint64 marshal to Integer.  If the golang
@@ -91,6 +127,43 @@ var (
 // 17. structs marshal to Structure.  Each field of the struct will be marshaled into the
 //     values of the Structure according to the above rules.
 //
+//     Like encoding/json, the fields of an embedded struct, or pointer to a struct, are
+//     flattened: they are marshaled as if they were fields of the enclosing struct, at the
+//     position of the embedded field.  If the enclosing struct has a field with the same name,
+//     it takes precedence.  If several embedded structs at the same depth have fields with the
+//     same name, and none or more than one of them has an explicit TTLV tag, the fields
+//     conflict and are all ignored.  If the embedded field is a nil pointer, its fields are
+//     skipped.  An embedded field whose struct tag
+//     specifies a TTLV tag isn't flattened, and is marshaled as a nested Structure, like a
+//     named field:
+//
+//         type Common struct {
+//             UniqueIdentifier string
+//         }
+//
+//         type Foo struct {
+//             Common                       // UniqueIdentifier is a value of Foo's Structure
+//         }
+//
+//         type Bar struct {
+//             Common `ttlv:"Attribute"`    // encodes as an Attribute Structure, containing
+//                                          // the UniqueIdentifier
+//         }
+//
+//     Unmarshal follows the same rules, allocating embedded pointers as needed.
+//
+//     Fields with the "any" struct flag are marshaled like other fields.  Since Unmarshal
+//     uses them to capture the values which don't match any other field (see Unmarshal), a
+//     field of type []TTLV with the "any" flag can pass unrecognized values, like vendor
+//     extensions, through a decode and re-encode.  The values are re-emitted in the order
+//     they were decoded, at the position of the field, so declare it last to re-emit
+//     them at the end of the Structure:
+//
+//         type Foo struct {
+//             UniqueIdentifier string
+//             Extra []TTLV `ttlv:",any"`
+//         }
+//
 // Any other golang type will return *MarshalerError with cause ErrUnsupportedTypeError.
 func Marshal(v interface{}) (TTLV, error) {
 	buf := bytes.NewBuffer(nil)
@@ -103,6 +176,28 @@ func Marshal(v interface{}) (TTLV, error) {
 	return buf.Bytes(), nil
 }
 
+// WriteValue marshals v and writes it to w.  It's shorthand for NewEncoder(w).Encode(v).
+// See Marshal for encoding rules.  ReadValue reads the value back.
+func WriteValue(w io.Writer, v interface{}) error {
+	return NewEncoder(w).Encode(v)
+}
+
+// EncodeTo marshals v and writes it to w, writing the same bytes to h as they are written to w,
+// so the digest of the encoded value can be computed (e.g. for a MAC) without a second pass over
+// the bytes.  h covers exactly the bytes written to w, in order.  If h is nil, it's equivalent to
+// WriteValue.
+//
+// The value is still encoded into the encoder's buffer before being written, since the length of
+// a Structure is only known once its contents have been encoded.  If there is an error encoding v,
+// nothing is written to either w or h.
+func EncodeTo(w io.Writer, h hash.Hash, v interface{}) error {
+	if h != nil {
+		w = io.MultiWriter(w, h)
+	}
+
+	return NewEncoder(w).Encode(v)
+}
+
 // Marshaler knows how to encode itself to TTLV.
 // The implementation should use the primitive methods of the encoder,
 // such as EncodeInteger(), etc.
@@ -164,7 +259,18 @@ func (e *Encoder) EncodeLongInteger(tag Tag, v int64) {
 	e.encBuf.encodeLongInt(tag, v)
 }
 
+// EncodeInterval encodes v as an Interval, truncated to whole seconds.  If v is negative or
+// exceeds MaxInterval, nothing is encoded, and the next call to Flush returns
+// ErrIntervalOverflow and discards the buffered values.
 func (e *Encoder) EncodeInterval(tag Tag, v time.Duration) {
+	if !validInterval(v) {
+		if e.err == nil {
+			e.err = merry.Here(ErrIntervalOverflow).Appendf("%s: %v", tag.String(), v)
+		}
+
+		return
+	}
+
 	e.encBuf.encodeInterval(tag, v)
 }
 
@@ -192,12 +298,116 @@ func (e *Encoder) EncodeByteString(tag Tag, v []byte) {
 	e.encBuf.encodeByteString(tag, v)
 }
 
+// EncodeByteStringReader encodes a ByteString with the given tag, reading the value
+// from r.  The length of the value must be known in advance, since it is written in the
+// header before the value.  If r returns fewer than length bytes, an error wrapping
+// io.ErrUnexpectedEOF is returned.
+//
+// Outside of EncodeStructure(), any buffered values are flushed first, and then the value
+// is streamed from r directly to the writer, so large values don't need to be held in
+// memory.  Inside a Structure, the enclosing Structure's length isn't known until it ends,
+// so the value is copied into the internal buffer, the same as EncodeByteString().
+//
+// When streaming, the header is written before the value is read, so if r returns fewer
+// than length bytes, the header and the bytes read have already been written, and the
+// writer is left holding an incomplete value which can't be decoded.  The output should be
+// discarded, e.g. by closing the connection.  Inside a Structure, the incomplete value is
+// removed from the buffer, and encoding can continue.
+func (e *Encoder) EncodeByteStringReader(tag Tag, r io.Reader, length int) error {
+	if length < 0 || length > MaxFullLen-lenHeader-7 {
+		return merry.Appendf(ErrInvalidLen, "%d", length)
+	}
+
+	if e.encodeDepth > 0 {
+		i := e.encBuf.begin(tag, TypeByteString)
+
+		if _, err := io.CopyN(&e.encBuf, r, int64(length)); err != nil {
+			e.encBuf.Truncate(i - lenHeader)
+			return byteStringReadErr(err)
+		}
+
+		e.encBuf.end(i)
+
+		return nil
+	}
+
+	if err := e.Flush(); err != nil {
+		return err
+	}
+
+	var hdr [lenHeader]byte
+
+	hdr[0], hdr[1], hdr[2] = byte(tag>>16), byte(tag>>8), byte(tag)
+	hdr[3] = byte(TypeByteString)
+	binary.BigEndian.PutUint32(hdr[4:], uint32(length))
+
+	if _, err := e.w.Write(hdr[:]); err != nil {
+		return merry.Wrap(err)
+	}
+
+	if _, err := io.CopyN(e.w, r, int64(length)); err != nil {
+		return byteStringReadErr(err)
+	}
+
+	if m := length % 8; m > 0 {
+		if _, err := e.w.Write(zeros[:8-m]); err != nil {
+			return merry.Wrap(err)
+		}
+	}
+
+	return nil
+}
+
+func byteStringReadErr(err error) error {
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+
+	return merry.Prepend(err, "reading ByteString value")
+}
+
 // Flush flushes the internal encoding buffer to the writer.
 func (e *Encoder) Flush() error {
 	if e.encodeDepth > 0 {
 		return nil
 	}
 
+	if err := e.err; err != nil {
+		e.err = nil
+		e.encBuf.Reset()
+
+		return err
+	}
+
+	r := e.Registry
+	if r == nil {
+		r = &DefaultRegistry
+	}
+
+	if e.ValidateTypes {
+		if err := r.ValidateTypes(e.encBuf.Bytes()); err != nil {
+			e.encBuf.Reset()
+			return err
+		}
+	}
+
+	switch {
+	case e.Reorder:
+		b, err := r.Reorder(e.encBuf.Bytes())
+		if err != nil {
+			e.encBuf.Reset()
+			return err
+		}
+
+		e.encBuf.Reset()
+		_, _ = e.encBuf.Write(b)
+	case e.ValidateOrder:
+		if err := r.ValidateOrder(e.encBuf.Bytes()); err != nil {
+			e.encBuf.Reset()
+			return err
+		}
+	}
+
 	_, err := e.encBuf.WriteTo(e.w)
 	e.encBuf.Reset()
 
@@ -247,6 +457,7 @@ var (
 	bigIntType      = bigIntPtrType.Elem()
 	durationType    = reflect.TypeOf(time.Nanosecond)
 	ttlvType        = reflect.TypeOf((*TTLV)(nil)).Elem()
+	rawTTLVType     = reflect.TypeOf(RawTTLV{})
 	tagType         = reflect.TypeOf(Tag(0))
 )
 
@@ -284,6 +495,9 @@ func indirect(v reflect.Value) reflect.Value {
 
 var zeroBigInt = big.Int{}
 
+// isEmptyValue reports whether v is golang's zero value of its type, for the
+// "omitempty" flag.  Unlike TTLV.IsZeroValue, a time.Time is empty if it's the
+// zero time.Time, not the epoch.
 func isEmptyValue(v reflect.Value) bool {
 	switch v.Kind() {
 	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
@@ -309,9 +523,38 @@ func isEmptyValue(v reflect.Value) bool {
 		return zeroBigInt.Cmp(&i) == 0
 	}
 
+	if v.Kind() == reflect.Struct {
+		return v.IsZero()
+	}
+
 	return false
 }
 
+// encodeTTLV copies a pre-encoded value to the output.  If the value is
+// a struct field, it is validated first, so a corrupt value can't
+// corrupt the enclosing structure, and its tag must match the field's
+// tag, unless the field has the "any" flag.  With the "omitempty" flag,
+// values which are zero according to TTLV.IsZeroValue are skipped.
+func (e *Encoder) encodeTTLV(t TTLV, fi *fieldInfo) error {
+	if len(t) == 0 || (fi != nil && fi.flags.omitEmpty() && t.IsZeroValue()) {
+		return nil
+	}
+
+	if fi != nil {
+		if err := t.Valid(); err != nil {
+			return e.marshalingError(fi.tag, ttlvType, err)
+		}
+
+		if !fi.flags.any() && fi.tag != TagNone && t.Tag() != fi.tag {
+			return e.marshalingError(fi.tag, ttlvType, ErrTagConflict).Appendf("field tag is %s, but TTLV value's tag is %s", fi.tag, t.Tag())
+		}
+	}
+
+	_, err := e.encBuf.Write(t)
+
+	return err
+}
+
 func (e *Encoder) encode(tag Tag, v reflect.Value, fi *fieldInfo) error {
 	// if pointer or interface
 	v = indirect(v)
@@ -323,8 +566,7 @@ func (e *Encoder) encode(tag Tag, v reflect.Value, fi *fieldInfo) error {
 
 	if typ == ttlvType {
 		// fast path: if the value is TTLV, we write it directly to the output buffer
-		_, err := e.encBuf.Write(v.Bytes())
-		return err
+		return e.encodeTTLV(v.Bytes(), fi)
 	}
 
 	typeInfo, err := getTypeInfo(typ)
@@ -336,6 +578,11 @@ func (e *Encoder) encode(tag Tag, v reflect.Value, fi *fieldInfo) error {
 		tag = tagForMarshal(v, typeInfo, fi)
 	}
 
+	// a RawTTLV in a field with the "any" flag may hold a value with any tag
+	if typ == rawTTLVType && fi != nil && fi.flags.any() {
+		tag = TagNone
+	}
+
 	var flags fieldFlags
 	if fi != nil {
 		flags = fi.flags
@@ -421,7 +668,11 @@ func (e *Encoder) encode(tag Tag, v reflect.Value, fi *fieldInfo) error {
 	//
 	// If the field is explicitly flag, return an error if the value can't be interpreted.  Otherwise
 	// ignore errors and let processing fallthrough to the type-based encoding.
-	enumMap := DefaultRegistry.EnumForTag(tag)
+	var enumMap EnumMap
+	if !flags.integer() {
+		enumMap = DefaultRegistry.EnumForTag(tag)
+	}
+
 	if flags.enum() || flags.bitmask() || enumMap != nil {
 		switch typ.Kind() {
 		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
@@ -498,7 +749,13 @@ func (e *Encoder) encode(tag Tag, v reflect.Value, fi *fieldInfo) error {
 		e.encBuf.encodeBigInt(tag, v.Interface().(*big.Int)) //nolint:forcetypeassert
 		return nil
 	case durationType:
-		e.encBuf.encodeInterval(tag, time.Duration(v.Int()))
+		d := time.Duration(v.Int())
+		if !validInterval(d) {
+			return e.marshalingError(tag, typ, ErrIntervalOverflow)
+		}
+
+		e.encBuf.encodeInterval(tag, d)
+
 		return nil
 	}
 
@@ -511,7 +768,11 @@ func (e *Encoder) encode(tag Tag, v reflect.Value, fi *fieldInfo) error {
 
 		err = e.EncodeStructure(tag, func(e *Encoder) error {
 			for _, field := range typeInfo.valueFields {
-				fv := v.FieldByIndex(field.index)
+				fv, ok := fieldByIndex(v, field.index, false)
+				if !ok {
+					// field of a nil embedded struct
+					continue
+				}
 
 				// note: we're staying in reflection world here instead of
 				// converting back to an interface{} value and going through
@@ -778,21 +1039,83 @@ func (h *encBuf) encodeByteString(tag Tag, b []byte) {
 	h.end(i)
 }
 
-func getTypeInfo(typ reflect.Type) (ti typeInfo, err error) {
+// typeInfoCache caches the results of getTypeInfo() by reflect.Type.  Computing
+// typeInfo reflects over all of a struct's fields and resolves their tags by name,
+// which is wasteful to repeat every time a value of the same type is marshaled
+// or unmarshaled.
+//
+// Since the resolved tags depend on the tags registered in DefaultRegistry, the
+// cache is cleared when tags are registered in DefaultRegistry.
+var typeInfoCache sync.Map
+
+type cachedTypeInfo struct {
+	ti  typeInfo
+	err error
+}
+
+func getTypeInfo(typ reflect.Type) (typeInfo, error) {
+	return getTypeInfoBuilding(typ, nil)
+}
+
+// getTypeInfoBuilding is getTypeInfo, called while building the typeInfo of the types in
+// building.  Flattening a struct embedded by pointer can make a type's fields include a field
+// of that same type, like a struct B embedding *A, where A has a field of type B.  The typeInfo
+// of such a field can't be built before its own, so it's rejected instead of recursing forever.
+func getTypeInfoBuilding(typ reflect.Type, building map[reflect.Type]bool) (typeInfo, error) {
+	if c, ok := typeInfoCache.Load(typ); ok {
+		c := c.(*cachedTypeInfo) //nolint:forcetypeassert
+
+		return c.ti, c.err
+	}
+
+	if building[typ] {
+		return typeInfo{}, merry.Here(ErrUnsupportedTypeError).Appendf("type %s contains itself through an embedded struct", typ)
+	}
+
+	if building == nil {
+		building = map[reflect.Type]bool{}
+	}
+
+	building[typ] = true
+	ti, err := buildTypeInfo(typ, building)
+	delete(building, typ)
+
+	// if another goroutine raced us to populate the cache for this type,
+	// use its result, so all callers share the same typeInfo.
+	c, _ := typeInfoCache.LoadOrStore(typ, &cachedTypeInfo{ti: ti, err: err})
+	ci := c.(*cachedTypeInfo) //nolint:forcetypeassert
+
+	return ci.ti, ci.err
+}
+
+// resetTypeInfoCache clears all cached reflection metadata.
+func resetTypeInfoCache() {
+	typeInfoCache.Range(func(key, _ interface{}) bool {
+		typeInfoCache.Delete(key)
+		return true
+	})
+	structDecoders.Range(func(key, _ interface{}) bool {
+		structDecoders.Delete(key)
+		return true
+	})
+}
+
+func buildTypeInfo(typ reflect.Type, building map[reflect.Type]bool) (ti typeInfo, err error) {
 	ti.inferredTag, _ = DefaultRegistry.ParseTag(typ.Name())
 	ti.typ = typ
-	err = ti.getFieldsInfo()
+	err = ti.getFieldsInfo(building)
 
 	return ti, err
 }
 
 var errSkip = errors.New("skip")
 
-func getFieldInfo(typ reflect.Type, sf reflect.StructField) (fieldInfo, error) {
+func getFieldInfo(typ reflect.Type, sf reflect.StructField, building map[reflect.Type]bool) (fieldInfo, error) {
 	var fi fieldInfo
 
-	// skip anonymous and unexported fields
-	if sf.Anonymous || /*unexported:*/ sf.PkgPath != "" {
+	// skip unexported fields.  Embedded structs with unexported types are flattened by
+	// getFieldsInfo(), before this is called.
+	if /*unexported:*/ sf.PkgPath != "" {
 		return fi, errSkip
 	}
 
@@ -829,6 +1152,8 @@ func getFieldInfo(typ reflect.Type, sf reflect.StructField) (fieldInfo, error) {
 				fi.flags |= fDateTimeExtended
 			case "bitmask":
 				fi.flags |= fBitBask
+			case "integer":
+				fi.flags |= fInteger
 			case "any":
 				anyField = true
 				fi.flags |= fAny
@@ -836,6 +1161,10 @@ func getFieldInfo(typ reflect.Type, sf reflect.StructField) (fieldInfo, error) {
 		}
 	}
 
+	if fi.flags.integer() && (fi.flags.enum() || fi.flags.bitmask()) {
+		return fi, merry.Here(ErrTagConflict).Appendf(`field %s.%s may not specify the "integer" flag with the "enum" or "bitmask" flags`, fi.structType.Name(), fi.name)
+	}
+
 	if anyField && fi.explicitTag != TagNone {
 		return fi, merry.Here(ErrTagConflict).Appendf(`field %s.%s may not specify a TTLV tag and the "any" flag`, fi.structType.Name(), fi.name)
 	}
@@ -845,7 +1174,7 @@ func getFieldInfo(typ reflect.Type, sf reflect.StructField) (fieldInfo, error) {
 	// the field tags, or the field type.
 	var err error
 
-	fi.ti, err = getTypeInfo(sf.Type)
+	fi.ti, err = getTypeInfoBuilding(sf.Type, building)
 	if err != nil {
 		return fi, err
 	}
@@ -873,23 +1202,19 @@ func getFieldInfo(typ reflect.Type, sf reflect.StructField) (fieldInfo, error) {
 	return fi, nil
 }
 
-func (ti *typeInfo) getFieldsInfo() error {
+func (ti *typeInfo) getFieldsInfo(building map[reflect.Type]bool) error {
 	if ti.typ.Kind() != reflect.Struct {
 		return nil
 	}
 
-	for i := 0; i < ti.typ.NumField(); i++ {
-		fi, err := getFieldInfo(ti.typ, ti.typ.Field(i))
+	fields, err := ti.collectFields(ti.typ, nil, map[reflect.Type]bool{ti.typ: true}, building)
+	if err != nil {
+		return err
+	}
 
-		switch {
-		case err == errSkip: //nolint:errorlint
-			// skip
-		case err != nil:
-			return err
-		case fi.name == "TTLVTag":
-			ti.tagField = &fi
-		default:
-			ti.valueFields = append(ti.valueFields, fi)
+	for i := range fields {
+		if dominantField(fields, i) {
+			ti.valueFields = append(ti.valueFields, fields[i])
 		}
 	}
 
@@ -915,6 +1240,150 @@ func (ti *typeInfo) getFieldsInfo() error {
 	return nil
 }
 
+// collectFields returns the fields of typ, which is either ti.typ or a struct embedded in it at
+// the given index path.  The fields of embedded structs are inserted at the position of the
+// embedded field, so values are encoded in the order the fields are declared.
+//
+// embedding holds the struct types on the current path of embedded fields.  An embedded field
+// whose type is already on the path, like a struct embedding a pointer to its own type, would
+// flatten forever, so it is skipped, as encoding/json does.
+func (ti *typeInfo) collectFields(typ reflect.Type, index []int, embedding, building map[reflect.Type]bool) ([]fieldInfo, error) {
+	var fields []fieldInfo
+
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+
+		if flattenEmbedded(sf) {
+			et := indirectType(sf.Type)
+			if embedding[et] {
+				continue
+			}
+
+			embedding[et] = true
+			ef, err := ti.collectFields(et, append(append([]int{}, index...), sf.Index...), embedding, building)
+			delete(embedding, et)
+
+			if err != nil {
+				return nil, err
+			}
+
+			fields = append(fields, ef...)
+
+			continue
+		}
+
+		fi, err := getFieldInfo(typ, sf, building)
+
+		switch {
+		case err == errSkip: //nolint:errorlint
+			// skip
+		case err != nil:
+			return nil, err
+		case fi.name == "TTLVTag":
+			// only the struct's own TTLVTag field determines its tag
+			if index == nil {
+				ti.tagField = &fi
+			}
+		default:
+			fi.index = append(append([]int{}, index...), fi.index...)
+			fields = append(fields, fi)
+		}
+	}
+
+	return fields, nil
+}
+
+// dominantField returns true if fields[i] isn't hidden by another field with the same name.
+// Like encoding/json, shallower fields hide deeper ones, so the fields of the struct itself take
+// precedence over the fields of embedded structs.  Among fields of the same depth, a field with
+// an explicit TTLV tag hides those without one.  If that leaves more than one, they conflict, and
+// all are ignored.
+func dominantField(fields []fieldInfo, i int) bool {
+	f := fields[i]
+
+	var rivals, tagged int
+
+	for _, o := range fields {
+		if o.name != f.name {
+			continue
+		}
+
+		switch {
+		case len(o.index) < len(f.index):
+			return false
+		case len(o.index) == len(f.index):
+			rivals++
+
+			if o.explicitTag != TagNone {
+				tagged++
+			}
+		}
+	}
+
+	switch {
+	case rivals == 1:
+		return true
+	case tagged == 1:
+		return f.explicitTag != TagNone
+	default:
+		return false
+	}
+}
+
+// flattenEmbedded returns true if the fields of an embedded field should be encoded as if they
+// were fields of the enclosing struct.  Embedded structs, and pointers to structs, are flattened
+// unless their field tag specifies a TTLV tag, in which case they are encoded as a Structure, like
+// a named field.  Pointers to unexported struct types are skipped, since they can't be allocated
+// when unmarshaling.
+func flattenEmbedded(sf reflect.StructField) bool {
+	if !sf.Anonymous || indirectType(sf.Type).Kind() != reflect.Struct {
+		return false
+	}
+
+	if sf.Type.Kind() == reflect.Ptr && sf.PkgPath != "" {
+		return false
+	}
+
+	name := strings.Split(sf.Tag.Get(structFieldTag), ",")[0]
+
+	return name == ""
+}
+
+func indirectType(typ reflect.Type) reflect.Type {
+	if typ.Kind() == reflect.Ptr {
+		return typ.Elem()
+	}
+
+	return typ
+}
+
+// fieldByIndex is like reflect.Value.FieldByIndex, but it returns false instead of panicking
+// if a nil pointer to an embedded struct is in the path.  If alloc is true, nil pointers are
+// set to new values instead, so the field can be set.
+func fieldByIndex(v reflect.Value, index []int, alloc bool) (reflect.Value, bool) {
+	if len(index) == 1 {
+		return v.Field(index[0]), true
+	}
+
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !alloc {
+					return reflect.Value{}, false
+				}
+
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+
+			v = v.Elem()
+		}
+
+		v = v.Field(x)
+	}
+
+	return v, true
+}
+
 type typeInfo struct {
 	typ         reflect.Type
 	inferredTag Tag
@@ -928,6 +1397,7 @@ const (
 	fDateTimeExtended
 	fAny
 	fBitBask
+	fInteger
 )
 
 type fieldFlags int
@@ -952,6 +1422,10 @@ func (f fieldFlags) bitmask() bool {
 	return f&fBitBask != 0
 }
 
+func (f fieldFlags) integer() bool {
+	return f&fInteger != 0
+}
+
 type fieldInfo struct {
 	structType       reflect.Type
 	explicitTag, tag Tag
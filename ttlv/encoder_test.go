@@ -2,6 +2,7 @@ package ttlv_test
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -10,11 +11,15 @@ import (
 	"math"
 	"math/big"
 	"reflect"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	. "github.com/gemalto/kmip-go/kmip14"
 	. "github.com/gemalto/kmip-go/ttlv"
+	"github.com/gemalto/kmip-go/ttlv/ttlvtest"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -372,10 +377,6 @@ func TestEncoder_EncodeValue_errors(t *testing.T) {
 			v:      uint32(math.MaxInt32 + 1),
 			expErr: ErrIntOverflow,
 		},
-		{
-			v:      int(math.MaxInt32 + 1),
-			expErr: ErrIntOverflow,
-		},
 		{
 			v:      uint(math.MaxInt32 + 1),
 			expErr: ErrIntOverflow,
@@ -396,6 +397,13 @@ func TestEncoder_EncodeValue_errors(t *testing.T) {
 			expErr: ErrUnsupportedTypeError,
 		},
 	}
+
+	if strconv.IntSize == 64 {
+		// int can only overflow an Integer on 64 bit platforms
+		var i int64 = math.MaxInt32 + 1
+		tests = append(tests, testCase{v: int(i), expErr: ErrIntOverflow})
+	}
+
 	enc := NewEncoder(bytes.NewBuffer(nil))
 	for _, test := range tests {
 		testName := test.name
@@ -691,7 +699,7 @@ func TestEncoder_EncodeValue(t *testing.T) {
 			},
 		},
 		{
-			name: "skipstructanonfield",
+			name: "flattenstructanonfield",
 			v: struct {
 				AttributeName string
 				Attribute
@@ -700,6 +708,7 @@ func TestEncoder_EncodeValue(t *testing.T) {
 				Tag: TagCancellationResult,
 				Value: Values{
 					Value{Tag: TagAttributeName, Value: "red"},
+					Value{Tag: TagAttributeValue, Value: "green"},
 				},
 			},
 		},
@@ -1185,6 +1194,64 @@ func TestEncoder_EncodeValue(t *testing.T) {
 				Value{Tag: TagAttributeValue, Value: int32(5)},
 			}},
 		},
+		{
+			name: "nilptrfields",
+			v: struct {
+				Attribute      *cert
+				AttributeValue *string
+				ArchiveDate    *time.Time
+				AttributeIndex *int `ttlv:",omitempty"`
+			}{},
+			expected: Value{Tag: TagCancellationResult, Value: Values{}},
+		},
+		{
+			name: "nilandemptyslicefields",
+			v: struct {
+				Attribute      []attr
+				AttributeValue []string
+				AttributeIndex []int `ttlv:",omitempty"`
+				ArchiveDate    []*cert
+			}{
+				AttributeValue: []string{},
+				AttributeIndex: []int{},
+				ArchiveDate:    []*cert{nil, nil},
+			},
+			expected: Value{Tag: TagCancellationResult, Value: Values{}},
+		},
+		{
+			name: "emptybyteslicefields",
+			v: struct {
+				Attribute      []byte
+				AttributeValue []byte
+				ArchiveDate    []byte `ttlv:",omitempty"`
+			}{
+				AttributeValue: []byte{},
+				ArchiveDate:    []byte{},
+			},
+			expected: Value{Tag: TagCancellationResult, Value: Values{
+				Value{Tag: TagAttributeValue, Value: []byte{}},
+			}},
+		},
+		{
+			name: "omitemptystruct",
+			v: struct {
+				Attribute      attr
+				AttributeValue attr  `ttlv:",omitempty"`
+				ArchiveDate    *attr `ttlv:",omitempty"`
+				AttributeIndex attr  `ttlv:",omitempty"`
+			}{
+				ArchiveDate:    &attr{},
+				AttributeIndex: attr{AttributeName: "color"},
+			},
+			expected: Value{Tag: TagCancellationResult, Value: Values{
+				Value{Tag: TagAttribute, Value: Values{
+					Value{Tag: TagAttributeName, Value: ""},
+				}},
+				Value{Tag: TagAttributeIndex, Value: Values{
+					Value{Tag: TagAttributeName, Value: "color"},
+				}},
+			}},
+		},
 		{
 			name: "enumtag",
 			v: struct {
@@ -1523,6 +1590,205 @@ func parseTime(s string) time.Time {
 	return v
 }
 
+func TestEncoder_EncodeValue_concurrent(t *testing.T) {
+	// reflection metadata is cached per type on first use, which
+	// must be safe when the first uses are concurrent.
+	type concurrentStruct struct {
+		ActivationDate   time.Time
+		BatchCount       int
+		UniqueIdentifier string
+		ObjectType       ObjectType
+	}
+
+	v := concurrentStruct{
+		ActivationDate:   time.Now().Truncate(time.Second),
+		BatchCount:       3,
+		UniqueIdentifier: "fred",
+		ObjectType:       ObjectTypeSymmetricKey,
+	}
+
+	expected, err := Marshal(Value{Tag: TagBatchItem, Value: Values{
+		{Tag: TagActivationDate, Value: v.ActivationDate},
+		{Tag: TagBatchCount, Value: v.BatchCount},
+		{Tag: TagUniqueIdentifier, Value: v.UniqueIdentifier},
+		{Tag: TagObjectType, Value: v.ObjectType},
+	}})
+	require.NoError(t, err)
+
+	results := make([][]byte, 10)
+	errs := make([]error, 10)
+
+	var wg sync.WaitGroup
+
+	for i := range results {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			buf := bytes.NewBuffer(nil)
+			enc := NewEncoder(buf)
+			errs[i] = enc.EncodeValue(TagBatchItem, v)
+
+			if errs[i] == nil {
+				errs[i] = enc.Flush()
+			}
+
+			results[i] = buf.Bytes()
+		}(i)
+	}
+
+	wg.Wait()
+
+	for i := range results {
+		require.NoError(t, errs[i])
+		assert.Equal(t, TTLV(expected), TTLV(results[i]))
+	}
+}
+
+func TestEncoder_EncodeValue_tagRegisteredAfterUse(t *testing.T) {
+	// tags inferred from field names must reflect tags registered
+	// after the type was first marshaled.
+	type lateTagStruct struct {
+		LateRegisteredTag string
+	}
+
+	v := lateTagStruct{LateRegisteredTag: "red"}
+
+	err := NewEncoder(ioutil.Discard).EncodeValue(TagBatchItem, v)
+	require.True(t, errors.Is(err, ErrNoTag), Details(err))
+
+	// tags of fields are resolved with DefaultRegistry, so the tag has to be registered
+	// there.  Registrations can't be undone, so restore a fresh registry afterwards.
+	t.Cleanup(func() {
+		var r Registry
+		RegisterTypes(&r)
+		Register(&r)
+		DefaultRegistry = r
+	})
+
+	DefaultRegistry.RegisterTag(0x54fff1, "Late Registered Tag")
+
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf)
+	require.NoError(t, enc.EncodeValue(TagBatchItem, v))
+	require.NoError(t, enc.Flush())
+
+	expected, err := Marshal(Value{Tag: TagBatchItem, Value: Values{
+		{Tag: Tag(0x54fff1), Value: "red"},
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, TTLV(expected), TTLV(buf.Bytes()))
+}
+
+// headerCheckReader fails if the writer doesn't already hold the ByteString header
+// by the time the value is read, i.e. if the value is being buffered.
+type headerCheckReader struct {
+	t   *testing.T
+	r   io.Reader
+	buf *bytes.Buffer
+}
+
+func (r *headerCheckReader) Read(p []byte) (int, error) {
+	assert.NotZero(r.t, r.buf.Len(), "header should be written before the value is read")
+	return r.r.Read(p)
+}
+
+func TestEncoder_EncodeByteStringReader(t *testing.T) {
+	for _, l := range []int{0, 5, 8, 13, 1024} {
+		t.Run(strconv.Itoa(l), func(t *testing.T) {
+			value := bytes.Repeat([]byte{0xab}, l)
+
+			expected, err := Marshal(Value{Tag: TagKeyMaterial, Value: value})
+			require.NoError(t, err)
+
+			// top level values are streamed to the writer
+			buf := bytes.NewBuffer(nil)
+			enc := NewEncoder(buf)
+			err = enc.EncodeByteStringReader(TagKeyMaterial, &headerCheckReader{t: t, r: bytes.NewReader(value), buf: buf}, l)
+			require.NoError(t, err)
+			assert.Equal(t, TTLV(expected), TTLV(buf.Bytes()))
+
+			// values inside a structure are buffered
+			expected, err = Marshal(Value{Tag: TagKeyValue, Value: Values{
+				{Tag: TagComment, Value: "red"},
+				{Tag: TagKeyMaterial, Value: value},
+			}})
+			require.NoError(t, err)
+
+			buf.Reset()
+			err = enc.EncodeStructure(TagKeyValue, func(e *Encoder) error {
+				e.EncodeTextString(TagComment, "red")
+				return e.EncodeByteStringReader(TagKeyMaterial, bytes.NewReader(value), l)
+			})
+			require.NoError(t, err)
+			require.NoError(t, enc.Flush())
+			assert.Equal(t, TTLV(expected), TTLV(buf.Bytes()))
+		})
+	}
+
+	t.Run("shortread", func(t *testing.T) {
+		buf := bytes.NewBuffer(nil)
+		enc := NewEncoder(buf)
+		err := enc.EncodeByteStringReader(TagKeyMaterial, bytes.NewReader([]byte{1, 2, 3}), 4)
+		require.True(t, errors.Is(err, io.ErrUnexpectedEOF), Details(err))
+
+		// when streaming, the header and partial value have already been written
+		assert.Equal(t, Hex2bytes("42 00 43 | 08 | 00 00 00 04 | 01 02 03"), buf.Bytes())
+		assert.Error(t, TTLV(buf.Bytes()).Valid())
+
+		// inside a structure, the partial value is discarded
+		buf.Reset()
+		err = enc.EncodeStructure(TagKeyValue, func(e *Encoder) error {
+			err := e.EncodeByteStringReader(TagKeyMaterial, bytes.NewReader([]byte{1, 2, 3}), 4)
+			require.True(t, errors.Is(err, io.ErrUnexpectedEOF), Details(err))
+
+			e.EncodeTextString(TagComment, "red")
+
+			return nil
+		})
+		require.NoError(t, err)
+		require.NoError(t, enc.Flush())
+
+		expected, err := Marshal(Value{Tag: TagKeyValue, Value: Values{
+			{Tag: TagComment, Value: "red"},
+		}})
+		require.NoError(t, err)
+		assert.Equal(t, TTLV(expected), TTLV(buf.Bytes()))
+	})
+
+	t.Run("invalidlength", func(t *testing.T) {
+		err := NewEncoder(ioutil.Discard).EncodeByteStringReader(TagKeyMaterial, bytes.NewReader(nil), -1)
+		require.True(t, errors.Is(err, ErrInvalidLen), Details(err))
+	})
+}
+
+func TestEncoder_ValidateTypes(t *testing.T) {
+	encodeInvalid := func(e *Encoder) error {
+		return e.EncodeStructure(TagKeyBlock, func(e *Encoder) error {
+			e.EncodeInteger(TagKeyFormatType, 5)
+			return nil
+		})
+	}
+
+	// permissive by default
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf)
+	require.NoError(t, encodeInvalid(enc))
+	require.NoError(t, enc.Flush())
+	assert.NotZero(t, buf.Len())
+
+	buf.Reset()
+	enc.ValidateTypes = true
+	require.NoError(t, encodeInvalid(enc))
+	err := enc.Flush()
+	require.True(t, errors.Is(err, ErrInvalidType), Details(err))
+	assert.Zero(t, buf.Len())
+
+	require.NoError(t, enc.Encode(Value{Tag: TagKeyFormatType, Value: KeyFormatTypeRaw}))
+	assert.NotZero(t, buf.Len())
+}
+
 func BenchmarkEncodeSlice(b *testing.B) {
 	enc := NewEncoder(ioutil.Discard)
 
@@ -1641,3 +1907,473 @@ func BenchmarkEncoder_EncodeInt(b *testing.B) {
 		require.NoError(b, enc.Flush())
 	}
 }
+
+func TestMarshal_namedEnumTypes(t *testing.T) {
+	type keyFormat int32
+
+	type withEnums struct {
+		KeyFormatType          KeyFormatType
+		CancellationResult     keyFormat
+		CryptographicAlgorithm CryptographicAlgorithm `ttlv:",integer"`
+	}
+
+	v := withEnums{
+		KeyFormatType:          KeyFormatTypeX_509,
+		CancellationResult:     2,
+		CryptographicAlgorithm: CryptographicAlgorithmAES,
+	}
+
+	b, err := Marshal(Value{Tag: TagKeyBlock, Value: v})
+	require.NoError(t, err)
+
+	exp, err := Marshal(NewStruct(TagKeyBlock,
+		Value{Tag: TagKeyFormatType, Value: EnumValue(KeyFormatTypeX_509)},
+		Value{Tag: TagCancellationResult, Value: EnumValue(2)},
+		Value{Tag: TagCryptographicAlgorithm, Value: int32(CryptographicAlgorithmAES)},
+	))
+	require.NoError(t, err)
+
+	assert.Equal(t, exp, b)
+
+	var out withEnums
+	require.NoError(t, Unmarshal(b, &out))
+	assert.Equal(t, v, out)
+
+	type conflict struct {
+		KeyFormatType KeyFormatType `ttlv:",integer,enum"`
+	}
+
+	_, err = Marshal(Value{Tag: TagKeyBlock, Value: conflict{}})
+	require.True(t, errors.Is(err, ErrTagConflict), Details(err))
+}
+
+func TestEncoder_intervalRange(t *testing.T) {
+	assert.Equal(t, time.Duration(math.MaxUint32)*time.Second, MaxInterval)
+
+	b, err := Marshal(Value{Tag: TagLeaseTime, Value: MaxInterval})
+	require.NoError(t, err)
+	assert.Equal(t, MaxInterval, b.ValueInterval())
+
+	// fractions of seconds are truncated
+	b, err = Marshal(Value{Tag: TagLeaseTime, Value: MaxInterval + time.Second - 1})
+	require.NoError(t, err)
+	assert.Equal(t, MaxInterval, b.ValueInterval())
+
+	for _, d := range []time.Duration{MaxInterval + time.Second, -time.Second} {
+		_, err = Marshal(Value{Tag: TagLeaseTime, Value: d})
+		require.True(t, errors.Is(err, ErrIntervalOverflow), Details(err))
+	}
+
+	type lease struct {
+		LeaseTime time.Duration
+	}
+
+	_, err = Marshal(Value{Tag: TagKeyBlock, Value: lease{LeaseTime: MaxInterval + time.Second}})
+	require.True(t, errors.Is(err, ErrIntervalOverflow), Details(err))
+
+	// the Encode<Type> method reports the error on the next Flush, and discards the buffer
+	var buf bytes.Buffer
+
+	enc := NewEncoder(&buf)
+	enc.EncodeTextString(TagComment, "red")
+	enc.EncodeInterval(TagLeaseTime, MaxInterval+time.Second)
+	require.True(t, errors.Is(enc.Flush(), ErrIntervalOverflow))
+	assert.Zero(t, buf.Len())
+
+	enc.EncodeInterval(TagLeaseTime, time.Minute)
+	require.NoError(t, enc.Flush())
+	assert.Equal(t, time.Minute, TTLV(buf.Bytes()).ValueInterval())
+}
+
+func TestEncodeTo(t *testing.T) {
+	v := NewStruct(TagRequestMessage,
+		NewStruct(TagBatchItem,
+			NewValue(TagOperation, OperationRegister),
+			NewValue(TagComment, strings.Repeat("x", 10000)),
+		),
+	)
+
+	expected, err := Marshal(v)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+
+	h := sha256.New()
+	require.NoError(t, EncodeTo(&buf, h, v))
+	assert.Equal(t, []byte(expected), buf.Bytes())
+
+	sum := sha256.Sum256(expected)
+	assert.Equal(t, sum[:], h.Sum(nil))
+
+	// a nil hash just writes
+	buf.Reset()
+	require.NoError(t, EncodeTo(&buf, nil, v))
+	assert.Equal(t, []byte(expected), buf.Bytes())
+
+	// nothing is written or hashed if encoding fails
+	buf.Reset()
+	h.Reset()
+	require.Error(t, EncodeTo(&buf, h, Value{Tag: TagLeaseTime, Value: MaxInterval + time.Second}))
+	assert.Empty(t, buf.Bytes())
+	empty := sha256.Sum256(nil)
+	assert.Equal(t, empty[:], h.Sum(nil))
+}
+
+type EmbeddedCommon struct {
+	UniqueIdentifier string
+	Comment          string `ttlv:",omitempty"`
+}
+
+type EmbeddedLease struct {
+	LeaseTime time.Duration
+}
+
+type EmbeddedNote struct {
+	Comment string
+}
+
+func TestMarshal_embeddedStructs(t *testing.T) {
+	type flattened struct {
+		EmbeddedCommon
+		*EmbeddedLease
+		Comment   string // shadows EmbeddedCommon.Comment
+		Operation Operation
+	}
+
+	type nested struct {
+		EmbeddedCommon `ttlv:"KeyBlock"`
+		Operation      Operation
+	}
+
+	type interleaved struct {
+		Operation Operation
+		EmbeddedLease
+		Comment string
+	}
+
+	type conflicting struct {
+		EmbeddedCommon
+		EmbeddedNote
+		Operation Operation
+	}
+
+	tests := []struct {
+		name     string
+		v        interface{}
+		expected Value
+		decoded  interface{}
+	}{
+		{
+			name: "flattened",
+			v: flattened{
+				EmbeddedCommon: EmbeddedCommon{UniqueIdentifier: "1", Comment: "ignored"},
+				EmbeddedLease:  &EmbeddedLease{LeaseTime: time.Minute},
+				Comment:        "red",
+				Operation:      OperationGet,
+			},
+			expected: NewStruct(TagRequestPayload,
+				NewValue(TagUniqueIdentifier, "1"),
+				NewValue(TagLeaseTime, time.Minute),
+				NewValue(TagComment, "red"),
+				NewValue(TagOperation, OperationGet),
+			),
+			decoded: &flattened{
+				EmbeddedCommon: EmbeddedCommon{UniqueIdentifier: "1"},
+				EmbeddedLease:  &EmbeddedLease{LeaseTime: time.Minute},
+				Comment:        "red",
+				Operation:      OperationGet,
+			},
+		},
+		{
+			name: "nilembeddedpointer",
+			v:    flattened{EmbeddedCommon: EmbeddedCommon{UniqueIdentifier: "1"}, Operation: OperationGet},
+			expected: NewStruct(TagRequestPayload,
+				NewValue(TagUniqueIdentifier, "1"),
+				NewValue(TagComment, ""),
+				NewValue(TagOperation, OperationGet),
+			),
+			decoded: &flattened{EmbeddedCommon: EmbeddedCommon{UniqueIdentifier: "1"}, Operation: OperationGet},
+		},
+		{
+			name: "declarationorder",
+			v: interleaved{
+				Operation:     OperationGet,
+				EmbeddedLease: EmbeddedLease{LeaseTime: time.Minute},
+				Comment:       "red",
+			},
+			expected: NewStruct(TagRequestPayload,
+				NewValue(TagOperation, OperationGet),
+				NewValue(TagLeaseTime, time.Minute),
+				NewValue(TagComment, "red"),
+			),
+			decoded: &interleaved{
+				Operation:     OperationGet,
+				EmbeddedLease: EmbeddedLease{LeaseTime: time.Minute},
+				Comment:       "red",
+			},
+		},
+		{
+			name: "conflicting",
+			v: conflicting{
+				EmbeddedCommon: EmbeddedCommon{UniqueIdentifier: "1", Comment: "red"},
+				EmbeddedNote:   EmbeddedNote{Comment: "blue"},
+				Operation:      OperationGet,
+			},
+			expected: NewStruct(TagRequestPayload,
+				NewValue(TagUniqueIdentifier, "1"),
+				NewValue(TagOperation, OperationGet),
+			),
+			decoded: &conflicting{EmbeddedCommon: EmbeddedCommon{UniqueIdentifier: "1"}, Operation: OperationGet},
+		},
+		{
+			name: "tagged",
+			v:    nested{EmbeddedCommon: EmbeddedCommon{UniqueIdentifier: "1"}, Operation: OperationGet},
+			expected: NewStruct(TagRequestPayload,
+				NewStruct(TagKeyBlock,
+					NewValue(TagUniqueIdentifier, "1"),
+				),
+				NewValue(TagOperation, OperationGet),
+			),
+			decoded: &nested{EmbeddedCommon: EmbeddedCommon{UniqueIdentifier: "1"}, Operation: OperationGet},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := Marshal(Value{Tag: TagRequestPayload, Value: tc.v})
+			require.NoError(t, err)
+
+			expected, err := Marshal(tc.expected)
+			require.NoError(t, err)
+			ttlvtest.AssertTTLVEqual(t, expected, b)
+
+			decoded := reflect.New(reflect.TypeOf(tc.v))
+			require.NoError(t, Unmarshal(b, decoded.Interface()))
+			assert.Equal(t, tc.decoded, decoded.Interface())
+		})
+	}
+}
+
+type EmbeddedSelf struct {
+	*EmbeddedSelf
+	UniqueIdentifier string
+}
+
+type EmbeddedLoop struct {
+	UniqueIdentifier string
+	KeyBlock         EmbeddedLoopBlock
+}
+
+type EmbeddedLoopBlock struct {
+	*EmbeddedLoop
+}
+
+func TestMarshal_embeddedCycles(t *testing.T) {
+	// a struct embedding a pointer to its own type is skipped, as encoding/json does
+	b, err := Marshal(Value{Tag: TagRequestPayload, Value: EmbeddedSelf{
+		EmbeddedSelf:     &EmbeddedSelf{UniqueIdentifier: "2"},
+		UniqueIdentifier: "1",
+	}})
+	require.NoError(t, err)
+
+	expected, err := Marshal(NewStruct(TagRequestPayload,
+		NewValue(TagUniqueIdentifier, "1"),
+	))
+	require.NoError(t, err)
+	ttlvtest.AssertTTLVEqual(t, expected, b)
+
+	var decoded EmbeddedSelf
+	require.NoError(t, Unmarshal(b, &decoded))
+	assert.Equal(t, EmbeddedSelf{UniqueIdentifier: "1"}, decoded)
+
+	// a struct whose flattened fields include a field of its own type can't be marshaled
+	_, err = Marshal(Value{Tag: TagRequestPayload, Value: EmbeddedLoop{UniqueIdentifier: "1"}})
+	require.Error(t, err)
+	require.True(t, errors.Is(err, ErrUnsupportedTypeError), Details(err))
+
+	_, err = Marshal(Value{Tag: TagKeyBlock, Value: EmbeddedLoopBlock{}})
+	require.Error(t, err)
+	require.True(t, errors.Is(err, ErrUnsupportedTypeError), Details(err))
+
+	var loop EmbeddedLoop
+	err = Unmarshal(expected, &loop)
+	require.Error(t, err)
+	require.True(t, errors.Is(err, ErrUnsupportedTypeError), Details(err))
+}
+
+func TestMarshal_preservesUnknownValues(t *testing.T) {
+	type batchItem struct {
+		Operation Operation
+		Extra     []TTLV `ttlv:",any"`
+	}
+
+	type message struct {
+		Comment   string
+		BatchItem []batchItem
+		Extra     []TTLV `ttlv:",any"`
+	}
+
+	// a message with vendor extensions interleaved with the known values
+	in, err := Marshal(NewStruct(TagRequestMessage,
+		NewValue(Tag(0x540001), "vendor"),
+		NewValue(TagComment, "red"),
+		NewStruct(TagBatchItem,
+			NewValue(TagOperation, OperationGet),
+			NewStruct(Tag(0x540002),
+				NewValue(TagBatchCount, 1),
+			),
+		),
+		NewValue(Tag(0x540003), int32(7)),
+	))
+	require.NoError(t, err)
+
+	// a proxy decodes the message, modifies a known field, and forwards it
+	var msg message
+	require.NoError(t, Unmarshal(in, &msg))
+
+	msg.Comment = "blue"
+
+	out, err := Marshal(Value{Tag: TagRequestMessage, Value: &msg})
+	require.NoError(t, err)
+
+	// the extensions are re-emitted at the end of their structures
+	expected, err := Marshal(NewStruct(TagRequestMessage,
+		NewValue(TagComment, "blue"),
+		NewStruct(TagBatchItem,
+			NewValue(TagOperation, OperationGet),
+			NewStruct(Tag(0x540002),
+				NewValue(TagBatchCount, 1),
+			),
+		),
+		NewValue(Tag(0x540001), "vendor"),
+		NewValue(Tag(0x540003), int32(7)),
+	))
+	require.NoError(t, err)
+	ttlvtest.AssertTTLVEqual(t, expected, out)
+}
+
+func TestMarshal_ttlvFields(t *testing.T) {
+	type key struct {
+		UniqueIdentifier string
+		KeyBlock         TTLV
+	}
+
+	keyBlock, err := Marshal(NewStruct(TagKeyBlock,
+		NewValue(TagKeyFormatType, KeyFormatTypeRaw),
+		NewStruct(TagKeyValue,
+			NewValue(TagKeyMaterial, []byte{0x01, 0x02, 0x03}),
+		),
+	))
+	require.NoError(t, err)
+
+	b, err := Marshal(Value{Tag: TagSymmetricKey, Value: key{UniqueIdentifier: "id", KeyBlock: keyBlock}})
+	require.NoError(t, err)
+
+	// the raw bytes are counted in the enclosing structure's length
+	expected, err := Marshal(NewStruct(TagSymmetricKey,
+		NewValue(TagUniqueIdentifier, "id"),
+		NewStruct(TagKeyBlock,
+			NewValue(TagKeyFormatType, KeyFormatTypeRaw),
+			NewStruct(TagKeyValue,
+				NewValue(TagKeyMaterial, []byte{0x01, 0x02, 0x03}),
+			),
+		),
+	))
+	require.NoError(t, err)
+	ttlvtest.AssertTTLVEqual(t, expected, b)
+
+	// an empty value encodes nothing
+	b, err = Marshal(Value{Tag: TagSymmetricKey, Value: key{UniqueIdentifier: "id"}})
+	require.NoError(t, err)
+
+	expected, err = Marshal(NewStruct(TagSymmetricKey, NewValue(TagUniqueIdentifier, "id")))
+	require.NoError(t, err)
+	ttlvtest.AssertTTLVEqual(t, expected, b)
+
+	// with omitempty, zero values are skipped
+	type comment struct {
+		Comment TTLV `ttlv:",omitempty"`
+	}
+
+	zero, err := Marshal(NewValue(TagComment, ""))
+	require.NoError(t, err)
+
+	b, err = Marshal(Value{Tag: TagSymmetricKey, Value: comment{Comment: zero}})
+	require.NoError(t, err)
+
+	expected, err = Marshal(NewStruct(TagSymmetricKey))
+	require.NoError(t, err)
+	ttlvtest.AssertTTLVEqual(t, expected, b)
+
+	// a TTLV holding the epoch is zero, but a time.Time holding the epoch isn't
+	type dates struct {
+		InitialDate    TTLV      `ttlv:",omitempty"`
+		ActivationDate time.Time `ttlv:",omitempty"`
+	}
+
+	epoch, err := Marshal(NewValue(TagInitialDate, time.Unix(0, 0)))
+	require.NoError(t, err)
+
+	b, err = Marshal(Value{Tag: TagSymmetricKey, Value: dates{InitialDate: epoch, ActivationDate: time.Unix(0, 0)}})
+	require.NoError(t, err)
+
+	expected, err = Marshal(NewStruct(TagSymmetricKey, NewValue(TagActivationDate, time.Unix(0, 0))))
+	require.NoError(t, err)
+	ttlvtest.AssertTTLVEqual(t, expected, b)
+
+	// the value's tag must match the field's tag
+	keyValue, err := Marshal(NewStruct(TagKeyValue))
+	require.NoError(t, err)
+
+	_, err = Marshal(Value{Tag: TagSymmetricKey, Value: key{KeyBlock: keyValue}})
+	require.Error(t, err)
+	require.True(t, errors.Is(err, ErrTagConflict), Details(err))
+
+	// and it must be valid
+	_, err = Marshal(Value{Tag: TagSymmetricKey, Value: key{KeyBlock: keyBlock[:len(keyBlock)-8]}})
+	require.Error(t, err)
+	require.True(t, errors.Is(err, ErrValueTruncated), Details(err))
+
+	// RawTTLV fields are checked the same way
+	type rawKey struct {
+		KeyBlock RawTTLV
+		Extra    RawTTLV `ttlv:",any"`
+	}
+
+	batchCount, err := Marshal(NewValue(TagBatchCount, 1))
+	require.NoError(t, err)
+
+	b, err = Marshal(Value{Tag: TagSymmetricKey, Value: rawKey{
+		KeyBlock: RawTTLV{Tag: TagKeyBlock, TTLV: keyBlock},
+		Extra:    RawTTLV{Tag: TagBatchCount, TTLV: batchCount},
+	}})
+	require.NoError(t, err)
+
+	expected, err = Marshal(NewStruct(TagSymmetricKey,
+		NewStruct(TagKeyBlock,
+			NewValue(TagKeyFormatType, KeyFormatTypeRaw),
+			NewStruct(TagKeyValue,
+				NewValue(TagKeyMaterial, []byte{0x01, 0x02, 0x03}),
+			),
+		),
+		NewValue(TagBatchCount, 1),
+	))
+	require.NoError(t, err)
+	ttlvtest.AssertTTLVEqual(t, expected, b)
+
+	_, err = Marshal(Value{Tag: TagSymmetricKey, Value: rawKey{KeyBlock: RawTTLV{TTLV: keyValue}}})
+	require.Error(t, err)
+	require.True(t, errors.Is(err, ErrTagConflict), Details(err))
+
+	// the value's tag must also match the RawTTLV's Tag
+	_, err = Marshal(Value{Tag: TagSymmetricKey, Value: rawKey{Extra: RawTTLV{Tag: TagBatchItem, TTLV: batchCount}}})
+	require.Error(t, err)
+	require.True(t, errors.Is(err, ErrTagConflict), Details(err))
+
+	// a header declaring a value which is missing
+	header := TTLV{0x42, 0x00, 0x0d, byte(TypeLongInteger), 0x00, 0x00, 0x00, 0x08}
+
+	_, err = Marshal(Value{Tag: TagSymmetricKey, Value: rawKey{Extra: RawTTLV{TTLV: header}}})
+	require.Error(t, err)
+	require.True(t, errors.Is(err, ErrValueTruncated), Details(err))
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/xml"
 	"fmt"
 	"os"
+	"text/template"
 )
 
 func Example_json() {
@@ -57,3 +58,40 @@ func ExamplePrint() {
 	//   ProtocolVersionMajor (Integer/4): 1
 	//   ProtocolVersionMinor (Integer/4): 0
 }
+
+func ExampleTemplateValue() {
+	input := `{"tag":"ResponseMessage","value":[
+		{"tag":"BatchItem","value":[
+			{"tag":"Operation","type":"Enumeration","value":"GetAttributes"},
+			{"tag":"ResponsePayload","value":[
+				{"tag":"UniqueIdentifier","type":"TextString","value":"1"},
+				{"tag":"Attribute","value":[
+					{"tag":"AttributeName","type":"TextString","value":"State"},
+					{"tag":"AttributeValue","type":"Enumeration","value":"0x00000002"}
+				]},
+				{"tag":"Attribute","value":[
+					{"tag":"AttributeName","type":"TextString","value":"Cryptographic Length"},
+					{"tag":"AttributeValue","type":"Integer","value":256}
+				]}
+			]}
+		]}
+	]}`
+
+	var msg TTLV
+
+	_ = json.Unmarshal([]byte(input), &msg)
+
+	tmpl := template.Must(template.New("report").Parse(
+		`{{.Find "Operation"}} {{(.Find "UniqueIdentifier").Value}}
+{{range .FindAll "Attribute"}}{{(.Get "AttributeName").Value}}: {{(.Get "AttributeValue").String}}
+{{end}}{{with .Find "ResultMessage"}}{{.}}{{else}}no message{{end}}
+`))
+
+	_ = tmpl.Execute(os.Stdout, NewTemplateValue(msg))
+
+	// Output:
+	// GetAttributes 1
+	// State: 0x00000002
+	// Cryptographic Length: 256
+	// no message
+}
@@ -91,33 +91,40 @@ func FormatEnum(v uint32, enumMap EnumMap) string {
 // - CertificateSign|0x00000004|0x0000008
 // - CertificateSign|0x0000000c
 func FormatInt(i int32, enumMap EnumMap) string {
-	if enumMap == nil {
-		return fmt.Sprintf("%#08x", i)
+	return strings.Join(MaskNames(i, enumMap), "|")
+}
+
+// MaskNames decomposes an integer bitmask into the names of the flags which are set,
+// the same terms FormatInt joins with pipes.  Set bits which don't belong to a registered
+// flag are combined into a single hex term at the end.  If no bits are set, or no
+// flags are registered in enumMap, the value is returned as a single hex term.  Examples:
+//
+// - [Encrypt Decrypt]
+// - [CertificateSign 0x00000004]
+// - [0x00000000]
+func MaskNames(i int32, enumMap EnumMap) []string {
+	var values []uint32
+	if enumMap != nil {
+		values = enumMap.Values()
 	}
 
-	values := enumMap.Values()
 	if len(values) == 0 {
-		return fmt.Sprintf("%#08x", i)
+		return []string{fmt.Sprintf("%#08x", uint32(i))}
 	}
 
-	v := uint32(i)
+	return maskNames(uint32(i), enumMap, values)
+}
 
-	// bitmask
-	// decompose mask into the names of set flags, concatenated by pipe
-	// if remaining value (minus registered flags) is not zero, append
+func maskNames(v uint32, enumMap EnumMap, values []uint32) []string {
+	// decompose mask into the names of set flags.  if remaining
+	// value (minus registered flags) is not zero, append
 	// the remaining value as hex.
-
-	var sb strings.Builder
+	var names []string
 
 	for _, v1 := range values {
 		if v1&v == v1 {
 			if name, ok := enumMap.Name(v1); ok {
-				if sb.Len() > 0 {
-					sb.WriteString("|")
-				}
-
-				sb.WriteString(name)
-
+				names = append(names, name)
 				v ^= v1
 			}
 		}
@@ -127,15 +134,12 @@ func FormatInt(i int32, enumMap EnumMap) string {
 		}
 	}
 
-	if v != 0 {
-		if sb.Len() > 0 {
-			sb.WriteString("|")
-		}
-
-		_, _ = fmt.Fprintf(&sb, "%#08x", v)
+	// also format a zero value, or no terms would be returned
+	if v != 0 || len(names) == 0 {
+		names = append(names, fmt.Sprintf("%#08x", v))
 	}
 
-	return sb.String()
+	return names
 }
 
 // ParseEnum parses a string into a uint32 according to the rules
@@ -153,6 +157,15 @@ func FormatInt(i int32, enumMap EnumMap) string {
 //
 // Returns ErrUnregisteredEnumName if string value is not a
 // registered enum value name.
+//
+// The string is first parsed as a decimal number, then as a hex
+// value if it starts with "0x", and only then looked up as a name
+// in enumMap.  Numbers and hex values are accepted whether or not
+// they are registered in enumMap (which may be nil), so values added
+// in newer versions of the spec can always be parsed in one of those
+// forms, even though their names can't be.  Unlike other hex values,
+// an odd number of hex digits is accepted, and treated as if it had a
+// leading zero, e.g. "0x2" parses the same as "0x02".
 func ParseEnum(s string, enumMap EnumMap) (uint32, error) {
 	u, err := strconv.ParseUint(s, 10, 32)
 	if err == nil {
@@ -160,6 +173,10 @@ func ParseEnum(s string, enumMap EnumMap) (uint32, error) {
 		return uint32(u), nil
 	}
 
+	if strings.HasPrefix(s, "0x") && len(s)%2 == 1 {
+		s = "0x0" + s[2:]
+	}
+
 	v, err := parseHexOrName(s, 4, enumMap)
 	if err != nil {
 		return 0, merry.Here(err)
@@ -179,6 +196,10 @@ func ParseEnum(s string, enumMap EnumMap) (uint32, error) {
 //
 // Returns ErrUnregisteredEnumName if string value is not a
 // registered enum value name.
+//
+// Each part of a bitmask is parsed with the same precedence as ParseEnum,
+// so unregistered bits can be given in hex alongside registered names,
+// e.g. "Sign|0x00100000".
 func ParseInt(s string, enumMap EnumMap) (int32, error) {
 	i, err := strconv.ParseInt(s, 10, 32)
 	if err == nil {
@@ -0,0 +1,163 @@
+package ttlv
+
+import (
+	"strings"
+
+	"github.com/ansel1/merry"
+)
+
+// ErrInvalidQuery is returned by Query() if the query string can't be parsed.
+var ErrInvalidQuery = merry.New("invalid query")
+
+type queryStep struct {
+	tag Tag
+	// any matches any tag
+	any bool
+	// deep matches descendants at any depth, rather than only children
+	deep bool
+}
+
+// Query returns the values in t which match path.  The path is a list of tags separated by
+// "/", and each tag selects the matching children of the values selected by the previous
+// tag, starting with the children of t, e.g.:
+//
+//	Query(msg, "BatchItem/ResponsePayload/UniqueIdentifier")
+//
+// Tags may be given by name or hex value, as accepted by DefaultRegistry.ParseTag().  "*" matches
+// any tag.  "//" before a tag matches descendants at any depth, rather than only children, so
+// "//UniqueIdentifier" finds every Unique Identifier in the message.  A leading "/" matches the
+// first tag against t itself, rather than its children, e.g. "/ResponseMessage/BatchItem".
+//
+// The matching values are returned in the order they appear in t.  Each one contains
+// only the matching value, not the values following it.  If nothing matches, nil is returned.
+// Returns the error from Valid() if t is not valid, or ErrInvalidQuery if path is malformed.
+func Query(t TTLV, path string) ([]TTLV, error) {
+	steps, absolute, err := parseQuery(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := t.Valid(); err != nil {
+		return nil, err
+	}
+
+	t = t[:t.FullLen()]
+
+	var curr []TTLV
+
+	if absolute {
+		if !steps[0].matches(t) {
+			return nil, nil
+		}
+
+		curr = []TTLV{t}
+		steps = steps[1:]
+	} else {
+		curr = []TTLV{t}
+	}
+
+	for _, step := range steps {
+		var next []TTLV
+
+		// a value may be reached more than once by a deep step, from each of its
+		// ancestors, so only collect it once
+		seen := map[*byte]bool{}
+
+		collect := func(n TTLV) {
+			if step.matches(n) && !seen[&n[0]] {
+				seen[&n[0]] = true
+
+				next = append(next, n)
+			}
+		}
+
+		for _, c := range curr {
+			if step.deep {
+				walkDescendants(c, collect)
+			} else {
+				eachChild(c, collect)
+			}
+		}
+
+		if len(next) == 0 {
+			return nil, nil
+		}
+
+		curr = next
+	}
+
+	return curr, nil
+}
+
+func parseQuery(path string) (steps []queryStep, absolute bool, err error) {
+	if path == "" {
+		return nil, false, merry.Append(ErrInvalidQuery, "empty path")
+	}
+
+	orig := path
+	absolute = strings.HasPrefix(path, "/") && !strings.HasPrefix(path, "//")
+
+	// strip one leading slash: the remaining "/" of a leading "//" then splits into a single
+	// empty segment, like a "//" anywhere else in the path
+	if strings.HasPrefix(path, "/") {
+		path = path[1:]
+	}
+
+	deep := false
+
+	for _, seg := range strings.Split(path, "/") {
+		if seg == "" {
+			if deep {
+				return nil, false, merry.Appendf(ErrInvalidQuery, "too many slashes in %q", orig)
+			}
+
+			// an empty segment comes from "//"
+			deep = true
+
+			continue
+		}
+
+		step := queryStep{deep: deep, any: seg == "*"}
+		deep = false
+
+		if !step.any {
+			step.tag, err = DefaultRegistry.ParseTag(seg)
+			if err != nil {
+				return nil, false, merry.WithCause(ErrInvalidQuery, err).Appendf("%q: %v", seg, err)
+			}
+		}
+
+		steps = append(steps, step)
+	}
+
+	if deep {
+		return nil, false, merry.Appendf(ErrInvalidQuery, "%q ends with a slash", orig)
+	}
+
+	return steps, absolute, nil
+}
+
+func (s queryStep) matches(t TTLV) bool {
+	return s.any || t.Tag() == s.tag
+}
+
+// eachChild calls fn with each value inside t, if t is a Structure.  Each value
+// passed to fn is trimmed to exclude the values which follow it.
+func eachChild(t TTLV, fn func(TTLV)) {
+	if t.Type() != TypeStructure {
+		return
+	}
+
+	for n := t.ValueStructure(); len(n) > 0; n = n.Next() {
+		fn(n[:n.FullLen()])
+	}
+}
+
+// walkDescendants calls fn with every value nested inside t, at any depth, in the
+// order they appear in t.
+func walkDescendants(t TTLV, fn func(TTLV)) {
+	eachChild(t, func(n TTLV) {
+		fn(n)
+		walkDescendants(n, fn)
+	})
+}
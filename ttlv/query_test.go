@@ -0,0 +1,81 @@
+package ttlv_test
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/gemalto/kmip-go/kmip14"
+	. "github.com/gemalto/kmip-go/ttlv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuery(t *testing.T) {
+	msg, err := Marshal(NewStruct(TagResponseMessage,
+		NewStruct(TagResponseHeader,
+			NewValue(TagBatchCount, 2),
+		),
+		NewStruct(TagBatchItem,
+			NewValue(TagOperation, OperationCreate),
+			NewStruct(TagResponsePayload,
+				NewValue(TagUniqueIdentifier, "1"),
+			),
+		),
+		NewStruct(TagBatchItem,
+			NewValue(TagOperation, OperationGet),
+			NewStruct(TagResponsePayload,
+				NewValue(TagUniqueIdentifier, "2"),
+				NewStruct(TagSymmetricKey,
+					NewStruct(TagKeyBlock,
+						NewValue(TagUniqueIdentifier, "nested"),
+					),
+				),
+			),
+		),
+	))
+	require.NoError(t, err)
+
+	query := func(path string) []interface{} {
+		t.Helper()
+
+		res, err := Query(msg, path)
+		require.NoError(t, err)
+
+		var vals []interface{}
+
+		for _, r := range res {
+			require.NoError(t, r.Valid())
+			require.Len(t, r, r.FullLen(), "results should not include the values following them")
+
+			if r.Type() == TypeStructure {
+				vals = append(vals, r.Tag())
+			} else {
+				vals = append(vals, r.Value())
+			}
+		}
+
+		return vals
+	}
+
+	assert.Equal(t, []interface{}{"1", "2"}, query("BatchItem/ResponsePayload/UniqueIdentifier"))
+	assert.Equal(t, []interface{}{"1", "2"}, query("/ResponseMessage/BatchItem/ResponsePayload/UniqueIdentifier"))
+	assert.Equal(t, []interface{}{"1", "2"}, query("0x42000f/ResponsePayload/UniqueIdentifier"))
+	assert.Equal(t, []interface{}{"1", "2", "nested"}, query("//UniqueIdentifier"))
+	assert.Equal(t, []interface{}{"1", "2", "nested"}, query("BatchItem//UniqueIdentifier"))
+	assert.Equal(t, []interface{}{"nested"}, query("//SymmetricKey/*/UniqueIdentifier"))
+	assert.Equal(t, []interface{}{"nested"}, query("//*//KeyBlock/UniqueIdentifier"))
+	assert.Equal(t, []interface{}{TagResponsePayload, TagResponsePayload}, query("*/ResponsePayload"))
+	assert.Equal(t, []interface{}{EnumValue(OperationCreate), EnumValue(OperationGet)}, query("BatchItem/Operation"))
+
+	assert.Nil(t, query("/RequestMessage/BatchItem"))
+	assert.Nil(t, query("BatchItem/RequestPayload"))
+	assert.Nil(t, query("BatchItem/Operation/UniqueIdentifier"))
+
+	for _, path := range []string{"", "BatchItem/", "BatchItem///Operation", "NotATag", "0xzz"} {
+		_, err := Query(msg, path)
+		assert.True(t, errors.Is(err, ErrInvalidQuery), "%q: %v", path, err)
+	}
+
+	_, err = Query(msg[:len(msg)-8], "BatchItem")
+	assert.True(t, errors.Is(err, ErrValueTruncated), Details(err))
+}
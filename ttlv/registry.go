@@ -1,6 +1,8 @@
 package ttlv
 
 import (
+	"encoding/json"
+	"io"
 	"sort"
 
 	"github.com/ansel1/merry"
@@ -12,7 +14,8 @@ import (
 // values.  It can be replaced, or additional values can be registered with it.
 //
 // It is not currently concurrent-safe, so replace or configure it early in your
-// program.
+// program.  Registering tags with DefaultRegistry clears the marshaling metadata
+// cached for golang types, but replacing DefaultRegistry does not.
 var DefaultRegistry Registry
 
 // nolint:gochecknoinits
@@ -23,6 +26,8 @@ func init() {
 var (
 	ErrInvalidHexString     = kmiputil.ErrInvalidHexString
 	ErrUnregisteredEnumName = merry.New("unregistered enum name")
+	ErrMissingRequiredValue = merry.New("missing required value")
+	ErrInvalidOrder         = merry.New("values out of order")
 )
 
 // NormalizeName tranforms KMIP names from the spec into the
@@ -145,9 +150,13 @@ func (e *Enum) Bitmask() bool {
 // a KMIP spec.  It's used throughout the package to map values their canonical
 // and normalized names.
 type Registry struct {
-	enums map[Tag]EnumMap
-	tags  Enum
-	types Enum
+	enums      map[Tag]EnumMap
+	tags       Enum
+	types      Enum
+	structures map[Tag][]RequiredValue
+	orders     map[Tag][]Tag
+	// objectAttributes maps object types to the attributes which apply to them
+	objectAttributes map[uint32]map[Tag]bool
 }
 
 func (r *Registry) RegisterType(t Type, name string) {
@@ -156,6 +165,11 @@ func (r *Registry) RegisterType(t Type, name string) {
 
 func (r *Registry) RegisterTag(t Tag, name string) {
 	r.tags.RegisterValue(uint32(t), name)
+
+	if r == &DefaultRegistry {
+		// tags of struct fields are resolved by name using DefaultRegistry
+		resetTypeInfoCache()
+	}
 }
 
 func (r *Registry) RegisterEnum(t Tag, def EnumMap) {
@@ -192,6 +206,380 @@ func (r *Registry) IsEnum(t Tag) bool {
 	return false
 }
 
+// ValidateTypes checks that the values in t with tags registered as enums have the type
+// the registry implies: Enumeration for enum tags, and Integer for bitmask tags.  For example,
+// a KeyFormatType encoded as an Integer is rejected with an error like "KeyFormatType must be
+// Enumeration, got Integer".  Values of other types, and values whose tags aren't registered
+// as enums, aren't checked.  If t contains several concatenated values, all are checked.
+//
+// Returns an error wrapping ErrInvalidType on a mismatch, or the error from Valid() if t is
+// not valid TTLV.
+func (r *Registry) ValidateTypes(t TTLV) error {
+	for len(t) > 0 {
+		if err := t.Valid(); err != nil {
+			return err
+		}
+
+		if err := r.validateTypes(t); err != nil {
+			return err
+		}
+
+		t = t[t.FullLen():]
+	}
+
+	return nil
+}
+
+func (r *Registry) validateTypes(t TTLV) error {
+	switch t.Type() {
+	case TypeStructure:
+		for n := t.ValueStructure(); len(n) > 0; n = n.Next() {
+			if err := r.validateTypes(n); err != nil {
+				return merry.Prepend(err, r.FormatTag(t.Tag()))
+			}
+		}
+	case TypeEnumeration, TypeInteger:
+		enum := r.EnumForTag(t.Tag())
+		if enum == nil {
+			return nil
+		}
+
+		expected := TypeEnumeration
+		if enum.Bitmask() {
+			expected = TypeInteger
+		}
+
+		if t.Type() != expected {
+			return merry.Appendf(ErrInvalidType, "%s must be %s, got %s", r.FormatTag(t.Tag()), r.FormatType(expected), r.FormatType(t.Type()))
+		}
+	}
+
+	return nil
+}
+
+// RequiredValue describes a value which must be present in a Structure.  See
+// RegisterStructure().
+type RequiredValue struct {
+	Tag Tag
+	// Type is the type the value must have.  If zero, any type is accepted, for values
+	// which the spec allows to be encoded in more than one way, like Key Value.
+	Type Type
+}
+
+// RegisterStructure registers the values required in Structures with tag t, replacing any
+// values previously registered for t.  ValidateStructure() checks Structures against these.
+// The kmip14 package registers the required values of the most common 1.4 structures, and
+// vendors can register their own structures the same way.
+func (r *Registry) RegisterStructure(t Tag, required ...RequiredValue) {
+	if r.structures == nil {
+		r.structures = map[Tag][]RequiredValue{}
+	}
+
+	r.structures[t] = required
+}
+
+// RequiredValues returns the values registered as required in Structures with tag t, or
+// nil if none are registered.
+func (r *Registry) RequiredValues(t Tag) []RequiredValue {
+	return r.structures[t]
+}
+
+// ValidateStructure checks that each Structure in t, including nested Structures, contains
+// the values registered as required for its tag with RegisterStructure(), with the registered
+// types.  Structures whose tags have nothing registered aren't checked, other than
+// their contents.  If t contains several concatenated values, all are checked.
+//
+// A missing value is reported with an error wrapping ErrMissingRequiredValue, like
+// "KeyBlock missing required KeyFormatType".  A value with the wrong type is reported with an
+// error wrapping ErrInvalidType.  The error from Valid() is returned if t is not valid TTLV.
+func (r *Registry) ValidateStructure(t TTLV) error {
+	for len(t) > 0 {
+		if err := t.Valid(); err != nil {
+			return err
+		}
+
+		if err := r.validateStructure(t); err != nil {
+			return err
+		}
+
+		t = t[t.FullLen():]
+	}
+
+	return nil
+}
+
+func (r *Registry) validateStructure(t TTLV) error {
+	if t.Type() != TypeStructure {
+		return nil
+	}
+
+	for _, req := range r.structures[t.Tag()] {
+		n := t.ValueStructure()
+		for len(n) > 0 && n.Tag() != req.Tag {
+			n = n.Next()
+		}
+
+		switch {
+		case len(n) == 0:
+			return merry.Here(ErrMissingRequiredValue).
+				WithMessagef("%s missing required %s", r.FormatTag(t.Tag()), r.FormatTag(req.Tag))
+		case req.Type != 0 && n.Type() != req.Type:
+			return merry.Appendf(ErrInvalidType, "%s must be %s, got %s", r.FormatTag(req.Tag), r.FormatType(req.Type), r.FormatType(n.Type())).
+				Prepend(r.FormatTag(t.Tag()))
+		}
+	}
+
+	for n := t.ValueStructure(); len(n) > 0; n = n.Next() {
+		if err := r.validateStructure(n); err != nil {
+			return merry.Prepend(err, r.FormatTag(t.Tag()))
+		}
+	}
+
+	return nil
+}
+
+// RegisterOrder registers the order the spec defines for the values in Structures with tag t,
+// replacing any order previously registered for t.  ValidateOrder() and Reorder() use it.
+// Values whose tags aren't in order aren't constrained, and may appear anywhere in the
+// Structure.  The kmip14 package registers the order of the 1.4 message structures and the
+// most common base objects.  Orders which differ between protocol versions should be
+// registered in separate registries.
+func (r *Registry) RegisterOrder(t Tag, order ...Tag) {
+	if r.orders == nil {
+		r.orders = map[Tag][]Tag{}
+	}
+
+	r.orders[t] = order
+}
+
+// Order returns the order registered for the values in Structures with tag t, or nil if
+// none is registered.
+func (r *Registry) Order(t Tag) []Tag {
+	return r.orders[t]
+}
+
+// rank returns the position of child in the order registered for parent, or -1 if
+// child isn't in the order.
+func (r *Registry) rank(parent, child Tag) int {
+	for i, t := range r.orders[parent] {
+		if t == child {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// ValidateOrder checks that the values in each Structure in t, including nested Structures,
+// appear in the order registered for the Structure's tag with RegisterOrder().  If t contains
+// several concatenated values, all are checked.
+//
+// A value out of order is reported with an error wrapping ErrInvalidOrder, like
+// "RequestHeader: ProtocolVersion must come before BatchCount".  The error from Valid() is
+// returned if t is not valid TTLV.
+func (r *Registry) ValidateOrder(t TTLV) error {
+	for len(t) > 0 {
+		if err := t.Valid(); err != nil {
+			return err
+		}
+
+		if err := r.validateOrder(t); err != nil {
+			return err
+		}
+
+		t = t[t.FullLen():]
+	}
+
+	return nil
+}
+
+func (r *Registry) validateOrder(t TTLV) error {
+	if t.Type() != TypeStructure {
+		return nil
+	}
+
+	last, lastRank := TagNone, -1
+
+	for n := t.ValueStructure(); len(n) > 0; n = n.Next() {
+		rank := r.rank(t.Tag(), n.Tag())
+		if rank > -1 {
+			if rank < lastRank {
+				return merry.Here(ErrInvalidOrder).
+					WithMessagef("%s must come before %s", r.FormatTag(n.Tag()), r.FormatTag(last)).
+					Prepend(r.FormatTag(t.Tag()))
+			}
+
+			last, lastRank = n.Tag(), rank
+		}
+
+		if err := r.validateOrder(n); err != nil {
+			return merry.Prepend(err, r.FormatTag(t.Tag()))
+		}
+	}
+
+	return nil
+}
+
+// Reorder returns a copy of t in which the values of each Structure, including nested
+// Structures, are sorted into the order registered for the Structure's tag with
+// RegisterOrder().  Values whose tags aren't in the registered order keep their positions,
+// and values with the same tag keep their relative order.  If t contains several
+// concatenated values, all are reordered.  The error from Valid() is returned if t is not
+// valid TTLV.
+func (r *Registry) Reorder(t TTLV) (TTLV, error) {
+	out := make(TTLV, 0, len(t))
+
+	for len(t) > 0 {
+		if err := t.Valid(); err != nil {
+			return nil, err
+		}
+
+		out = r.reorder(out, t[:t.FullLen()])
+		t = t[t.FullLen():]
+	}
+
+	return out, nil
+}
+
+func (r *Registry) reorder(out, t TTLV) TTLV {
+	if t.Type() != TypeStructure {
+		return append(out, t...)
+	}
+
+	var children []TTLV
+	for n := t.ValueStructure(); len(n) > 0; n = n.Next() {
+		children = append(children, n[:n.FullLen()])
+	}
+
+	if r.orders[t.Tag()] != nil {
+		// sort the ordered values among the positions the ordered values occupy,
+		// leaving the others where they are
+		var positions []int
+
+		var ordered []TTLV
+
+		for i, c := range children {
+			if r.rank(t.Tag(), c.Tag()) > -1 {
+				positions = append(positions, i)
+				ordered = append(ordered, c)
+			}
+		}
+
+		sort.SliceStable(ordered, func(i, j int) bool {
+			return r.rank(t.Tag(), ordered[i].Tag()) < r.rank(t.Tag(), ordered[j].Tag())
+		})
+
+		for i, pos := range positions {
+			children[pos] = ordered[i]
+		}
+	}
+
+	// the length is unchanged, so the header can be copied as is
+	out = append(out, t[:lenHeader]...)
+	for _, c := range children {
+		out = r.reorder(out, c)
+	}
+
+	return out
+}
+
+// RegisterObjectAttributes registers attributes which apply to objects of objectType, which
+// is the value of an Object Type enumeration.  Attributes are added to those already registered
+// for objectType, so vendors can extend the standard object types, as well as register their
+// own.  The kmip14 package registers the attributes of the standard 1.4 object types.
+func (r *Registry) RegisterObjectAttributes(objectType uint32, attrs ...Tag) {
+	if r.objectAttributes == nil {
+		r.objectAttributes = map[uint32]map[Tag]bool{}
+	}
+
+	m := r.objectAttributes[objectType]
+	if m == nil {
+		m = map[Tag]bool{}
+		r.objectAttributes[objectType] = m
+	}
+
+	for _, t := range attrs {
+		m[t] = true
+	}
+}
+
+// ObjectAttributes returns the attributes registered for objectType, sorted by tag,
+// or nil if none are registered.
+func (r *Registry) ObjectAttributes(objectType uint32) []Tag {
+	m := r.objectAttributes[objectType]
+	if len(m) == 0 {
+		return nil
+	}
+
+	tags := make([]Tag, 0, len(m))
+	for t := range m {
+		tags = append(tags, t)
+	}
+
+	sort.Slice(tags, func(i, j int) bool {
+		return tags[i] < tags[j]
+	})
+
+	return tags
+}
+
+// AttributeApplies returns false if attributes are registered for objectType, and attr
+// isn't one of them.  If no attributes are registered for objectType, nothing is known
+// about it, so any attribute is assumed to apply.
+func (r *Registry) AttributeApplies(objectType uint32, attr Tag) bool {
+	m := r.objectAttributes[objectType]
+
+	return len(m) == 0 || m[attr]
+}
+
+// EnumEntry is a value registered in an enum, with its names.
+type EnumEntry struct {
+	Value uint32
+	// Name is the normalized name, e.g. "SymmetricKey"
+	Name string
+	// CanonicalName is the name from the spec, e.g. "Symmetric Key"
+	CanonicalName string
+}
+
+// EnumValues returns the values registered for an Enumeration tag, sorted by value.
+// This is useful for presenting the valid choices for a tag.  Returns an empty slice
+// if no enum is registered for the tag, or if the tag is registered as a bitmask.
+func (r *Registry) EnumValues(t Tag) []EnumEntry {
+	e := r.EnumForTag(t)
+	if e == nil || e.Bitmask() {
+		return []EnumEntry{}
+	}
+
+	return enumEntries(e)
+}
+
+// MaskBits returns the flags registered for a bitmask Integer tag, sorted by value.
+// Returns an empty slice if no bitmask is registered for the tag.
+func (r *Registry) MaskBits(t Tag) []EnumEntry {
+	e := r.EnumForTag(t)
+	if e == nil || !e.Bitmask() {
+		return []EnumEntry{}
+	}
+
+	return enumEntries(e)
+}
+
+func enumEntries(e EnumMap) []EnumEntry {
+	values := e.Values()
+	// Values() should already be sorted, but the interface doesn't require it
+	sort.Sort(uint32Slice(values))
+
+	out := make([]EnumEntry, 0, len(values))
+
+	for _, v := range values {
+		name, _ := e.Name(v)
+		canonicalName, _ := e.CanonicalName(v)
+		out = append(out, EnumEntry{Value: v, Name: name, CanonicalName: canonicalName})
+	}
+
+	return out
+}
+
 func (r *Registry) Tags() EnumMap {
 	return &r.tags
 }
@@ -208,6 +596,12 @@ func (r *Registry) FormatInt(t Tag, v int32) string {
 	return FormatInt(v, r.EnumForTag(t))
 }
 
+// MaskNames returns the names of the flags set in v, using the bitmask registered
+// for the tag.  See MaskNames().
+func (r *Registry) MaskNames(t Tag, v int32) []string {
+	return MaskNames(v, r.EnumForTag(t))
+}
+
 func (r *Registry) FormatTag(t Tag) string {
 	return FormatTag(uint32(t), &r.tags)
 }
@@ -240,6 +634,234 @@ func (r *Registry) ParseType(s string) (Type, error) {
 	return ParseType(s, &r.types)
 }
 
+// registryJSON is the document written by ExportJSON and read by LoadRegistryJSON.
+type registryJSON struct {
+	Types            []registryValueJSON            `json:"types"`
+	Tags             []registryTagJSON              `json:"tags"`
+	ObjectAttributes []registryObjectAttributesJSON `json:"objectAttributes,omitempty"`
+}
+
+// registryObjectAttributesJSON lists the attributes registered for an object type with
+// RegisterObjectAttributes.
+type registryObjectAttributesJSON struct {
+	ObjectType uint32   `json:"objectType"`
+	Attributes []uint32 `json:"attributes"`
+}
+
+type registryValueJSON struct {
+	Value         uint32 `json:"value"`
+	Name          string `json:"name"`
+	CanonicalName string `json:"canonicalName"`
+}
+
+type registryTagJSON struct {
+	registryValueJSON
+	// Type is the type of values with this tag, if it is known.  Only tags with
+	// registered enums have known types.
+	Type *Type             `json:"type,omitempty"`
+	Enum *registryEnumJSON `json:"enum,omitempty"`
+	// Required lists the values registered as required in Structures with this tag,
+	// with RegisterStructure.
+	Required []registryRequiredJSON `json:"required,omitempty"`
+	// Order lists the tags of the order registered for Structures with this tag,
+	// with RegisterOrder.
+	Order []uint32 `json:"order,omitempty"`
+}
+
+type registryRequiredJSON struct {
+	Tag uint32 `json:"tag"`
+	// Type is omitted if any type is accepted.
+	Type *Type `json:"type,omitempty"`
+}
+
+type registryEnumJSON struct {
+	Bitmask bool                `json:"bitmask,omitempty"`
+	Values  []registryValueJSON `json:"values"`
+}
+
+func enumValuesJSON(e EnumMap) []registryValueJSON {
+	entries := enumEntries(e)
+	out := make([]registryValueJSON, 0, len(entries))
+
+	for _, entry := range entries {
+		out = append(out, registryValueJSON(entry))
+	}
+
+	return out
+}
+
+// ExportJSON writes the registered types, tags, and enums to w as a JSON document.
+// Values are sorted, so the output is stable.  Each tag includes its canonical and
+// normalized name, and, if an enum is registered for the tag, the enum's values.
+// Tags of Structures also include the values required in them (see RegisterStructure())
+// and the order of their values (see RegisterOrder()), by tag value.  The attributes
+// registered for each object type with RegisterObjectAttributes() are listed by tag
+// value under "objectAttributes".
+//
+// The document can be loaded with LoadRegistryJSON.
+func (r *Registry) ExportJSON(w io.Writer) error {
+	doc := registryJSON{
+		Types: enumValuesJSON(&r.types),
+		Tags:  []registryTagJSON{},
+	}
+
+	tags := map[Tag]bool{}
+	for _, v := range r.tags.Values() {
+		tags[Tag(v)] = true
+	}
+
+	for t := range r.enums {
+		tags[t] = true
+	}
+
+	for t := range r.structures {
+		tags[t] = true
+	}
+
+	for t := range r.orders {
+		tags[t] = true
+	}
+
+	tagValues := make([]uint32, 0, len(tags))
+	for t := range tags {
+		tagValues = append(tagValues, uint32(t))
+	}
+
+	sort.Sort(uint32Slice(tagValues))
+
+	for _, v := range tagValues {
+		tag := Tag(v)
+		tj := registryTagJSON{}
+		tj.Value = v
+		tj.Name, _ = r.tags.Name(v)
+		tj.CanonicalName, _ = r.tags.CanonicalName(v)
+
+		if e := r.EnumForTag(tag); e != nil {
+			typ := TypeEnumeration
+			if e.Bitmask() {
+				typ = TypeInteger
+			}
+
+			tj.Type = &typ
+			tj.Enum = &registryEnumJSON{
+				Bitmask: e.Bitmask(),
+				Values:  enumValuesJSON(e),
+			}
+		}
+
+		for _, req := range r.structures[tag] {
+			rj := registryRequiredJSON{Tag: uint32(req.Tag)}
+			if req.Type != 0 {
+				typ := req.Type
+				rj.Type = &typ
+			}
+
+			tj.Required = append(tj.Required, rj)
+		}
+
+		for _, t := range r.orders[tag] {
+			tj.Order = append(tj.Order, uint32(t))
+		}
+
+		doc.Tags = append(doc.Tags, tj)
+	}
+
+	objectTypes := make([]uint32, 0, len(r.objectAttributes))
+	for ot := range r.objectAttributes {
+		objectTypes = append(objectTypes, ot)
+	}
+
+	sort.Sort(uint32Slice(objectTypes))
+
+	for _, ot := range objectTypes {
+		attrs := r.ObjectAttributes(ot)
+		if len(attrs) == 0 {
+			continue
+		}
+
+		oj := registryObjectAttributesJSON{ObjectType: ot, Attributes: make([]uint32, len(attrs))}
+		for i, t := range attrs {
+			oj.Attributes[i] = uint32(t)
+		}
+
+		doc.ObjectAttributes = append(doc.ObjectAttributes, oj)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return merry.Wrap(enc.Encode(doc))
+}
+
+// LoadRegistryJSON reads a document written by Registry.ExportJSON, and returns a new
+// Registry with the types, tags, enums, required values, orders, and object attributes in
+// the document registered.
+func LoadRegistryJSON(rd io.Reader) (*Registry, error) {
+	var doc registryJSON
+
+	if err := json.NewDecoder(rd).Decode(&doc); err != nil {
+		return nil, merry.Prepend(err, "decoding registry JSON")
+	}
+
+	var r Registry
+
+	for _, t := range doc.Types {
+		if t.Value > 0xff {
+			return nil, merry.Errorf("invalid type value: %#x", t.Value)
+		}
+
+		r.RegisterType(Type(t.Value), t.CanonicalName)
+	}
+
+	for _, t := range doc.Tags {
+		if t.CanonicalName != "" {
+			r.RegisterTag(Tag(t.Value), t.CanonicalName)
+		}
+
+		if t.Enum != nil {
+			e := NewEnum()
+			if t.Enum.Bitmask {
+				e = NewBitmask()
+			}
+
+			for _, v := range t.Enum.Values {
+				e.RegisterValue(v.Value, v.CanonicalName)
+			}
+
+			r.RegisterEnum(Tag(t.Value), &e)
+		}
+
+		if len(t.Required) > 0 {
+			required := make([]RequiredValue, len(t.Required))
+			for i, req := range t.Required {
+				required[i].Tag = Tag(req.Tag)
+				if req.Type != nil {
+					required[i].Type = *req.Type
+				}
+			}
+
+			r.RegisterStructure(Tag(t.Value), required...)
+		}
+
+		if len(t.Order) > 0 {
+			order := make([]Tag, len(t.Order))
+			for i, o := range t.Order {
+				order[i] = Tag(o)
+			}
+
+			r.RegisterOrder(Tag(t.Value), order...)
+		}
+	}
+
+	for _, oa := range doc.ObjectAttributes {
+		for _, t := range oa.Attributes {
+			r.RegisterObjectAttributes(oa.ObjectType, Tag(t))
+		}
+	}
+
+	return &r, nil
+}
+
 // uint32Slice attaches the methods of Interface to []int, sorting in increasing order.
 type uint32Slice []uint32
 
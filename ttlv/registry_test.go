@@ -1,10 +1,15 @@
 package ttlv_test
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
 	"testing"
 
 	. "github.com/gemalto/kmip-go/kmip14"
 	. "github.com/gemalto/kmip-go/ttlv"
+	"github.com/gemalto/kmip-go/ttlv/ttlvtest"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -43,6 +48,54 @@ func TestBitMaskString(t *testing.T) {
 	}
 }
 
+func TestRegistry_MaskNames(t *testing.T) {
+	tests := []struct {
+		name string
+		tag  Tag
+		in   int32
+		out  []string
+	}{
+		{
+			name: "flags",
+			tag:  TagCryptographicUsageMask,
+			in:   int32(CryptographicUsageMaskEncrypt | CryptographicUsageMaskDecrypt),
+			out:  []string{"Encrypt", "Decrypt"},
+		},
+		{
+			name: "unknownbits",
+			tag:  TagCryptographicUsageMask,
+			in:   int32(CryptographicUsageMaskSign | CryptographicUsageMask(0x00100000) | CryptographicUsageMask(0x00200000)),
+			out:  []string{"Sign", "0x00300000"},
+		},
+		{
+			name: "zero",
+			tag:  TagCryptographicUsageMask,
+			in:   0,
+			out:  []string{"0x00000000"},
+		},
+		{
+			name: "noflagsregistered",
+			tag:  TagBatchCount,
+			in:   12,
+			out:  []string{"0x0000000c"},
+		},
+		{
+			name: "highbit",
+			tag:  TagBatchCount,
+			in:   -1,
+			out:  []string{"0xffffffff"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			names := DefaultRegistry.MaskNames(tc.tag, tc.in)
+			assert.Equal(t, tc.out, names)
+			assert.Equal(t, DefaultRegistry.FormatInt(tc.tag, tc.in), strings.Join(names, "|"))
+		})
+	}
+}
+
 func TestParseInteger(t *testing.T) {
 	tests := []struct {
 		out CryptographicUsageMask
@@ -103,6 +156,55 @@ func TestParseInteger(t *testing.T) {
 	}
 }
 
+func TestParseEnum_unregistered(t *testing.T) {
+	tests := []struct {
+		in  string
+		out uint32
+	}{
+		{in: "Active", out: uint32(StateActive)},
+		{in: "0x00000001", out: 1},
+		{in: "0x000000ff", out: 0xff},
+		{in: "0xff", out: 0xff},
+		{in: "0x2", out: 2},
+		{in: "255", out: 255},
+	}
+
+	for _, testcase := range tests {
+		t.Run(testcase.in, func(t *testing.T) {
+			v, err := DefaultRegistry.ParseEnum(TagState, testcase.in)
+			require.NoError(t, err)
+			assert.Equal(t, testcase.out, v)
+
+			// hex and numeric values don't need a registered enum at all
+			if testcase.in != "Active" {
+				v, err = ParseEnum(testcase.in, nil)
+				require.NoError(t, err)
+				assert.Equal(t, testcase.out, v)
+			}
+		})
+	}
+
+	_, err := DefaultRegistry.ParseEnum(TagState, "Hibernating")
+	require.True(t, errors.Is(err, ErrUnregisteredEnumName), "got %v", err)
+
+	_, err = DefaultRegistry.ParseEnum(TagState, "0xzz")
+	require.True(t, errors.Is(err, ErrInvalidHexString), "got %v", err)
+
+	// odd-length hex is only accepted for enumerations
+	_, err = ParseTag("0x2", nil)
+	require.True(t, errors.Is(err, ErrInvalidHexString), "got %v", err)
+
+	_, err = DefaultRegistry.ParseInt(TagCryptographicUsageMask, "0x2")
+	require.True(t, errors.Is(err, ErrInvalidHexString), "got %v", err)
+
+	mask, err := DefaultRegistry.ParseInt(TagCryptographicUsageMask, "Sign|0x100000")
+	require.NoError(t, err)
+	assert.Equal(t, int32(CryptographicUsageMaskSign)|0x00100000, mask)
+
+	_, err = DefaultRegistry.ParseInt(TagCryptographicUsageMask, "Sign|Levitate")
+	require.True(t, errors.Is(err, ErrUnregisteredEnumName), "got %v", err)
+}
+
 func TestNormalizeNames(t *testing.T) {
 	tests := map[string]string{
 		"Structure":                       "Structure",
@@ -128,3 +230,419 @@ func TestNormalizeNames(t *testing.T) {
 		})
 	}
 }
+
+func TestRegistry_ExportJSON(t *testing.T) {
+	var buf bytes.Buffer
+
+	require.NoError(t, DefaultRegistry.ExportJSON(&buf))
+
+	var doc struct {
+		Types []json.RawMessage
+		Tags  []struct {
+			Value         uint32
+			Name          string
+			CanonicalName string
+			Type          *Type
+			Enum          *struct {
+				Bitmask bool
+				Values  []struct {
+					Value         uint32
+					Name          string
+					CanonicalName string
+				}
+			}
+			Required []struct {
+				Tag  uint32
+				Type *Type
+			}
+			Order []uint32
+		}
+		ObjectAttributes []struct {
+			ObjectType uint32
+			Attributes []uint32
+		}
+	}
+
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &doc))
+	assert.Len(t, doc.Types, 11)
+
+	// sorted by object type, then tag
+	require.NotEmpty(t, doc.ObjectAttributes)
+	assert.Equal(t, uint32(ObjectTypeCertificate), doc.ObjectAttributes[0].ObjectType)
+
+	for _, oa := range doc.ObjectAttributes {
+		assert.Equal(t, len(DefaultRegistry.ObjectAttributes(oa.ObjectType)), len(oa.Attributes))
+
+		if oa.ObjectType == uint32(ObjectTypeSecretData) {
+			assert.Contains(t, oa.Attributes, uint32(TagCryptographicParameters))
+		}
+	}
+
+	// sorted by value
+	for i := 1; i < len(doc.Tags); i++ {
+		require.Less(t, doc.Tags[i-1].Value, doc.Tags[i].Value)
+	}
+
+	var found int
+
+	for _, tag := range doc.Tags {
+		switch Tag(tag.Value) {
+		case TagObjectType:
+			found++
+
+			assert.Equal(t, "ObjectType", tag.Name)
+			assert.Equal(t, "Object Type", tag.CanonicalName)
+			require.NotNil(t, tag.Type)
+			assert.Equal(t, TypeEnumeration, *tag.Type)
+			require.NotNil(t, tag.Enum)
+			assert.False(t, tag.Enum.Bitmask)
+			assert.Equal(t, uint32(ObjectTypeCertificate), tag.Enum.Values[0].Value)
+			assert.Equal(t, "Certificate", tag.Enum.Values[0].CanonicalName)
+		case TagCryptographicUsageMask:
+			found++
+
+			require.NotNil(t, tag.Type)
+			assert.Equal(t, TypeInteger, *tag.Type)
+			require.NotNil(t, tag.Enum)
+			assert.True(t, tag.Enum.Bitmask)
+		case TagComment:
+			found++
+
+			assert.Nil(t, tag.Type)
+			assert.Nil(t, tag.Enum)
+			assert.Nil(t, tag.Required)
+			assert.Nil(t, tag.Order)
+		case TagRequestMessage:
+			found++
+
+			assert.Equal(t, []uint32{uint32(TagRequestHeader), uint32(TagBatchItem)}, tag.Order)
+		case TagKeyBlock:
+			found++
+
+			require.Len(t, tag.Required, len(DefaultRegistry.RequiredValues(TagKeyBlock)))
+
+			for i, req := range DefaultRegistry.RequiredValues(TagKeyBlock) {
+				assert.Equal(t, uint32(req.Tag), tag.Required[i].Tag)
+
+				if req.Type == 0 {
+					assert.Nil(t, tag.Required[i].Type)
+				} else {
+					require.NotNil(t, tag.Required[i].Type)
+					assert.Equal(t, req.Type, *tag.Required[i].Type)
+				}
+			}
+		}
+	}
+
+	assert.Equal(t, 5, found)
+}
+
+func TestLoadRegistryJSON(t *testing.T) {
+	var buf bytes.Buffer
+
+	require.NoError(t, DefaultRegistry.ExportJSON(&buf))
+
+	r, err := LoadRegistryJSON(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+
+	// the loaded registry exports the same document
+	var buf2 bytes.Buffer
+
+	require.NoError(t, r.ExportJSON(&buf2))
+	assert.Equal(t, buf.String(), buf2.String())
+
+	tag, err := r.ParseTag("Cryptographic Algorithm")
+	require.NoError(t, err)
+	assert.Equal(t, TagCryptographicAlgorithm, tag)
+	assert.Equal(t, "AES", r.FormatEnum(TagCryptographicAlgorithm, uint32(CryptographicAlgorithmAES)))
+	assert.Equal(t, "Encrypt|Decrypt", r.FormatInt(TagCryptographicUsageMask, int32(CryptographicUsageMaskEncrypt|CryptographicUsageMaskDecrypt)))
+	assert.Equal(t, "0x00000000", r.FormatInt(TagCryptographicUsageMask, 0))
+	assert.True(t, r.IsBitmask(TagCryptographicUsageMask))
+	assert.Equal(t, "TextString", r.FormatType(TypeTextString))
+	assert.False(t, r.AttributeApplies(uint32(ObjectTypeSymmetricKey), TagCertificateType))
+	assert.True(t, r.AttributeApplies(uint32(ObjectTypeCertificate), TagCertificateType))
+	assert.Equal(t, DefaultRegistry.RequiredValues(TagKeyBlock), r.RequiredValues(TagKeyBlock))
+	assert.Equal(t, DefaultRegistry.Order(TagRequestHeader), r.Order(TagRequestHeader))
+
+	_, err = LoadRegistryJSON(strings.NewReader(`{"types":[{"value":256}]}`))
+	require.Error(t, err)
+
+	_, err = LoadRegistryJSON(strings.NewReader(`[`))
+	require.Error(t, err)
+}
+
+func TestRegistry_ValidateTypes(t *testing.T) {
+	valid, err := Marshal(Value{Tag: TagKeyBlock, Value: Values{
+		{Tag: TagKeyFormatType, Value: EnumValue(KeyFormatTypeRaw)},
+		{Tag: TagCryptographicUsageMask, Value: int32(CryptographicUsageMaskEncrypt)},
+		{Tag: TagCryptographicLength, Value: 256},
+		{Tag: TagComment, Value: "red"},
+	}})
+	require.NoError(t, err)
+	require.NoError(t, DefaultRegistry.ValidateTypes(valid))
+
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf)
+	require.NoError(t, enc.EncodeStructure(TagKeyBlock, func(e *Encoder) error {
+		e.EncodeInteger(TagKeyFormatType, 5)
+		return nil
+	}))
+	require.NoError(t, enc.Flush())
+
+	invalid := TTLV(buf.Bytes())
+
+	err = DefaultRegistry.ValidateTypes(invalid)
+	require.True(t, errors.Is(err, ErrInvalidType), Details(err))
+	assert.EqualError(t, err, "KeyBlock: invalid KMIP type: KeyFormatType must be Enumeration, got Integer")
+
+	buf = bytes.NewBuffer(nil)
+	enc = NewEncoder(buf)
+	enc.EncodeEnumeration(TagCryptographicUsageMask, 1)
+	require.NoError(t, enc.Flush())
+
+	mask := TTLV(buf.Bytes())
+
+	err = DefaultRegistry.ValidateTypes(mask)
+	require.True(t, errors.Is(err, ErrInvalidType), Details(err))
+	assert.EqualError(t, err, "invalid KMIP type: CryptographicUsageMask must be Integer, got Enumeration")
+
+	// all concatenated values are checked
+	err = DefaultRegistry.ValidateTypes(append(append(TTLV{}, valid...), invalid...))
+	require.True(t, errors.Is(err, ErrInvalidType), Details(err))
+
+	err = DefaultRegistry.ValidateTypes(valid[:len(valid)-1])
+	require.True(t, errors.Is(err, ErrValueTruncated), Details(err))
+}
+
+func TestRegistry_EnumValues(t *testing.T) {
+	values := DefaultRegistry.EnumValues(TagObjectType)
+	require.NotEmpty(t, values)
+	assert.Equal(t, EnumEntry{Value: uint32(ObjectTypeCertificate), Name: "Certificate", CanonicalName: "Certificate"}, values[0])
+	assert.Contains(t, values, EnumEntry{Value: uint32(ObjectTypeSymmetricKey), Name: "SymmetricKey", CanonicalName: "Symmetric Key"})
+
+	bits := DefaultRegistry.MaskBits(TagCryptographicUsageMask)
+	require.NotEmpty(t, bits)
+	assert.Equal(t, EnumEntry{Value: uint32(CryptographicUsageMaskSign), Name: "Sign", CanonicalName: "Sign"}, bits[0])
+
+	// tags which aren't the right kind of enum return empty slices
+	assert.Equal(t, []EnumEntry{}, DefaultRegistry.EnumValues(TagCryptographicUsageMask))
+	assert.Equal(t, []EnumEntry{}, DefaultRegistry.MaskBits(TagObjectType))
+	assert.Equal(t, []EnumEntry{}, DefaultRegistry.EnumValues(TagComment))
+	assert.Equal(t, []EnumEntry{}, DefaultRegistry.MaskBits(TagComment))
+}
+
+func TestRegistry_ValidateStructure(t *testing.T) {
+	valid, err := Marshal(NewStruct(TagSymmetricKey,
+		NewStruct(TagKeyBlock,
+			NewValue(TagKeyFormatType, KeyFormatTypeRaw),
+			NewStruct(TagKeyValue,
+				NewValue(TagKeyMaterial, []byte{1, 2, 3}),
+			),
+		),
+	))
+	require.NoError(t, err)
+	require.NoError(t, DefaultRegistry.ValidateStructure(valid))
+
+	missing, err := Marshal(NewStruct(TagSymmetricKey,
+		NewStruct(TagKeyBlock,
+			NewStruct(TagKeyValue,
+				NewValue(TagKeyMaterial, []byte{1, 2, 3}),
+			),
+		),
+	))
+	require.NoError(t, err)
+
+	err = DefaultRegistry.ValidateStructure(missing)
+	require.True(t, errors.Is(err, ErrMissingRequiredValue), Details(err))
+	assert.EqualError(t, err, "SymmetricKey: KeyBlock missing required KeyFormatType")
+
+	wrongType, err := Marshal(NewStruct(TagCredential,
+		NewValue(TagCredentialType, "password"),
+		NewValue(TagCredentialValue, "secret"),
+	))
+	require.NoError(t, err)
+
+	err = DefaultRegistry.ValidateStructure(wrongType)
+	require.True(t, errors.Is(err, ErrInvalidType), Details(err))
+	assert.EqualError(t, err, "Credential: invalid KMIP type: CredentialType must be Enumeration, got TextString")
+
+	// all concatenated values are checked
+	err = DefaultRegistry.ValidateStructure(append(append(TTLV{}, valid...), missing...))
+	require.True(t, errors.Is(err, ErrMissingRequiredValue), Details(err))
+
+	// vendor structures can be registered
+	var r Registry
+
+	r.RegisterStructure(Tag(0x540001), RequiredValue{Tag: Tag(0x540002)})
+	assert.Equal(t, []RequiredValue{{Tag: Tag(0x540002)}}, r.RequiredValues(Tag(0x540001)))
+	assert.Nil(t, r.RequiredValues(TagKeyBlock))
+
+	vendor, err := Marshal(NewStruct(Tag(0x540001), NewValue(Tag(0x540003), 1)))
+	require.NoError(t, err)
+
+	err = r.ValidateStructure(vendor)
+	require.True(t, errors.Is(err, ErrMissingRequiredValue), Details(err))
+	assert.EqualError(t, err, "0x540001 missing required 0x540002")
+
+	// structures without registered values aren't checked
+	require.NoError(t, r.ValidateStructure(missing))
+}
+
+func TestRegistry_ValidateOrder(t *testing.T) {
+	ordered, err := Marshal(NewStruct(TagRequestMessage,
+		NewStruct(TagRequestHeader,
+			NewStruct(TagProtocolVersion,
+				NewValue(TagProtocolVersionMajor, 1),
+				NewValue(TagProtocolVersionMinor, 4),
+			),
+			NewValue(Tag(0x540001), "vendor"),
+			NewValue(TagBatchCount, 1),
+		),
+		NewStruct(TagBatchItem,
+			NewValue(TagOperation, OperationGet),
+			NewStruct(TagRequestPayload),
+		),
+	))
+	require.NoError(t, err)
+	require.NoError(t, DefaultRegistry.ValidateOrder(ordered))
+
+	// the header's values are reversed, and the vendor value, which isn't in the
+	// registered order, is in a different place
+	unordered, err := Marshal(NewStruct(TagRequestMessage,
+		NewStruct(TagRequestHeader,
+			NewValue(TagBatchCount, 1),
+			NewValue(Tag(0x540001), "vendor"),
+			NewStruct(TagProtocolVersion,
+				NewValue(TagProtocolVersionMinor, 4),
+				NewValue(TagProtocolVersionMajor, 1),
+			),
+		),
+		NewStruct(TagBatchItem,
+			NewValue(TagOperation, OperationGet),
+			NewStruct(TagRequestPayload),
+		),
+	))
+	require.NoError(t, err)
+
+	err = DefaultRegistry.ValidateOrder(unordered)
+	require.True(t, errors.Is(err, ErrInvalidOrder), Details(err))
+	assert.EqualError(t, err, "RequestMessage: RequestHeader: ProtocolVersion must come before BatchCount")
+
+	// reordering sorts the registered values, and leaves the others in place
+	reordered, err := DefaultRegistry.Reorder(unordered)
+	require.NoError(t, err)
+	require.NoError(t, DefaultRegistry.ValidateOrder(reordered))
+
+	expected, err := Marshal(NewStruct(TagRequestMessage,
+		NewStruct(TagRequestHeader,
+			NewStruct(TagProtocolVersion,
+				NewValue(TagProtocolVersionMajor, 1),
+				NewValue(TagProtocolVersionMinor, 4),
+			),
+			NewValue(Tag(0x540001), "vendor"),
+			NewValue(TagBatchCount, 1),
+		),
+		NewStruct(TagBatchItem,
+			NewValue(TagOperation, OperationGet),
+			NewStruct(TagRequestPayload),
+		),
+	))
+	require.NoError(t, err)
+	ttlvtest.AssertTTLVEqual(t, expected, reordered)
+
+	// structures without a registered order aren't checked
+	var r Registry
+	require.NoError(t, r.ValidateOrder(unordered))
+	assert.Nil(t, r.Order(TagRequestHeader))
+
+	_, err = DefaultRegistry.Reorder(unordered[:len(unordered)-8])
+	require.True(t, errors.Is(err, ErrValueTruncated), Details(err))
+}
+
+func TestEncoder_ValidateOrder(t *testing.T) {
+	v := NewStruct(TagProtocolVersion,
+		NewValue(TagProtocolVersionMinor, 4),
+		NewValue(TagProtocolVersionMajor, 1),
+	)
+
+	// off by default
+	var buf bytes.Buffer
+	require.NoError(t, NewEncoder(&buf).Encode(v))
+
+	buf.Reset()
+
+	enc := NewEncoder(&buf)
+	enc.ValidateOrder = true
+	err := enc.Encode(v)
+	require.True(t, errors.Is(err, ErrInvalidOrder), Details(err))
+	assert.Zero(t, buf.Len())
+
+	enc.Reorder = true
+	require.NoError(t, enc.Encode(v))
+
+	expected, err := Marshal(NewStruct(TagProtocolVersion,
+		NewValue(TagProtocolVersionMajor, 1),
+		NewValue(TagProtocolVersionMinor, 4),
+	))
+	require.NoError(t, err)
+	ttlvtest.AssertTTLVEqual(t, expected, buf.Bytes())
+
+	// another registry can be used, e.g. with a different order
+	var r Registry
+	r.RegisterOrder(TagProtocolVersion, TagProtocolVersionMinor, TagProtocolVersionMajor)
+
+	buf.Reset()
+
+	enc = NewEncoder(&buf)
+	enc.Registry = &r
+	enc.ValidateOrder = true
+	require.NoError(t, enc.Encode(v))
+
+	err = enc.Encode(expected)
+	require.True(t, errors.Is(err, ErrInvalidOrder), Details(err))
+
+	enc.Reorder = true
+	buf.Reset()
+	require.NoError(t, enc.Encode(expected))
+	reversed, err := Marshal(v)
+	require.NoError(t, err)
+	ttlvtest.AssertTTLVEqual(t, reversed, buf.Bytes())
+
+	// and for ValidateTypes, so a registry with no enums accepts any type
+	buf.Reset()
+
+	enc = NewEncoder(&buf)
+	enc.Registry = &r
+	enc.ValidateTypes = true
+	require.NoError(t, enc.Encode(NewValue(TagKeyFormatType, int32(5))))
+}
+
+func TestRegistry_ObjectAttributes(t *testing.T) {
+	var r Registry
+
+	// nothing registered: every attribute applies
+	assert.True(t, r.AttributeApplies(uint32(ObjectTypeSymmetricKey), TagCertificateType))
+	assert.Nil(t, r.ObjectAttributes(uint32(ObjectTypeSymmetricKey)))
+
+	Register(&r)
+
+	assert.True(t, r.AttributeApplies(uint32(ObjectTypeSymmetricKey), TagCryptographicLength))
+	assert.False(t, r.AttributeApplies(uint32(ObjectTypeSymmetricKey), TagCertificateType))
+	assert.True(t, r.AttributeApplies(uint32(ObjectTypeCertificate), TagCertificateType))
+	assert.Equal(t, DefaultRegistry.RequiredValues(TagKeyBlock), r.RequiredValues(TagKeyBlock))
+	assert.Equal(t, DefaultRegistry.Order(TagRequestHeader), r.Order(TagRequestHeader))
+	assert.False(t, r.AttributeApplies(uint32(ObjectTypeOpaqueObject), TagCryptographicAlgorithm))
+	assert.True(t, r.AttributeApplies(uint32(ObjectTypeTemplate), TagCertificateType))
+	assert.False(t, r.AttributeApplies(uint32(ObjectTypeSymmetricKey), TagCryptographicDomainParameters))
+	assert.True(t, r.AttributeApplies(uint32(ObjectTypePrivateKey), TagCryptographicDomainParameters))
+	assert.True(t, r.AttributeApplies(uint32(ObjectTypeSecretData), TagCryptographicParameters))
+
+	// vendors can extend the standard types, and register their own
+	r.RegisterObjectAttributes(uint32(ObjectTypeOpaqueObject), TagCryptographicAlgorithm)
+	assert.True(t, r.AttributeApplies(uint32(ObjectTypeOpaqueObject), TagCryptographicAlgorithm))
+	assert.True(t, r.AttributeApplies(uint32(ObjectTypeOpaqueObject), TagName))
+
+	r.RegisterObjectAttributes(0x80000001, TagName, TagObjectType)
+	assert.Equal(t, []Tag{TagName, TagObjectType}, r.ObjectAttributes(0x80000001))
+	assert.False(t, r.AttributeApplies(0x80000001, TagState))
+}
@@ -1,5 +1,7 @@
 package ttlv
 
+import "sort"
+
 const (
 	TagNone               = Tag(0)
 	tagAttributeName  Tag = 0x42000a
@@ -20,15 +22,26 @@ func (t Tag) CanonicalName() string {
 	return DefaultRegistry.FormatTagCanonical(t)
 }
 
+// MarshalText implements encoding.TextMarshaler, so tags encode as their
+// normalized names (or hex values, if not registered) in text formats, like JSON
+// object keys.
 func (t Tag) MarshalText() (text []byte, err error) {
 	return []byte(t.String()), nil
 }
 
+// UnmarshalText implements encoding.TextUnmarshaler.  It parses
+// the text with ParseTag, so it accepts the normalized name, the canonical
+// name, or a hex value.
 func (t *Tag) UnmarshalText(text []byte) (err error) {
 	*t, err = DefaultRegistry.ParseTag(string(text))
 	return
 }
 
+// Set implements flag.Value.  See UnmarshalText.
+func (t *Tag) Set(s string) error {
+	return t.UnmarshalText([]byte(s))
+}
+
 const (
 	minStandardTag uint32 = 0x00420000
 	maxStandardTag uint32 = 0x00430000
@@ -36,15 +49,104 @@ const (
 	maxCustomTag   uint32 = 0x00550000
 )
 
-// Valid checks whether the tag's numeric value is valid according to
-// the ranges in the spec.
-func (t Tag) Valid() bool {
+// TagNamespace classifies tags by the range of values the spec reserves them from.
+type TagNamespace int
+
+const (
+	// TagNamespaceInvalid is the namespace of values outside the ranges reserved for tags.
+	TagNamespaceInvalid TagNamespace = iota
+	// TagNamespaceStandard is the namespace of tags defined by the spec, 0x420000 to 0x42FFFF.
+	TagNamespaceStandard
+	// TagNamespaceVendor is the namespace of extension tags, 0x540000 to 0x54FFFF, which vendors
+	// use for their own values.
+	TagNamespaceVendor
+)
+
+func (n TagNamespace) String() string {
+	switch n {
+	case TagNamespaceStandard:
+		return "Standard"
+	case TagNamespaceVendor:
+		return "Vendor"
+	default:
+		return "Invalid"
+	}
+}
+
+// Namespace returns the namespace of the tag, based on its numeric value.
+func (t Tag) Namespace() TagNamespace {
 	switch {
 	case uint32(t) >= minStandardTag && uint32(t) < maxStandardTag:
-		return true
+		return TagNamespaceStandard
 	case uint32(t) >= minCustomTag && uint32(t) < maxCustomTag:
-		return true
+		return TagNamespaceVendor
 	default:
-		return false
+		return TagNamespaceInvalid
 	}
 }
+
+// IsStandard returns true if the tag is in the range of tags defined by the spec.
+func (t Tag) IsStandard() bool {
+	return t.Namespace() == TagNamespaceStandard
+}
+
+// IsVendor returns true if the tag is in the range of extension tags.
+func (t Tag) IsVendor() bool {
+	return t.Namespace() == TagNamespaceVendor
+}
+
+// Valid checks whether the tag's numeric value is valid according to
+// the ranges in the spec.
+func (t Tag) Valid() bool {
+	return t.Namespace() != TagNamespaceInvalid
+}
+
+// TagSet is a set of tags, for checking tag membership, e.g. to decide which
+// values to redact or filter.  The zero value is an empty set, ready to use.
+type TagSet struct {
+	tags map[Tag]struct{}
+}
+
+// NewTagSet returns a set containing the tags.
+func NewTagSet(tags ...Tag) TagSet {
+	s := TagSet{tags: make(map[Tag]struct{}, len(tags))}
+	for _, t := range tags {
+		s.tags[t] = struct{}{}
+	}
+
+	return s
+}
+
+// Add adds tags to the set.
+func (s *TagSet) Add(tags ...Tag) {
+	if s.tags == nil {
+		s.tags = make(map[Tag]struct{}, len(tags))
+	}
+
+	for _, t := range tags {
+		s.tags[t] = struct{}{}
+	}
+}
+
+// Contains returns true if the tag is in the set.
+func (s TagSet) Contains(t Tag) bool {
+	_, ok := s.tags[t]
+	return ok
+}
+
+// Len returns the number of tags in the set.
+func (s TagSet) Len() int {
+	return len(s.tags)
+}
+
+// Tags returns the tags in the set, sorted by value.
+func (s TagSet) Tags() []Tag {
+	tags := make([]Tag, 0, len(s.tags))
+	for t := range s.tags {
+		tags = append(tags, t)
+	}
+
+	sort.Slice(tags, func(i, j int) bool { return tags[i] < tags[j] })
+
+	return tags
+}
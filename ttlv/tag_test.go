@@ -1,12 +1,132 @@
 package ttlv_test
 
 import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"io/ioutil"
 	"testing"
 
 	"github.com/gemalto/kmip-go/kmip14"
+	"github.com/gemalto/kmip-go/ttlv"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestTag_CanonicalName(t *testing.T) {
 	assert.Equal(t, "Cryptographic Algorithm", kmip14.TagCryptographicAlgorithm.CanonicalName())
 }
+
+func TestTag_MarshalText_json(t *testing.T) {
+	type config struct {
+		Redact []ttlv.Tag
+		Limits map[ttlv.Tag]int
+		Type   ttlv.Type
+	}
+
+	c := config{
+		Redact: []ttlv.Tag{kmip14.TagKeyMaterial, ttlv.Tag(0x540001)},
+		Limits: map[ttlv.Tag]int{kmip14.TagBatchCount: 5},
+		Type:   ttlv.TypeByteString,
+	}
+
+	b, err := json.Marshal(c)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"Redact":["KeyMaterial","0x540001"],"Limits":{"BatchCount":5},"Type":"ByteString"}`, string(b))
+
+	var c2 config
+
+	require.NoError(t, json.Unmarshal(b, &c2))
+	assert.Equal(t, c, c2)
+
+	// canonical names are accepted too
+	require.NoError(t, json.Unmarshal([]byte(`{"Redact":["Key Material"],"Type":"0x0c"}`), &c2))
+	assert.Equal(t, []ttlv.Tag{kmip14.TagKeyMaterial}, c2.Redact)
+	assert.Equal(t, ttlv.Type(0x0c), c2.Type)
+
+	// unknown names are errors
+	err = json.Unmarshal([]byte(`{"Redact":["NotATag"]}`), &c2)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ttlv.ErrUnregisteredEnumName), ttlv.Details(err))
+
+	err = json.Unmarshal([]byte(`{"Type":"NotAType"}`), &c2)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ttlv.ErrUnregisteredEnumName), ttlv.Details(err))
+}
+
+func TestTag_Set(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+
+	var tag ttlv.Tag
+
+	var typ ttlv.Type
+
+	fs.Var(&tag, "tag", "")
+	fs.Var(&typ, "type", "")
+
+	require.NoError(t, fs.Parse([]string{"-tag", "CryptographicAlgorithm", "-type", "TextString"}))
+	assert.Equal(t, kmip14.TagCryptographicAlgorithm, tag)
+	assert.Equal(t, ttlv.TypeTextString, typ)
+
+	require.NoError(t, fs.Parse([]string{"-tag", "0x540002"}))
+	assert.Equal(t, ttlv.Tag(0x540002), tag)
+	assert.Equal(t, "0x540002", tag.String())
+
+	require.Error(t, fs.Parse([]string{"-tag", "NotATag"}))
+	require.Error(t, fs.Parse([]string{"-type", "0x1234"}))
+}
+
+func TestTag_Namespace(t *testing.T) {
+	tests := []struct {
+		tag       ttlv.Tag
+		namespace ttlv.TagNamespace
+	}{
+		{kmip14.TagCryptographicAlgorithm, ttlv.TagNamespaceStandard},
+		{ttlv.Tag(0x420000), ttlv.TagNamespaceStandard},
+		{ttlv.Tag(0x42ffff), ttlv.TagNamespaceStandard},
+		{ttlv.Tag(0x540000), ttlv.TagNamespaceVendor},
+		{ttlv.Tag(0x54ffff), ttlv.TagNamespaceVendor},
+		{ttlv.TagNone, ttlv.TagNamespaceInvalid},
+		{ttlv.Tag(0x430000), ttlv.TagNamespaceInvalid},
+		{ttlv.Tag(0x550000), ttlv.TagNamespaceInvalid},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.tag.String(), func(t *testing.T) {
+			assert.Equal(t, tc.namespace, tc.tag.Namespace())
+			assert.Equal(t, tc.namespace == ttlv.TagNamespaceStandard, tc.tag.IsStandard())
+			assert.Equal(t, tc.namespace == ttlv.TagNamespaceVendor, tc.tag.IsVendor())
+			assert.Equal(t, tc.namespace != ttlv.TagNamespaceInvalid, tc.tag.Valid())
+		})
+	}
+
+	assert.Equal(t, "Vendor", ttlv.TagNamespaceVendor.String())
+}
+
+func TestTagSet(t *testing.T) {
+	var s ttlv.TagSet
+	assert.False(t, s.Contains(kmip14.TagComment))
+	assert.Zero(t, s.Len())
+
+	s.Add(kmip14.TagComment, ttlv.Tag(0x540001))
+	assert.True(t, s.Contains(kmip14.TagComment))
+	assert.True(t, s.Contains(ttlv.Tag(0x540001)))
+	assert.False(t, s.Contains(kmip14.TagKeyMaterial))
+	assert.Equal(t, []ttlv.Tag{kmip14.TagComment, ttlv.Tag(0x540001)}, s.Tags())
+
+	s = ttlv.NewTagSet(kmip14.TagKeyMaterial, kmip14.TagKeyMaterial)
+	assert.Equal(t, 1, s.Len())
+	assert.True(t, s.Contains(kmip14.TagKeyMaterial))
+
+	sensitive := kmip14.SensitiveTags()
+	for _, tag := range []ttlv.Tag{kmip14.TagKeyMaterial, kmip14.TagPassword, kmip14.TagPrivateExponent, kmip14.TagData} {
+		assert.True(t, sensitive.Contains(tag), tag.String())
+	}
+
+	assert.False(t, sensitive.Contains(kmip14.TagUniqueIdentifier))
+
+	// each call returns a new set
+	sensitive.Add(kmip14.TagUniqueIdentifier)
+	assert.False(t, kmip14.SensitiveTags().Contains(kmip14.TagUniqueIdentifier))
+}
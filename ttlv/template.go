@@ -0,0 +1,178 @@
+package ttlv
+
+// TemplateValue exposes a TTLV value to text/template and html/template, so reports can
+// be generated from KMIP messages without writing go code.  Tags are named in templates
+// by any name DefaultRegistry.ParseTag() accepts, e.g. "BatchItem", "Batch Item", or "0x42000f",
+// and values are formatted with the DefaultRegistry.
+//
+// The methods available to templates are:
+//
+//   - .Tag, .Type: the names of the value's tag and type
+//   - .Value: the go value, as returned by TTLV.Value(), except Enumerations, and Integers
+//     with tags registered as bitmasks, are returned as their names.  Structures return nil.
+//   - .String: the value formatted as a string, as PrintFlat() formats values, except
+//     TextStrings aren't quoted.  Structures return "".
+//   - .Children: the values in a Structure
+//   - .Find "Tag": the first value with the tag, searching the Structure depth first,
+//     or nil if there is none
+//   - .FindAll "Tag": all the values with the tag, searching depth first
+//   - .Get "Tag" "Tag"...: the value at a path of tags, each naming a member of the
+//     previous value, or nil if there is none.  The first tag names a member of this value.
+//
+// The methods returning values return *TemplateValue, so they can be chained, and absent
+// values can be tested with "with" or "if".  The methods of a nil *TemplateValue return
+// empty results, so a missing value prints as "".  Unrecognized tag names are template errors.
+// For example, to list the names of the attributes in a Get Attributes response:
+//
+//	{{range (.Get "BatchItem" "ResponsePayload").FindAll "Attribute"}}
+//	{{(.Get "AttributeName").Value}}: {{(.Get "AttributeValue").String}}
+//	{{- end}}
+//
+// Invalid values have no Children, and their Value and String are empty.
+type TemplateValue struct {
+	TTLV TTLV
+}
+
+// NewTemplateValue returns a TemplateValue for t, to pass as a template's data.
+func NewTemplateValue(t TTLV) *TemplateValue {
+	return &TemplateValue{TTLV: t}
+}
+
+// Tag returns the name of the value's tag.
+func (v *TemplateValue) Tag() string {
+	if v == nil {
+		return ""
+	}
+
+	return DefaultRegistry.FormatTag(v.TTLV.Tag())
+}
+
+// Type returns the name of the value's type.
+func (v *TemplateValue) Type() string {
+	if v == nil {
+		return ""
+	}
+
+	return DefaultRegistry.FormatType(v.TTLV.Type())
+}
+
+// Value returns the value as a go value, or the name of an enum value.
+func (v *TemplateValue) Value() interface{} {
+	if v == nil || v.TTLV.checkType(v.TTLV.Type()) != nil {
+		return nil
+	}
+
+	switch v.TTLV.Type() {
+	case TypeStructure:
+		return nil
+	case TypeEnumeration, TypeInteger:
+		return flatValue(v.TTLV)
+	default:
+		return v.TTLV.Value()
+	}
+}
+
+// String returns the value formatted as a string.
+func (v *TemplateValue) String() string {
+	if v == nil || v.TTLV.checkType(v.TTLV.Type()) != nil {
+		return ""
+	}
+
+	if v.TTLV.Type() == TypeTextString {
+		return v.TTLV.ValueTextString()
+	}
+
+	return flatValue(v.TTLV)
+}
+
+// Children returns the values in a Structure.
+func (v *TemplateValue) Children() []*TemplateValue {
+	if v == nil || v.TTLV.Type() != TypeStructure || v.TTLV.Valid() != nil {
+		return nil
+	}
+
+	var children []*TemplateValue
+	for n := v.TTLV.ValueStructure(); len(n) > 0; n = n.Next() {
+		children = append(children, NewTemplateValue(n[:n.FullLen()]))
+	}
+
+	return children
+}
+
+// Find returns the first value with the tag, searching depth first.
+func (v *TemplateValue) Find(name string) (*TemplateValue, error) {
+	all, err := v.find(name, true)
+	if err != nil || len(all) == 0 {
+		return nil, err
+	}
+
+	return all[0], nil
+}
+
+// FindAll returns all the values with the tag, searching depth first.
+func (v *TemplateValue) FindAll(name string) ([]*TemplateValue, error) {
+	return v.find(name, false)
+}
+
+func (v *TemplateValue) find(name string, first bool) ([]*TemplateValue, error) {
+	tag, err := DefaultRegistry.ParseTag(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var found []*TemplateValue
+
+	var walk func(c *TemplateValue) bool
+
+	walk = func(c *TemplateValue) bool {
+		for _, n := range c.Children() {
+			if n.TTLV.Tag() == tag {
+				found = append(found, n)
+				if first {
+					return false
+				}
+			}
+
+			if !walk(n) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	walk(v)
+
+	return found, nil
+}
+
+// Get returns the value at the path of tag names, or nil if there is none.  Like TTLV.Get(),
+// each name is a member of the previous value, starting with the children of v.  Where a
+// Structure has several members with a tag, the first is followed.  An empty path returns v.
+func (v *TemplateValue) Get(names ...string) (*TemplateValue, error) {
+	curr := v
+
+	for _, name := range names {
+		tag, err := DefaultRegistry.ParseTag(name)
+		if err != nil {
+			return nil, err
+		}
+
+		var next *TemplateValue
+
+		for _, n := range curr.Children() {
+			if n.TTLV.Tag() == tag {
+				next = n
+				break
+			}
+		}
+
+		if next == nil {
+			return nil, nil
+		}
+
+		curr = next
+	}
+
+	return curr, nil
+}
@@ -0,0 +1,84 @@
+package ttlv_test
+
+import (
+	"bytes"
+	"testing"
+	"text/template"
+
+	. "github.com/gemalto/kmip-go/kmip14"
+	. "github.com/gemalto/kmip-go/ttlv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplateValue(t *testing.T) {
+	b, err := Marshal(NewStruct(TagRequestMessage,
+		NewStruct(TagBatchItem,
+			NewValue(TagOperation, OperationGet),
+			NewStruct(TagRequestPayload,
+				NewValue(TagUniqueIdentifier, "1"),
+				NewValue(TagCryptographicUsageMask, CryptographicUsageMaskEncrypt|CryptographicUsageMaskDecrypt),
+			),
+		),
+		NewStruct(TagBatchItem,
+			NewValue(TagOperation, OperationDestroy),
+		),
+	))
+	require.NoError(t, err)
+
+	v := NewTemplateValue(b)
+	assert.Equal(t, "RequestMessage", v.Tag())
+	assert.Equal(t, "Structure", v.Type())
+	assert.Nil(t, v.Value())
+	require.Len(t, v.Children(), 2)
+
+	ops, err := v.FindAll("Operation")
+	require.NoError(t, err)
+	require.Len(t, ops, 2)
+	assert.Equal(t, "Get", ops[0].Value())
+	assert.Equal(t, "Destroy", ops[1].String())
+
+	mask, err := v.Get("BatchItem", "RequestPayload", "CryptographicUsageMask")
+	require.NoError(t, err)
+	assert.Equal(t, "Encrypt|Decrypt", mask.Value())
+
+	// tags can be named by canonical name or hex value
+	id, err := v.Find("Unique Identifier")
+	require.NoError(t, err)
+	assert.Equal(t, "1", id.Value())
+
+	id, err = v.Find("0x420094")
+	require.NoError(t, err)
+	assert.Equal(t, "1", id.String())
+
+	// missing values are nil, and nil values are empty
+	missing, err := v.Get("BatchItem", "ResponsePayload", "UniqueIdentifier")
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+	assert.Equal(t, "", missing.String())
+	assert.Nil(t, missing.Value())
+	assert.Nil(t, missing.Children())
+
+	missing, err = v.Find("ResultMessage")
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+
+	// unknown tag names are errors
+	_, err = v.Find("NotATag")
+	require.Error(t, err)
+
+	_, err = v.Get("BatchItem", "NotATag")
+	require.Error(t, err)
+
+	tmpl := template.Must(template.New("").Parse(`{{.Find "NotATag"}}`))
+	require.Error(t, tmpl.Execute(&bytes.Buffer{}, v))
+
+	// invalid values have no children or value
+	invalid := NewTemplateValue(b[:len(b)-8])
+	assert.Nil(t, invalid.Children())
+	assert.Equal(t, "RequestMessage", invalid.Tag())
+
+	id = NewTemplateValue(id.TTLV[:10])
+	assert.Nil(t, id.Value())
+	assert.Equal(t, "", id.String())
+}
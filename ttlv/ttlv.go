@@ -1,13 +1,17 @@
 package ttlv
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
 	"encoding/xml"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
+	"math"
 	"math/big"
 	"strconv"
 	"strings"
@@ -79,39 +83,99 @@ func (t TTLV) Type() Type {
 // Len only reads the header, so it does not validate if the value
 // segment's length matches the length in the header.  See Valid().
 //
-// Returns empty value if TTLV header is truncated.
+// Returns empty value if TTLV header is truncated.  On platforms where int
+// is 32 bits, lengths which overflow int are returned as negative values.  ValidHeader()
+// rejects such lengths.
 func (t TTLV) Len() int {
+	return int(t.len32())
+}
+
+func (t TTLV) len32() uint32 {
 	// don't panic if header is truncated
 	if len(t) < lenHeader {
 		return 0
 	}
 
-	return int(binary.BigEndian.Uint32(t[4:8]))
+	return binary.BigEndian.Uint32(t[4:8])
 }
 
+// MaxFullLen is the largest full length (header + value + padding) of a TTLV
+// value which will be accepted by FullLenChecked() and ValidHeader(), and so by Valid()
+// and the Decoder.  Values which declare larger lengths in their headers are rejected with
+// ErrInvalidLen.  FullLen() doesn't enforce it, so lowering it doesn't make code which
+// only computes lengths panic.
+//
+// MaxFullLen applies to every value, at any depth, in every decoder.  Decoder.MaxMessageBytes,
+// ReadValue(), and ReadMessageBuffered() take a separate limit, which only applies to the
+// top-level values read from a stream, and is checked before the value is read.  A value is
+// rejected if it exceeds either limit, so the effective limit on a message is the smaller of
+// the two, and MaxFullLen bounds the values nested inside it.
+//
+// Like DefaultRegistry, this should be configured early in your program.
+var MaxFullLen = math.MaxInt32
+
 // FullLen returns the expected length of the entire TTLV block (header + value), based
 // on the type and len encoded in the header.
 //
 // Does not check whether the actual value segment matches
-// the expected length.  See Valid().
+// the expected length, or whether the length exceeds MaxFullLen.  See Valid()
+// and FullLenChecked().
 //
 // panics if type encoded in header is invalid or unrecognized.
 func (t TTLV) FullLen() int {
+	l, err := t.fullLen()
+	if err != nil {
+		panic(fmt.Sprintf("%v: %x", err, []byte(t.header())))
+	}
+
+	return int(l)
+}
+
+// FullLenChecked is like FullLen, but returns ErrInvalidType if the type encoded in
+// the header is invalid or unrecognized, and ErrInvalidLen if the full length
+// would exceed MaxFullLen.
+func (t TTLV) FullLenChecked() (int, error) {
+	l, err := t.fullLen()
+	if err != nil {
+		return 0, err
+	}
+
+	// MaxFullLen can't be larger than the largest int, so the conversion
+	// below can't overflow.
+	if l > uint64(MaxFullLen) {
+		return 0, ErrInvalidLen
+	}
+
+	return int(l), nil
+}
+
+func (t TTLV) fullLen() (uint64, error) {
+	var l uint64
+
 	switch t.Type() {
 	case TypeInterval, TypeDateTime, TypeDateTimeExtended, TypeBoolean, TypeEnumeration, TypeLongInteger, TypeInteger:
-		return lenHeader + 8
+		l = lenHeader + 8
 	case TypeByteString, TypeTextString:
-		l := t.Len() + lenHeader
+		// computed as uint64, so this can't overflow
+		l = uint64(t.len32()) + lenHeader
 		if m := l % 8; m > 0 {
-			return l + (8 - m)
+			l += 8 - m
 		}
-
-		return l
 	case TypeBigInteger, TypeStructure:
-		return t.Len() + lenHeader
+		l = uint64(t.len32()) + lenHeader
+	default:
+		return 0, ErrInvalidType
 	}
 
-	panic(fmt.Sprintf("invalid type: %x", byte(t.Type())))
+	return l, nil
+}
+
+func (t TTLV) header() TTLV {
+	if len(t) < lenHeader {
+		return t
+	}
+
+	return t[:lenHeader]
 }
 
 // ValueRaw returns the raw bytes of the value segment of the TTLV.
@@ -121,16 +185,17 @@ func (t TTLV) FullLen() int {
 // but it will not panic.
 func (t TTLV) ValueRaw() []byte {
 	// don't panic if the value is truncated
-	l := t.Len()
+	l := t.len32()
 	if l == 0 {
 		return nil
 	}
 
-	if len(t) < lenHeader+l {
+	// compare as uint64 to avoid overflowing int on 32 bit platforms
+	if uint64(len(t)-lenHeader) < uint64(l) {
 		return t[lenHeader:]
 	}
 
-	return t[lenHeader : lenHeader+l]
+	return t[lenHeader : lenHeader+int(l)]
 }
 
 // Value returns the value of the TTLV, converted to an idiomatic
@@ -198,15 +263,21 @@ func (t TTLV) ValueTextString() string {
 	return string(t.ValueRaw())
 }
 
+// ValueByteString returns an empty, non-nil slice for an empty ByteString, so
+// the value marshals back to an empty ByteString, rather than to nothing.
 func (t TTLV) ValueByteString() []byte {
-	return t.ValueRaw()
+	if b := t.ValueRaw(); b != nil {
+		return b
+	}
+
+	return []byte{}
 }
 
 func (t TTLV) ValueDateTime() time.Time {
 	i := t.ValueLongInteger()
 
 	if t.Type() == TypeDateTimeExtended {
-		return time.Unix(0, i*1000).UTC()
+		return timeFromUnixMicro(i)
 	}
 
 	return time.Unix(i, 0).UTC()
@@ -216,12 +287,21 @@ func (t TTLV) ValueDateTimeExtended() DateTimeExtended {
 	i := t.ValueLongInteger()
 
 	if t.Type() == TypeDateTimeExtended {
-		return DateTimeExtended{Time: time.Unix(0, i*1000).UTC()}
+		return DateTimeExtended{Time: timeFromUnixMicro(i)}
 	}
 
 	return DateTimeExtended{Time: time.Unix(i, 0).UTC()}
 }
 
+// timeFromUnixMicro converts a DateTimeExtended value, which is the number of
+// microseconds since the epoch, to a time.  Unlike time.Unix(0, us*1000), it doesn't
+// overflow for times outside the range of UnixNano() (years 1678 to 2262).
+func timeFromUnixMicro(us int64) time.Time {
+	return time.Unix(us/1000000, (us%1000000)*1000).UTC()
+}
+
+// ValueInterval returns the value of an Interval.  Intervals are counts of seconds, up to
+// MaxInterval, which always fit in a time.Duration.
 func (t TTLV) ValueInterval() time.Duration {
 	return time.Duration(binary.BigEndian.Uint32(t.ValueRaw())) * time.Second
 }
@@ -233,52 +313,156 @@ func (t TTLV) ValueStructure() TTLV {
 	return t.ValueRaw()
 }
 
+// AsString returns the value of a TextString.  Unlike ValueTextString(), it checks
+// the type and length of the value first.  The As<Type>() methods return ErrInvalidType
+// if the value is a different type, and an error from ValidHeader() or ErrValueTruncated
+// if the value is not valid.
+func (t TTLV) AsString() (string, error) {
+	if err := t.checkType(TypeTextString); err != nil {
+		return "", err
+	}
+
+	return t.ValueTextString(), nil
+}
+
+// AsInt returns the value of an Integer.
+func (t TTLV) AsInt() (int32, error) {
+	if err := t.checkType(TypeInteger); err != nil {
+		return 0, err
+	}
+
+	return t.ValueInteger(), nil
+}
+
+// AsEnum returns the value of an Enumeration.
+func (t TTLV) AsEnum() (uint32, error) {
+	if err := t.checkType(TypeEnumeration); err != nil {
+		return 0, err
+	}
+
+	return uint32(t.ValueEnumeration()), nil
+}
+
+// AsBytes returns the value of a ByteString.
+func (t TTLV) AsBytes() ([]byte, error) {
+	if err := t.checkType(TypeByteString); err != nil {
+		return nil, err
+	}
+
+	return t.ValueByteString(), nil
+}
+
+// AsTime returns the value of a DateTime or DateTimeExtended.
+func (t TTLV) AsTime() (time.Time, error) {
+	if err := t.checkType(TypeDateTime, TypeDateTimeExtended); err != nil {
+		return time.Time{}, err
+	}
+
+	return t.ValueDateTime(), nil
+}
+
+func (t TTLV) checkType(types ...Type) error {
+	if err := t.ValidHeader(); err != nil {
+		return err
+	}
+
+	if len(t) < t.FullLen() {
+		return ErrValueTruncated
+	}
+
+	for _, typ := range types {
+		if t.Type() == typ {
+			return nil
+		}
+	}
+
+	return merry.Appendf(ErrInvalidType, "expected %v, got %v", types[0], t.Type())
+}
+
+// IsZeroValue returns true if the value is the zero value of its type:
+//
+//   - Integer, LongInteger, BigInteger, Enumeration, and Interval: 0
+//   - Boolean: false
+//   - DateTime and DateTimeExtended: the epoch, 1970-01-01T00:00:00Z
+//   - TextString and ByteString: empty
+//   - Structure: no members
+//
+// Note that the zero DateTime is the epoch, not golang's zero time.Time, which
+// doesn't encode as 0.  Returns false if the header is invalid or the value is truncated.
+//
+// A TTLV struct field with the "omitempty" flag is omitted when marshaling if its value
+// is zero by this definition.  Fields of other types use golang's definition of zero
+// instead, so a time.Time field with "omitempty" is omitted if it's the zero time.Time,
+// but not if it's the epoch, while a TTLV field holding the epoch is omitted.
+func (t TTLV) IsZeroValue() bool {
+	if t.ValidHeader() != nil || len(t) < t.FullLen() {
+		return false
+	}
+
+	switch t.Type() {
+	case TypeTextString, TypeByteString, TypeStructure:
+		return t.Len() == 0
+	default:
+	}
+
+	// the remaining types are all zero when every byte of their value is zero
+	for _, b := range t.ValueRaw() {
+		if b != 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
 // Valid checks whether a TTLV value is valid.  It checks whether the value segment
 // is long enough to hold the encoded type.  If the type is Structure, it recursively
 // checks all the enclosed TTLV values.
 //
 // Returns nil if valid.
 func (t TTLV) Valid() error {
+	_, err := t.firstInvalid()
+
+	return err
+}
+
+// firstInvalid validates t like Valid(), and also returns the offset, relative to the
+// start of t, of the value which failed validation.
+func (t TTLV) firstInvalid() (int, error) {
 	if err := t.ValidHeader(); err != nil {
-		return err
+		return 0, err
 	}
 
 	if len(t) < t.FullLen() {
-		return ErrValueTruncated
+		return 0, ErrValueTruncated
 	}
 
 	if t.Type() == TypeStructure {
-		inner := t.ValueStructure()
+		offset := lenHeader
 
-		for {
-			if len(inner) == 0 {
-				break
-			}
-
-			if err := inner.Valid(); err != nil {
-				return merry.Prepend(err, t.Tag().String())
+		for inner := t.ValueStructure(); len(inner) > 0; {
+			if o, err := inner.firstInvalid(); err != nil {
+				return offset + o, merry.Prepend(err, t.Tag().String())
 			}
 
-			inner = inner.Next()
+			l := inner.FullLen()
+			offset += l
+			inner = inner[l:]
 		}
 	}
 
-	return nil
+	return 0, nil
 }
 
 func (t TTLV) validTag() bool {
-	switch t[0] {
-	case 0x42, 0x54: // valid
-		return true
-	}
-
-	return false
+	return t.Tag().Valid()
 }
 
 // ValidHeader checks whether the header is valid.  It ensures the
 // value is long enough to hold a full header, whether the tag
 // value is within valid ranges, whether the type is recognized,
-// and whether the encoded length is valid for the encoded type.
+// and whether the encoded length is valid for the encoded type,
+// and doesn't exceed MaxFullLen.
 //
 // Returns nil if valid.
 func (t TTLV) ValidHeader() error {
@@ -309,6 +493,36 @@ func (t TTLV) ValidHeader() error {
 		return ErrInvalidType
 	}
 
+	if _, err := t.FullLenChecked(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Expect checks that the value has the expected tag and type, e.g. to check that a message
+// is a ResponseMessage before decoding it:
+//
+//	if err := msg.Expect(TagResponseMessage, TypeStructure); err != nil {
+//		return err
+//	}
+//
+// It returns ErrHeaderTruncated if the header is truncated, ErrInvalidTag if the tag
+// doesn't match, or ErrInvalidType if the type doesn't match.  The error message includes
+// the tag and type which were found.  Only the header is checked.  See Valid().
+func (t TTLV) Expect(tag Tag, typ Type) error {
+	if len(t) < lenHeader {
+		return merry.Appendf(ErrHeaderTruncated, "expected %s (%s), found %d bytes", tag.String(), typ.String(), len(t))
+	}
+
+	if t.Tag() != tag {
+		return merry.Appendf(ErrInvalidTag, "expected %s (%s), found %s (%s)", tag.String(), typ.String(), t.Tag().String(), t.Type().String())
+	}
+
+	if t.Type() != typ {
+		return merry.Appendf(ErrInvalidType, "expected %s to be %s, found %s", tag.String(), typ.String(), t.Type().String())
+	}
+
 	return nil
 }
 
@@ -325,6 +539,105 @@ func (t TTLV) Next() TTLV {
 	return n
 }
 
+// Get returns the value at the path of tags, or nil if there is none.  Like Query() and
+// TemplateValue.Get(), each tag names a member of the previous value, which must be a
+// Structure, starting with the members of t.  For example, the Result Status of a response's
+// first batch item is:
+//
+//	msg.Get(TagBatchItem, TagResultStatus).ValueEnumeration()
+//
+// Where a Structure has several members with a tag, the first is followed.  The result is
+// trimmed to its FullLen(), so its Value() methods can be called directly.  Only the values
+// Get passes over are checked, so a truncated or malformed value ends the search, rather than
+// panicking, but the result may be a Structure containing invalid values.  An empty path
+// returns t itself.
+func (t TTLV) Get(path ...Tag) TTLV {
+	found := t.get(path, false)
+	if len(found) == 0 {
+		return nil
+	}
+
+	return found[0]
+}
+
+// GetAll is like Get(), but returns all the members of the last Structure on the path with
+// the last tag, in order, e.g. all the Attributes in a request payload's Template-Attribute:
+//
+//	payload.GetAll(TagTemplateAttribute, TagAttribute)
+func (t TTLV) GetAll(path ...Tag) []TTLV {
+	return t.get(path, true)
+}
+
+func (t TTLV) get(path []Tag, all bool) []TTLV {
+	if !t.complete() {
+		return nil
+	}
+
+	found := []TTLV{t[:t.FullLen()]}
+
+	for i, tag := range path {
+		curr, last := found[0], i == len(path)-1
+		if curr.Type() != TypeStructure {
+			return nil
+		}
+
+		found = nil
+
+		for n := curr.ValueStructure(); len(n) > 0; {
+			if !n.complete() {
+				break
+			}
+
+			l := n.FullLen()
+			if n.Tag() == tag {
+				found = append(found, n[:l])
+				if !all || !last {
+					break
+				}
+			}
+
+			n = n[l:]
+		}
+
+		if len(found) == 0 {
+			return nil
+		}
+	}
+
+	return found
+}
+
+// complete returns true if t has a valid header, and is long enough to hold its value.
+// Unlike Valid(), the values in a Structure aren't checked.
+func (t TTLV) complete() bool {
+	return t.ValidHeader() == nil && len(t) >= t.FullLen()
+}
+
+// FindNextTTLV scans b forward from offset for the start of something which looks like
+// a complete TTLV value: a tag starting with 0x42 or 0x54, a recognized type, a length
+// valid for that type, and enough remaining bytes to hold the full value.  If the value is
+// a Structure, its contents must also be valid (see Valid()).  It returns the index of the
+// first such position, or false if none is found.
+//
+// This is intended for resynchronizing after a corrupt or non-KMIP region in a stream, which
+// Next() and Print() can't do.  It is only a heuristic: arbitrary bytes, including bytes
+// inside another TTLV value (like a ByteString), may happen to look like a valid TTLV, so
+// a match is only a likely message boundary.  Values nested inside a Structure are valid
+// TTLVs too, so after decoding a match, resume scanning past its FullLen().
+func FindNextTTLV(b []byte, offset int) (start int, ok bool) {
+	if offset < 0 {
+		offset = 0
+	}
+
+	for i := offset; i <= len(b)-lenHeader; i++ {
+		if TTLV(b[i:]).Valid() == nil {
+			return i, true
+		}
+	}
+
+	return 0, false
+}
+
 // String renders the TTLV in a human-friendly format using Print().
 func (t TTLV) String() string {
 	var sb strings.Builder
@@ -333,6 +646,131 @@ func (t TTLV) String() string {
 	return sb.String()
 }
 
+// Stats summarizes the size and shape of a TTLV value.  See TTLV.Stats().
+type Stats struct {
+	// TotalBytes is the full length of the value, including headers and padding.
+	TotalBytes int
+	// Nodes is the number of values, including the value itself and all
+	// the values nested in it.
+	Nodes int
+	// MaxDepth is the deepest level of nesting.  A value which isn't
+	// a Structure, or an empty Structure, has a depth of 1.
+	MaxDepth int
+	// BytesByType is the number of bytes used by values of each type, including
+	// headers and padding.  Structures only count their headers, since the values
+	// nested in them are counted under their own types, so the counts add up to
+	// TotalBytes.
+	BytesByType map[Type]int
+}
+
+// Canonical returns a copy of the TTLV value re-encoded in a canonical form, so
+// that encodings of the same value which differ only in insignificant bytes become
+// identical:
+//
+// - pad bytes are zeroed
+// - BigIntegers are re-encoded with the fewest sign-extension bytes
+// - Booleans are re-encoded as 0 or 1
+//
+// Only the first value in t is copied.  Returns the error from Valid() if t
+// is not valid.
+func (t TTLV) Canonical() (TTLV, error) {
+	if err := t.Valid(); err != nil {
+		return nil, err
+	}
+
+	var buf encBuf
+
+	t.canonical(&buf)
+
+	return buf.Bytes(), nil
+}
+
+func (t TTLV) canonical(buf *encBuf) {
+	switch t.Type() {
+	case TypeStructure:
+		i := buf.begin(t.Tag(), TypeStructure)
+		for n := t.ValueStructure(); len(n) > 0; n = n.Next() {
+			n.canonical(buf)
+		}
+		buf.end(i)
+	case TypeBigInteger:
+		buf.encodeBigInt(t.Tag(), t.ValueBigInteger())
+	case TypeBoolean:
+		buf.encodeBool(t.Tag(), t.ValueBoolean())
+	default:
+		i := buf.begin(t.Tag(), t.Type())
+		_, _ = buf.Write(t.ValueRaw())
+		buf.end(i)
+	}
+}
+
+// Fingerprint writes the canonical form of the TTLV value, as returned by Canonical(),
+// into h.  Exactly those bytes are written: the full encoding of the first value in t,
+// including headers and zeroed padding, and nothing else.  Values which differ only in
+// pad bytes, BigInteger sign extension, or Boolean encoding produce the same digest.
+//
+// t must be valid TTLV: if it isn't, the error from Valid() is returned and nothing is
+// written to h.
+func (t TTLV) Fingerprint(h hash.Hash) error {
+	c, err := t.Canonical()
+	if err != nil {
+		return err
+	}
+
+	_, _ = h.Write(c)
+
+	return nil
+}
+
+// Stats walks the TTLV value and all the values nested in it, and returns
+// a summary of its size.  This is useful for understanding which values
+// dominate the size of a message.
+//
+// Stats stops walking when it encounters an invalid or truncated value, so
+// Nodes, MaxDepth, and BytesByType only count the valid values before that point.
+// Use Valid() to check whether the value is valid.
+func (t TTLV) Stats() Stats {
+	stats := Stats{
+		BytesByType: map[Type]int{},
+	}
+
+	if t.ValidHeader() != nil || len(t) < t.FullLen() {
+		return stats
+	}
+
+	stats.TotalBytes = t.FullLen()
+	t.stats(&stats, 1)
+
+	return stats
+}
+
+// stats assumes t has a valid header, and is not truncated.
+func (t TTLV) stats(stats *Stats, depth int) {
+	l := t.FullLen()
+
+	stats.Nodes++
+
+	if depth > stats.MaxDepth {
+		stats.MaxDepth = depth
+	}
+
+	if t.Type() != TypeStructure {
+		stats.BytesByType[t.Type()] += l
+		return
+	}
+
+	stats.BytesByType[TypeStructure] += lenHeader
+
+	for inner := t.ValueStructure(); len(inner) > 0; {
+		if inner.ValidHeader() != nil || len(inner) < inner.FullLen() {
+			return
+		}
+
+		inner.stats(stats, depth+1)
+		inner = inner[inner.FullLen():]
+	}
+}
+
 func (t TTLV) MarshalXML(e *xml.Encoder, _ xml.StartElement) error {
 	if len(t) == 0 {
 		return nil
@@ -361,6 +799,10 @@ func (t TTLV) MarshalXML(e *xml.Encoder, _ xml.StartElement) error {
 	switch t.Type() {
 	case TypeStructure:
 		se := xml.StartElement{Name: out.XMLName}
+		if out.Tag != "" {
+			se.Attr = append(se.Attr, xml.Attr{Name: xml.Name{Local: "tag"}, Value: out.Tag})
+		}
+
 		if out.Type != "" {
 			se.Attr = append(se.Attr, xml.Attr{Name: xml.Name{Local: "type"}, Value: out.Type})
 		}
@@ -619,6 +1061,44 @@ func (t *TTLV) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 	return nil
 }
 
+// UnmarshalXMLFragment parses a sequence of sibling KMIP XML elements, which
+// need not be enclosed in a single root element, e.g. a list of Attributes.
+// Each top level element is parsed the same way as UnmarshalXML, and returned
+// as a separate TTLV, in document order.  Whitespace, comments, and processing
+// instructions between the elements are ignored, but any other text is an error.
+func UnmarshalXMLFragment(b []byte) ([]TTLV, error) {
+	d := xml.NewDecoder(bytes.NewReader(b))
+
+	var ttlvs []TTLV
+
+	for {
+		tok, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			return ttlvs, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			var t TTLV
+
+			err := t.UnmarshalXML(d, tok)
+			if err != nil {
+				return nil, merry.Prependf(err, "element %d", len(ttlvs))
+			}
+
+			ttlvs = append(ttlvs, t)
+		case xml.CharData:
+			if len(bytes.TrimSpace(tok)) > 0 {
+				return nil, merry.Errorf("unexpected text between elements: %q", string(tok))
+			}
+		}
+	}
+}
+
 var (
 	maxJSONInt    = int64(1) << 52
 	maxJSONBigInt = big.NewInt(maxJSONInt)
@@ -759,7 +1239,7 @@ func (t *TTLV) unmarshalJSON(b []byte, attrTag Tag) error {
 				if tp == TypeDateTime {
 					tm = time.Unix(int64(u), 0)
 				} else {
-					tm = tm.Add(time.Duration(u) * time.Microsecond)
+					tm = timeFromUnixMicro(int64(u))
 				}
 			} else {
 				var err error
@@ -792,6 +1272,10 @@ func (t *TTLV) unmarshalJSON(b []byte, attrTag Tag) error {
 
 			enc.encodeInt(tag, i)
 		case float64:
+			if tv != math.Trunc(tv) || tv < math.MinInt32 || tv > math.MaxInt32 {
+				return syntaxError(errors.New("must be an integer between -2147483648 and 2147483647"))
+			}
+
 			enc.encodeInt(tag, int32(tv))
 		}
 	case TypeLongInteger:
@@ -810,7 +1294,14 @@ func (t *TTLV) unmarshalJSON(b []byte, attrTag Tag) error {
 
 			enc.encodeLongInt(tag, int64(kmiputil.DecodeUint64(b)))
 		case float64:
-			enc.encodeLongInt(tag, int64(tv))
+			// parse the number from the original text, rather than converting the float,
+			// which can't represent all the values near the ends of the int64 range exactly
+			i, err := strconv.ParseInt(string(ttl.Value), 10, 64)
+			if err != nil {
+				return syntaxError(errors.New("must be an integer between -9223372036854775808 and 9223372036854775807"))
+			}
+
+			enc.encodeLongInt(tag, i)
 		}
 	case TypeBigInteger:
 		switch tv := v.(type) {
@@ -901,6 +1392,47 @@ func (t TTLV) MarshalJSON() ([]byte, error) {
 
 	var sb strings.Builder
 
+	if err := t.encodeJSON(&sb); err != nil {
+		return nil, err
+	}
+
+	return []byte(sb.String()), nil
+}
+
+// EncodeJSON writes the JSON encoding of t to w, as it walks t, rather than building
+// the whole encoding in memory first, so large values can be transcoded with little
+// memory beyond t itself.  The output is identical to MarshalJSON().  As with MarshalJSON(),
+// an invalid value returns the error from Valid(), and nothing is written.
+func EncodeJSON(w io.Writer, t TTLV) error {
+	bw := bufio.NewWriter(w)
+
+	if len(t) == 0 {
+		_, _ = bw.WriteString("null")
+		return bw.Flush()
+	}
+
+	if err := t.Valid(); err != nil {
+		return err
+	}
+
+	if err := t.encodeJSON(bw); err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
+
+// jsonWriter is implemented by strings.Builder and bufio.Writer, which both
+// defer write errors: strings.Builder never fails, and bufio.Writer returns
+// the error from Flush().
+type jsonWriter interface {
+	io.Writer
+	io.StringWriter
+}
+
+// encodeJSON writes the JSON encoding of t, which must be valid, to sb.  Byte strings
+// are hex encoded in chunks, rather than copied to a hex string.
+func (t TTLV) encodeJSON(sb jsonWriter) error {
 	sb.WriteString(`{"tag":"`)
 	sb.WriteString(t.Tag().String())
 
@@ -934,7 +1466,7 @@ func (t TTLV) MarshalJSON() ([]byte, error) {
 		v := t.ValueLongInteger()
 		if v <= -maxJSONInt || v >= maxJSONInt {
 			sb.WriteString(`"0x`)
-			sb.WriteString(hex.EncodeToString(t.ValueRaw()))
+			_, _ = hex.NewEncoder(sb).Write(t.ValueRaw())
 			sb.WriteString(`"`)
 		} else {
 			sb.WriteString(strconv.FormatInt(v, 10))
@@ -944,25 +1476,25 @@ func (t TTLV) MarshalJSON() ([]byte, error) {
 		if v.IsInt64() && v.CmpAbs(maxJSONBigInt) < 0 {
 			val, err := v.MarshalJSON()
 			if err != nil {
-				return nil, err
+				return err
 			}
 
 			sb.Write(val)
 		} else {
 			sb.WriteString(`"0x`)
-			sb.WriteString(hex.EncodeToString(t.ValueRaw()))
+			_, _ = hex.NewEncoder(sb).Write(t.ValueRaw())
 			sb.WriteString(`"`)
 		}
 	case TypeTextString:
 		val, err := json.Marshal(t.ValueTextString())
 		if err != nil {
-			return nil, err
+			return err
 		}
 
 		sb.Write(val)
 	case TypeByteString:
 		sb.WriteString(`"`)
-		sb.WriteString(hex.EncodeToString(t.ValueRaw()))
+		_, _ = hex.NewEncoder(sb).Write(t.ValueRaw())
 		sb.WriteString(`"`)
 	case TypeStructure:
 		sb.WriteString("[")
@@ -997,12 +1529,9 @@ func (t TTLV) MarshalJSON() ([]byte, error) {
 
 				sb.WriteString(`}`)
 			default:
-				v, err := c.MarshalJSON()
-				if err != nil {
-					return nil, err
+				if err := c.encodeJSON(sb); err != nil {
+					return err
 				}
-
-				sb.Write(v)
 			}
 
 			c = c.Next()
@@ -1014,7 +1543,7 @@ func (t TTLV) MarshalJSON() ([]byte, error) {
 	case TypeDateTime, TypeDateTimeExtended:
 		val, err := t.ValueDateTime().MarshalJSON()
 		if err != nil {
-			return nil, err
+			return err
 		}
 
 		sb.Write(val)
@@ -1024,12 +1553,30 @@ func (t TTLV) MarshalJSON() ([]byte, error) {
 
 	sb.WriteString(`}`)
 
-	return []byte(sb.String()), nil
+	return nil
+}
+
+// MarshalJSONIndent is like MarshalJSON, but formats the output for human consumption:
+// each element begins on a new line beginning with prefix, followed by one or more copies
+// of indent according to the nesting depth, as with json.MarshalIndent().
+func (t TTLV) MarshalJSONIndent(prefix, indent string) ([]byte, error) {
+	b, err := t.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, b, prefix, indent); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
 }
 
 // UnmarshalTTLV implements ttlv.Unmarshaler.  Unmarshaling a TTLV
 // into another TTLV will allocate a new slice, and copy the bytes
-// from the source TTLV into the new slice.
+// from the source TTLV into the new slice.  Only the first value
+// is copied, not any values which follow it in the source.
 func (t *TTLV) UnmarshalTTLV(_ *Decoder, ttlv TTLV) error {
 	if ttlv == nil {
 		*t = nil
@@ -1037,6 +1584,10 @@ func (t *TTLV) UnmarshalTTLV(_ *Decoder, ttlv TTLV) error {
 		return nil
 	}
 
+	if l, err := ttlv.FullLenChecked(); err == nil && l < len(ttlv) {
+		ttlv = ttlv[:l]
+	}
+
 	if l := len(ttlv); len(*t) < l {
 		*t = make([]byte, l)
 	} else {
@@ -1048,6 +1599,56 @@ func (t *TTLV) UnmarshalTTLV(_ *Decoder, ttlv TTLV) error {
 	return nil
 }
 
+// RawTTLV holds an encoded value, and its tag, without interpreting it.  Like json.RawMessage,
+// it's used as the type of a struct field to defer decoding part of a message, like a batch
+// item's payload, until enough of the rest has been decoded to choose the type to decode it
+// into.  See Unmarshal.
+//
+// When marshaled, the encoded value is validated, then copied to the output unchanged.  Its tag
+// must match Tag, if set, and the tag of the struct field holding it, unless the field has the
+// "any" flag.
+type RawTTLV struct {
+	// Tag is the tag of the value.  This is useful if the field can hold values with different
+	// tags, e.g. if it has the "any" flag.
+	Tag  Tag
+	TTLV TTLV
+}
+
+// UnmarshalTTLV implements Unmarshaler.  Like TTLV.UnmarshalTTLV, it copies the value.
+func (r *RawTTLV) UnmarshalTTLV(d *Decoder, ttlv TTLV) error {
+	r.Tag = ttlv.Tag()
+
+	return r.TTLV.UnmarshalTTLV(d, ttlv)
+}
+
+// MarshalTTLV implements Marshaler.  It returns an error if the value is invalid, or if its
+// tag doesn't match r.Tag or the tag argument, when those aren't TagNone.
+func (r RawTTLV) MarshalTTLV(e *Encoder, tag Tag) error {
+	if len(r.TTLV) == 0 {
+		return nil
+	}
+
+	if err := r.TTLV.Valid(); err != nil {
+		return e.marshalingError(tag, rawTTLVType, err)
+	}
+
+	for _, want := range []Tag{r.Tag, tag} {
+		if want != TagNone && r.TTLV.Tag() != want {
+			return e.marshalingError(tag, rawTTLVType, ErrTagConflict).Appendf("expected tag %s, but TTLV value's tag is %s", want, r.TTLV.Tag())
+		}
+	}
+
+	_, err := e.encBuf.Write(r.TTLV)
+
+	return err
+}
+
+// Decode decodes the value into v.  See Unmarshal for decoding rules.  If the RawTTLV is empty,
+// v is not modified.
+func (r RawTTLV) Decode(v interface{}) error {
+	return NewDecoder(nil).DecodeValue(v, r.TTLV)
+}
+
 // Print pretty prints the TTLV value in a human-readable format.  This
 // format cannot be parsed back into TTLV.
 //
@@ -1131,6 +1732,106 @@ func Print(w io.Writer, prefix, indent string, t TTLV) error {
 	return nil
 }
 
+// PrintFlat prints the TTLV value with one line per value, in the format:
+//
+//	TagPath<tab>Type<tab>Value
+//
+// TagPath is the dotted path of tag names from the root value, e.g.
+// RequestMessage.BatchItem.Operation.  If a tag is repeated within a Structure,
+// each occurrence is suffixed with its index among the values with that tag,
+// e.g. BatchItem[0] and BatchItem[1].  Structures are printed with an empty Value,
+// followed by the values they contain.  Values are formatted like Print(), except
+// TextStrings are quoted, and DateTimes are formatted as RFC3339 in UTC, so the
+// output is deterministic, and each value fits on a single line.  This makes the
+// output easy to grep, and to compare with standard diff tools.
+//
+// Like Print, PrintFlat tolerates invalid values: it prints as much as it can
+// decode, prints a line with the error for the first invalid value, and returns
+// the error.
+func PrintFlat(w io.Writer, t TTLV) error {
+	return printFlat(w, "", t)
+}
+
+func printFlat(w io.Writer, path string, t TTLV) error {
+	if verr := t.Valid(); verr != nil {
+		if errors.Is(verr, ErrHeaderTruncated) {
+			_, err := fmt.Fprintf(w, "%s\t\t(%s) %#x\n", path, verr.Error(), []byte(t))
+			if err != nil {
+				return err
+			}
+
+			return verr
+		}
+
+		if path == "" {
+			path = t.Tag().String()
+		}
+
+		if _, err := fmt.Fprintf(w, "%s\t%s\t(%s) %#x\n", path, t.Type().String(), verr.Error(), t.ValueRaw()); err != nil {
+			return err
+		}
+
+		return verr
+	}
+
+	if path == "" {
+		path = t.Tag().String()
+	}
+
+	if _, err := fmt.Fprintf(w, "%s\t%s\t%s\n", path, t.Type().String(), flatValue(t)); err != nil {
+		return err
+	}
+
+	if t.Type() != TypeStructure {
+		return nil
+	}
+
+	// count the occurrences of each tag, to decide which need an index
+	counts := map[Tag]int{}
+	for n := t.ValueStructure(); len(n) > 0; n = n.Next() {
+		counts[n.Tag()]++
+	}
+
+	indexes := map[Tag]int{}
+
+	for n := t.ValueStructure(); len(n) > 0; n = n.Next() {
+		childPath := path + "." + n.Tag().String()
+		if counts[n.Tag()] > 1 {
+			childPath += "[" + strconv.Itoa(indexes[n.Tag()]) + "]"
+			indexes[n.Tag()]++
+		}
+
+		if err := printFlat(w, childPath, n); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func flatValue(t TTLV) string {
+	switch t.Type() {
+	case TypeStructure:
+		return ""
+	case TypeByteString:
+		return fmt.Sprintf("%#x", t.ValueByteString())
+	case TypeTextString:
+		return strconv.Quote(t.ValueTextString())
+	case TypeEnumeration:
+		return DefaultRegistry.FormatEnum(t.Tag(), uint32(t.ValueEnumeration()))
+	case TypeInteger:
+		if enum := DefaultRegistry.EnumForTag(t.Tag()); enum != nil {
+			return FormatInt(t.ValueInteger(), enum)
+		}
+
+		return strconv.Itoa(int(t.ValueInteger()))
+	case TypeDateTime, TypeDateTimeExtended:
+		return t.ValueDateTime().UTC().Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprintf("%v", t.Value())
+	}
+}
+
 // PrintPrettyHex pretty prints the TTLV value as hex values, with spacers between
 // the segments of the TTLV.  Like Print, this is safe to call even on invalid TTLV
 // values.  An error will only be returned if there is a problem with the writer.
@@ -1187,6 +1888,10 @@ func PrintPrettyHex(w io.Writer, prefix, indent string, t TTLV) error {
 
 var one = big.NewInt(1)
 
+// unpadBigInt strips the redundant sign extension bytes from the front of a BigInteger's
+// value.  It makes a single pass over the pad bytes, and unmarshalBigInt() makes a constant number of
+// linear passes over the rest, so decoding a BigInteger is linear in its length, however it
+// is padded.
 func unpadBigInt(data []byte) []byte {
 	if len(data) < 2 {
 		return data
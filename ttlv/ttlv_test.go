@@ -2,13 +2,17 @@ package ttlv_test
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
 	"encoding/xml"
+	"errors"
+	"flag"
 	"fmt"
 	"math"
 	"math/big"
+	"math/rand"
 	"strconv"
 	"testing"
 	"time"
@@ -76,6 +80,58 @@ func TestPrint(t *testing.T) {
 	assert.Equal(t, `ProtocolVersionMinor (Integer/4): (value truncated) 0x00000000`, buf.String())
 }
 
+func TestPrintFlat(t *testing.T) {
+	b := Hex2bytes(sample)
+	buf := &bytes.Buffer{}
+	err := PrintFlat(buf, b)
+	require.NoError(t, err)
+	assert.Equal(t, `RequestMessage	Structure	
+RequestMessage.RequestHeader	Structure	
+RequestMessage.RequestHeader.ProtocolVersion	Structure	
+RequestMessage.RequestHeader.ProtocolVersion.ProtocolVersionMajor	Integer	1
+RequestMessage.RequestHeader.ProtocolVersion.ProtocolVersionMinor	Integer	0
+RequestMessage.RequestHeader.BatchOrderOption	Boolean	true
+RequestMessage.RequestHeader.BatchCount	Integer	2
+RequestMessage.BatchItem[0]	Structure	
+RequestMessage.BatchItem[0].Operation	Enumeration	Locate
+RequestMessage.BatchItem[0].UniqueBatchItemID	ByteString	0x36
+RequestMessage.BatchItem[0].RequestPayload	Structure	
+RequestMessage.BatchItem[0].RequestPayload.Attribute	Structure	
+RequestMessage.BatchItem[0].RequestPayload.Attribute.AttributeName	TextString	"Name"
+RequestMessage.BatchItem[0].RequestPayload.Attribute.AttributeValue	Structure	
+RequestMessage.BatchItem[0].RequestPayload.Attribute.AttributeValue.NameValue	TextString	"pubkey"
+RequestMessage.BatchItem[0].RequestPayload.Attribute.AttributeValue.NameType	Enumeration	UninterpretedTextString
+RequestMessage.BatchItem[1]	Structure	
+RequestMessage.BatchItem[1].Operation	Enumeration	ModifyAttribute
+RequestMessage.BatchItem[1].UniqueBatchItemID	ByteString	0x37
+RequestMessage.BatchItem[1].RequestPayload	Structure	
+RequestMessage.BatchItem[1].RequestPayload.Attribute	Structure	
+RequestMessage.BatchItem[1].RequestPayload.Attribute.AttributeName	TextString	"x-myattr"
+RequestMessage.BatchItem[1].RequestPayload.Attribute.AttributeValue	TextString	"test2"
+`, buf.String())
+
+	v, err := Marshal(Value{Tag: TagKeyBlock, Value: Values{
+		{Tag: TagCryptographicUsageMask, Value: CryptographicUsageMaskEncrypt | CryptographicUsageMaskDecrypt},
+		{Tag: TagActivationDate, Value: time.Date(2021, 1, 2, 3, 4, 5, 0, time.FixedZone("EST", -5*60*60))},
+		{Tag: TagComment, Value: "tab\there"},
+	}})
+	require.NoError(t, err)
+
+	buf.Reset()
+	require.NoError(t, PrintFlat(buf, v))
+	assert.Equal(t, "KeyBlock\tStructure\t\n"+
+		"KeyBlock.CryptographicUsageMask\tInteger\tEncrypt|Decrypt\n"+
+		"KeyBlock.ActivationDate\tDateTime\t2021-01-02T08:04:05Z\n"+
+		"KeyBlock.Comment\tTextString\t\"tab\\there\"\n", buf.String())
+
+	// Should tolerate invalid value with valid header
+	b = Hex2bytes("42006b0200000004000000000000")
+	buf.Reset()
+	err = PrintFlat(buf, b)
+	assert.Error(t, err)
+	assert.Equal(t, "ProtocolVersionMinor\tInteger\t(value truncated) 0x00000000\n", buf.String())
+}
+
 func TestPrintPrettyHex(t *testing.T) {
 	b := Hex2bytes(sample)
 	buf := &bytes.Buffer{}
@@ -242,6 +298,557 @@ func TestTTLV(t *testing.T) {
 	}
 }
 
+func TestTTLV_As(t *testing.T) {
+	dt := time.Date(2008, 3, 14, 11, 56, 40, 0, time.UTC)
+
+	values := map[Type]TTLV{}
+
+	for _, v := range []Value{
+		{Tag: TagComment, Value: "red"},
+		{Tag: TagBatchCount, Value: int32(5)},
+		{Tag: TagObjectType, Value: ObjectTypeSymmetricKey},
+		{Tag: TagNonceValue, Value: []byte{0x01, 0x02}},
+		{Tag: TagActivationDate, Value: dt},
+		{Tag: TagActivationDate, Value: DateTimeExtended{Time: dt}},
+		{Tag: TagFresh, Value: true},
+		{Tag: TagBatchItem, Value: Values{}},
+	} {
+		b, err := Marshal(v)
+		require.NoError(t, err)
+
+		values[TTLV(b).Type()] = b
+	}
+
+	getters := map[Type]func(TTLV) (interface{}, error){
+		TypeTextString: func(t TTLV) (interface{}, error) {
+			return t.AsString()
+		},
+		TypeInteger: func(t TTLV) (interface{}, error) {
+			return t.AsInt()
+		},
+		TypeEnumeration: func(t TTLV) (interface{}, error) {
+			return t.AsEnum()
+		},
+		TypeByteString: func(t TTLV) (interface{}, error) {
+			return t.AsBytes()
+		},
+		TypeDateTime: func(t TTLV) (interface{}, error) {
+			return t.AsTime()
+		},
+	}
+
+	expected := map[Type]interface{}{
+		TypeTextString:  "red",
+		TypeInteger:     int32(5),
+		TypeEnumeration: uint32(ObjectTypeSymmetricKey),
+		TypeByteString:  []byte{0x01, 0x02},
+		TypeDateTime:    dt,
+	}
+
+	for getterType, getter := range getters {
+		for valueType, value := range values {
+			t.Run(fmt.Sprintf("%v_%v", getterType, valueType), func(t *testing.T) {
+				v, err := getter(value)
+
+				switch {
+				case getterType == valueType, getterType == TypeDateTime && valueType == TypeDateTimeExtended:
+					require.NoError(t, err)
+					assert.Equal(t, expected[getterType], v)
+				default:
+					require.Error(t, err)
+					assert.True(t, errors.Is(err, ErrInvalidType), Details(err))
+				}
+			})
+		}
+
+		t.Run(fmt.Sprintf("%v_invalid", getterType), func(t *testing.T) {
+			value := values[getterType]
+
+			_, err := getter(value[:len(value)-1])
+			assert.True(t, errors.Is(err, ErrValueTruncated), Details(err))
+
+			_, err = getter(value[:4])
+			assert.True(t, errors.Is(err, ErrHeaderTruncated), Details(err))
+
+			_, err = getter(nil)
+			assert.True(t, errors.Is(err, ErrHeaderTruncated), Details(err))
+		})
+	}
+}
+
+func TestTTLV_Expect(t *testing.T) {
+	b, err := Marshal(NewStruct(TagResponseMessage, NewValue(TagComment, "red")))
+	require.NoError(t, err)
+
+	require.NoError(t, b.Expect(TagResponseMessage, TypeStructure))
+
+	err = b.Expect(TagRequestMessage, TypeStructure)
+	require.True(t, errors.Is(err, ErrInvalidTag))
+	require.EqualError(t, err, "invalid tag: expected RequestMessage (Structure), found ResponseMessage (Structure)")
+
+	err = b.Expect(TagResponseMessage, TypeTextString)
+	require.True(t, errors.Is(err, ErrInvalidType))
+	require.EqualError(t, err, "invalid KMIP type: expected ResponseMessage to be TextString, found Structure")
+
+	err = b[:5].Expect(TagResponseMessage, TypeStructure)
+	require.True(t, errors.Is(err, ErrHeaderTruncated))
+	require.EqualError(t, err, "header truncated: expected ResponseMessage (Structure), found 5 bytes")
+}
+
+func TestTTLV_Get(t *testing.T) {
+	b, err := Marshal(Value{Tag: TagResponseMessage, Value: Values{
+		Value{Tag: TagResponseHeader, Value: Values{
+			Value{Tag: TagBatchCount, Value: 2},
+		}},
+		Value{Tag: TagBatchItem, Value: Values{
+			Value{Tag: TagOperation, Value: OperationGetAttributes},
+			Value{Tag: TagResultStatus, Value: ResultStatusSuccess},
+			Value{Tag: TagResponsePayload, Value: Values{
+				Value{Tag: TagUniqueIdentifier, Value: "1"},
+				Value{Tag: TagAttribute, Value: Values{
+					Value{Tag: TagAttributeName, Value: "Name"},
+				}},
+				Value{Tag: TagAttribute, Value: Values{
+					Value{Tag: TagAttributeName, Value: "Object Type"},
+				}},
+			}},
+		}},
+		Value{Tag: TagBatchItem, Value: Values{
+			Value{Tag: TagResultStatus, Value: ResultStatusOperationFailed},
+		}},
+	}})
+	require.NoError(t, err)
+
+	status := b.Get(TagBatchItem, TagResultStatus)
+	require.NoError(t, status.Valid())
+	assert.Equal(t, EnumValue(ResultStatusSuccess), status.ValueEnumeration())
+	assert.Len(t, status, status.FullLen())
+
+	assert.Equal(t, "1", b.Get(TagBatchItem, TagResponsePayload, TagUniqueIdentifier).Value())
+
+	// the path starts with the members of b, like TemplateValue.Get
+	assert.Nil(t, b.Get(TagResponseMessage))
+	assert.Nil(t, b.Get(TagResponseMessage, TagBatchItem))
+
+	tv, err := NewTemplateValue(b).Get("BatchItem", "ResponsePayload", "UniqueIdentifier")
+	require.NoError(t, err)
+	assert.Equal(t, b.Get(TagBatchItem, TagResponsePayload, TagUniqueIdentifier), tv.TTLV)
+
+	// an empty path returns b itself
+	assert.Equal(t, TTLV(b), b.Get())
+	assert.Equal(t, []TTLV{b}, b.GetAll())
+
+	// repeated tags
+	attrs := b.GetAll(TagBatchItem, TagResponsePayload, TagAttribute)
+	require.Len(t, attrs, 2)
+	assert.Equal(t, "Name", attrs[0].Get(TagAttributeName).Value())
+	assert.Equal(t, "Object Type", attrs[1].Get(TagAttributeName).Value())
+
+	items := b.GetAll(TagBatchItem)
+	require.Len(t, items, 2)
+	assert.Equal(t, EnumValue(ResultStatusOperationFailed), items[1].Get(TagResultStatus).Value())
+
+	// missing paths
+	assert.Nil(t, b.Get(TagRequestHeader))
+	assert.Nil(t, b.Get(TagBatchItem, TagResponsePayload, TagName))
+	assert.Nil(t, b.GetAll(TagBatchItem, TagResponsePayload, TagName))
+	assert.Nil(t, TTLV(nil).Get(TagBatchItem))
+	assert.Nil(t, TTLV(nil).Get())
+
+	// a non-structure in the middle of the path
+	assert.Nil(t, b.Get(TagBatchItem, TagOperation, TagResultStatus))
+	assert.Nil(t, b.GetAll(TagBatchItem, TagOperation, TagResultStatus))
+
+	// truncated buffers
+	for i := 0; i < len(b); i++ {
+		truncated := b[:i]
+
+		require.NotPanics(t, func() {
+			truncated.Get(TagBatchItem, TagResponsePayload, TagAttribute, TagAttributeName)
+			truncated.GetAll(TagBatchItem, TagResponsePayload, TagAttribute)
+		})
+	}
+
+	assert.Nil(t, b[:len(b)-8].Get(TagResponseHeader, TagBatchCount))
+
+	// a structure whose contents are truncated: only the header of its first member fits
+	withTruncatedChild := append(TTLV(nil), b[:8]...)
+	binary.BigEndian.PutUint32(withTruncatedChild[4:8], 8)
+	withTruncatedChild = append(withTruncatedChild, b.ValueStructure()[:8]...)
+	require.NoError(t, withTruncatedChild.ValidHeader())
+	require.Error(t, withTruncatedChild.Valid())
+
+	assert.Nil(t, withTruncatedChild.Get(TagResponseHeader))
+}
+
+func TestTTLV_IsZeroValue(t *testing.T) {
+	tests := []struct {
+		v    interface{}
+		zero bool
+	}{
+		{v: int32(0), zero: true},
+		{v: int32(1)},
+		{v: int64(0), zero: true},
+		{v: int64(-1)},
+		{v: big.NewInt(0), zero: true},
+		{v: big.NewInt(1)},
+		{v: EnumValue(0), zero: true},
+		{v: EnumValue(1)},
+		{v: false, zero: true},
+		{v: true},
+		{v: time.Unix(0, 0), zero: true},
+		{v: time.Unix(1, 0)},
+		{v: DateTimeExtended{Time: time.Unix(0, 0)}, zero: true},
+		{v: DateTimeExtended{Time: time.Unix(0, 1000)}},
+		{v: time.Duration(0), zero: true},
+		{v: time.Second},
+		{v: "", zero: true},
+		{v: "red"},
+		{v: []byte{}, zero: true},
+		{v: []byte{0}},
+		{v: Values{}, zero: true},
+		{v: Values{NewValue(TagComment, "")}},
+	}
+
+	for _, tc := range tests {
+		b, err := Marshal(Value{Tag: TagComment, Value: tc.v})
+		require.NoError(t, err)
+		assert.Equal(t, tc.zero, b.IsZeroValue(), "%T %v: %s", tc.v, tc.v, b)
+	}
+
+	// invalid values are never zero
+	b, err := Marshal(Value{Tag: TagComment, Value: int32(0)})
+	require.NoError(t, err)
+	assert.False(t, b[:8].IsZeroValue())
+	assert.False(t, TTLV(nil).IsZeroValue())
+}
+
+func TestTTLV_FullLenChecked(t *testing.T) {
+	tests := []struct {
+		name   string
+		bs     string
+		expLen int
+		expErr error
+	}{
+		{
+			name:   "integer",
+			bs:     "42 00 20 | 02 | 00 00 00 04",
+			expLen: 16,
+		},
+		{
+			name:   "padded",
+			bs:     "42 00 20 | 07 | 00 00 00 0B",
+			expLen: 24,
+		},
+		{
+			name:   "maxint32",
+			bs:     "42 00 20 | 01 | 7F FF FF F7",
+			expLen: math.MaxInt32,
+		},
+		{
+			name:   "structureexceedsmax",
+			bs:     "42 00 20 | 01 | 7F FF FF F8",
+			expErr: ErrInvalidLen,
+		},
+		{
+			name:   "bytestringexceedsmax",
+			bs:     "42 00 20 | 08 | 7F FF FF F1",
+			expErr: ErrInvalidLen,
+		},
+		{
+			name:   "maxuint32structure",
+			bs:     "42 00 20 | 01 | FF FF FF FF",
+			expErr: ErrInvalidLen,
+		},
+		{
+			name:   "maxuint32bytestring",
+			bs:     "42 00 20 | 08 | FF FF FF FF",
+			expErr: ErrInvalidLen,
+		},
+		{
+			name:   "maxuint32biginteger",
+			bs:     "42 00 20 | 04 | FF FF FF F8",
+			expErr: ErrInvalidLen,
+		},
+		{
+			name:   "invalidtype",
+			bs:     "42 00 20 | 0F | 00 00 00 04",
+			expErr: ErrInvalidType,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tt := TTLV(Hex2bytes(tc.bs))
+
+			l, err := tt.FullLenChecked()
+			if tc.expErr != nil {
+				require.True(t, errors.Is(err, tc.expErr), Details(err))
+				assert.True(t, errors.Is(tt.ValidHeader(), tc.expErr), Details(tt.ValidHeader()))
+				if tc.expErr == ErrInvalidType {
+					assert.Panics(t, func() { tt.FullLen() })
+				} else {
+					// FullLen only panics on invalid types
+					assert.NotPanics(t, func() { tt.FullLen() })
+				}
+
+				// doesn't panic on truncated values
+				assert.Empty(t, tt.ValueRaw())
+				assert.Error(t, tt.Valid())
+				assert.Nil(t, tt.Next())
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expLen, l)
+			assert.Equal(t, tc.expLen, tt.FullLen())
+		})
+	}
+}
+
+func TestTTLV_FullLenChecked_maxFullLen(t *testing.T) {
+	defer func(prev int) { MaxFullLen = prev }(MaxFullLen)
+
+	MaxFullLen = 64
+
+	tt := TTLV(Hex2bytes("42 00 20 | 08 | 00 00 00 38"))
+	l, err := tt.FullLenChecked()
+	require.NoError(t, err)
+	assert.Equal(t, 64, l)
+
+	tt = TTLV(Hex2bytes("42 00 20 | 08 | 00 00 00 39"))
+	_, err = tt.FullLenChecked()
+	require.True(t, errors.Is(err, ErrInvalidLen), Details(err))
+	require.True(t, errors.Is(tt.ValidHeader(), ErrInvalidLen), Details(tt.ValidHeader()))
+
+	// FullLen doesn't enforce the limit
+	assert.Equal(t, 65+7, tt.FullLen())
+
+	// the decoder rejects the header before allocating a buffer for the value
+	_, err = NewDecoder(bytes.NewReader(tt)).NextTTLV()
+	require.True(t, errors.Is(err, ErrInvalidLen), Details(err))
+}
+
+func TestTTLV_DateTime_edges(t *testing.T) {
+	tests := []struct {
+		name string
+		tm   time.Time
+		typ  Type
+		exp  string
+	}{
+		{
+			name: "epoch",
+			tm:   time.Unix(0, 0).UTC(),
+			typ:  TypeDateTime,
+			exp:  "42 00 87 | 09 | 00 00 00 08 | 00 00 00 00 00 00 00 00",
+		},
+		{
+			name: "beforeepoch",
+			tm:   time.Unix(-1, 0).UTC(),
+			typ:  TypeDateTime,
+			exp:  "42 00 87 | 09 | 00 00 00 08 | FF FF FF FF FF FF FF FF",
+		},
+		{
+			name: "1900",
+			tm:   time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC),
+			typ:  TypeDateTime,
+			exp:  "42 00 87 | 09 | 00 00 00 08 | FF FF FF FF 7C 55 81 80",
+		},
+		{
+			name: "9999",
+			tm:   time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC),
+			typ:  TypeDateTime,
+			exp:  "42 00 87 | 09 | 00 00 00 08 | 00 00 00 3A FF F4 41 7F",
+		},
+		{
+			name: "extendedbeforeepoch",
+			tm:   time.Unix(-1, 500000000).UTC(),
+			typ:  TypeDateTimeExtended,
+			exp:  "42 00 87 | 0B | 00 00 00 08 | FF FF FF FF FF F8 5E E0",
+		},
+		{
+			name: "extended1600",
+			tm:   time.Date(1600, 1, 1, 0, 0, 0, 1000, time.UTC),
+			typ:  TypeDateTimeExtended,
+			exp:  "42 00 87 | 0B | 00 00 00 08 | FF D6 84 A7 0D 8E 80 01",
+		},
+		{
+			name: "extended9999",
+			tm:   time.Date(9999, 12, 31, 23, 59, 59, 999999000, time.UTC),
+			typ:  TypeDateTimeExtended,
+			exp:  "42 00 87 | 0B | 00 00 00 08 | 03 84 44 0C CC 73 5F FF",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var v interface{} = tc.tm
+			if tc.typ == TypeDateTimeExtended {
+				v = DateTimeExtended{Time: tc.tm}
+			}
+
+			b, err := Marshal(Value{Tag: TagSerialNumber, Value: v})
+			require.NoError(t, err)
+
+			exp := TTLV(Hex2bytes(tc.exp))
+			require.Equal(t, exp, TTLV(b))
+
+			tt := TTLV(b)
+			assert.Equal(t, tc.tm, tt.ValueDateTime())
+
+			tm, err := tt.AsTime()
+			require.NoError(t, err)
+			assert.Equal(t, tc.tm, tm)
+
+			var decoded time.Time
+
+			require.NoError(t, Unmarshal(tt, &decoded))
+			assert.Equal(t, tc.tm, decoded)
+
+			// JSON and XML round trips
+			j, err := json.Marshal(tt)
+			require.NoError(t, err)
+
+			var fromJSON TTLV
+
+			require.NoError(t, json.Unmarshal(j, &fromJSON))
+			assert.Equal(t, tt, fromJSON, string(j))
+
+			x, err := xml.Marshal(tt)
+			require.NoError(t, err)
+
+			var fromXML TTLV
+
+			require.NoError(t, xml.Unmarshal(x, &fromXML))
+			assert.Equal(t, tt, fromXML, string(x))
+
+			// hex JSON encoding
+			hexJSON := fmt.Sprintf(`{"tag":"SerialNumber","type":%q,"value":"0x%x"}`, tc.typ.String(), []byte(tt.ValueRaw()))
+
+			require.NoError(t, json.Unmarshal([]byte(hexJSON), &fromJSON))
+			assert.Equal(t, tt, fromJSON, hexJSON)
+		})
+	}
+}
+
+func TestTTLV_Stats(t *testing.T) {
+	b := Hex2bytes(sample)
+
+	stats := TTLV(b).Stats()
+	assert.Equal(t, Stats{
+		TotalBytes: 288,
+		Nodes:      23,
+		MaxDepth:   6,
+		BytesByType: map[Type]int{
+			TypeStructure:   80,
+			TypeInteger:     48,
+			TypeBoolean:     16,
+			TypeEnumeration: 48,
+			TypeByteString:  32,
+			TypeTextString:  64,
+		},
+	}, stats)
+
+	// the byte counts add up to the total
+	var sum int
+	for _, n := range stats.BytesByType {
+		sum += n
+	}
+
+	assert.Equal(t, stats.TotalBytes, sum)
+
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, NewEncoder(buf).EncodeValue(TagComment, "red"))
+	assert.Equal(t, Stats{
+		TotalBytes:  16,
+		Nodes:       1,
+		MaxDepth:    1,
+		BytesByType: map[Type]int{TypeTextString: 16},
+	}, TTLV(buf.Bytes()).Stats())
+
+	// invalid values
+	assert.Equal(t, Stats{BytesByType: map[Type]int{}}, TTLV(nil).Stats())
+	assert.Equal(t, Stats{BytesByType: map[Type]int{}}, TTLV(b[:100]).Stats())
+}
+
+func TestFindNextTTLV(t *testing.T) {
+	msg := Hex2bytes(sample)
+
+	// a message surrounded by junk, followed by a second message
+	junk := []byte{0x00, 0x42, 0x00, 0xff, 0x54, 0x12}
+	b := append(append(append([]byte{}, junk...), msg...), junk...)
+	b = append(b, msg...)
+
+	start, ok := FindNextTTLV(b, 0)
+	require.True(t, ok)
+	assert.Equal(t, len(junk), start)
+	assert.Equal(t, msg, []byte(TTLV(b[start:])[:TTLV(b[start:]).FullLen()]))
+
+	start, ok = FindNextTTLV(b, start+len(msg))
+	require.True(t, ok)
+	assert.Equal(t, 2*len(junk)+len(msg), start)
+
+	// a truncated message is not matched, though values nested inside it may be
+	next, ok := FindNextTTLV(b[:len(b)-1], start)
+	require.True(t, ok)
+	assert.Greater(t, next, start)
+
+	_, ok = FindNextTTLV(junk, 0)
+	assert.False(t, ok)
+
+	_, ok = FindNextTTLV(nil, 0)
+	assert.False(t, ok)
+
+	start, ok = FindNextTTLV(msg, -1)
+	require.True(t, ok)
+	assert.Equal(t, 0, start)
+}
+
+func TestTTLV_Canonical(t *testing.T) {
+	canonical := Hex2bytes(`
+420078 | 01 | 00000030
+	420004 | 04 | 00000008 | FFFFFFFFFFFFFFFF
+	42000A | 07 | 00000003 | 7265640000000000
+	420008 | 06 | 00000008 | 0000000000000001
+`)
+	// same value, with non-zero padding, and a sign extended BigInteger
+	padded := Hex2bytes(`
+420078 | 01 | 00000038
+	420004 | 04 | 00000010 | FFFFFFFFFFFFFFFF FFFFFFFFFFFFFFFF
+	42000A | 07 | 00000003 | 726564FFFFFFFFFF
+	420008 | 06 | 00000008 | 0000000000000001
+`)
+
+	c, err := TTLV(padded).Canonical()
+	require.NoError(t, err)
+	assert.Equal(t, TTLV(canonical), c)
+
+	c, err = TTLV(canonical).Canonical()
+	require.NoError(t, err)
+	assert.Equal(t, TTLV(canonical), c)
+
+	h1, h2 := sha256.New(), sha256.New()
+	require.NoError(t, TTLV(canonical).Fingerprint(h1))
+	require.NoError(t, TTLV(padded).Fingerprint(h2))
+	assert.Equal(t, h1.Sum(nil), h2.Sum(nil))
+
+	// the hash is fed exactly the canonical bytes
+	expected := sha256.Sum256(canonical)
+	assert.Equal(t, expected[:], h1.Sum(nil))
+
+	// a different value has a different fingerprint
+	h2.Reset()
+	require.NoError(t, TTLV(Hex2bytes(sample)).Fingerprint(h2))
+	assert.NotEqual(t, h1.Sum(nil), h2.Sum(nil))
+
+	// invalid values are rejected
+	h2.Reset()
+	err = TTLV(canonical[:len(canonical)-1]).Fingerprint(h2)
+	require.True(t, errors.Is(err, ErrValueTruncated), Details(err))
+	assert.Equal(t, sha256.New().Sum(nil), h2.Sum(nil))
+}
+
 func TestTTLV_UnmarshalTTLV(t *testing.T) {
 	var ttlv TTLV
 
@@ -389,6 +996,31 @@ func TestTTLV_UnmarshalJSON_errors(t *testing.T) {
 			input: `{"tag":"BatchCount","type":"Integer","value":"0xA0A0A0A0A0"}`,
 			msg:   "BatchCount: invalid Integer: invalid hex string: must be 4 bytes",
 		},
+		{
+			name:  "integeroverflow",
+			input: `{"tag":"BatchCount","type":"Integer","value":2147483648}`,
+			msg:   "BatchCount: invalid Integer: must be an integer between -2147483648 and 2147483647",
+		},
+		{
+			name:  "integerunderflow",
+			input: `{"tag":"BatchCount","type":"Integer","value":-2147483649}`,
+			msg:   "BatchCount: invalid Integer: must be an integer between -2147483648 and 2147483647",
+		},
+		{
+			name:  "integerfraction",
+			input: `{"tag":"BatchCount","type":"Integer","value":1.5}`,
+			msg:   "BatchCount: invalid Integer: must be an integer between -2147483648 and 2147483647",
+		},
+		{
+			name:  "longintegerunderflow",
+			input: `{"tag":"BatchCount","type":"LongInteger","value":-9223372036854775809}`,
+			msg:   "BatchCount: invalid LongInteger: must be an integer between -9223372036854775808 and 9223372036854775807",
+		},
+		{
+			name:  "longintegerfraction",
+			input: `{"tag":"BatchCount","type":"LongInteger","value":1.5}`,
+			msg:   "BatchCount: invalid LongInteger: must be an integer between -9223372036854775808 and 9223372036854775807",
+		},
 		{
 			name:  "longintegerinvalidtype",
 			input: `{"tag":"BatchCount","type":"LongInteger","value":true}`,
@@ -785,10 +1417,103 @@ func TestTTLV_MarshalJSON(t *testing.T) {
 			j, err := json.Marshal(b)
 			require.NoError(t, err)
 			require.JSONEq(t, testcase.exp, string(j))
+
+			var buf bytes.Buffer
+			require.NoError(t, EncodeJSON(&buf, b))
+			require.Equal(t, string(j), buf.String())
 		})
 	}
 }
 
+func TestEncodeJSON(t *testing.T) {
+	bigInt, ok := new(big.Int).SetString("-123456789012345678901234567890", 10)
+	require.True(t, ok)
+
+	b, err := Marshal(Value{Tag: TagRequestMessage, Value: Values{
+		Value{Tag: TagAttribute, Value: Values{
+			Value{Tag: TagAttributeName, Value: "Cryptographic Algorithm"},
+			Value{Tag: TagAttributeValue, Value: CryptographicAlgorithmAES},
+		}},
+		Value{Tag: TagAttribute, Value: Values{
+			Value{Tag: TagAttributeName, Value: "Cryptographic Usage Mask"},
+			Value{Tag: TagAttributeValue, Value: CryptographicUsageMaskEncrypt | CryptographicUsageMaskDecrypt},
+		}},
+		Value{Tag: TagNonceValue, Value: bytes.Repeat([]byte{0xde, 0xad, 0xbe, 0xef}, 10000)},
+		Value{Tag: TagP, Value: bigInt},
+		Value{Tag: TagQ, Value: big.NewInt(-5)},
+		Value{Tag: TagLeaseTime, Value: 10 * time.Second},
+		Value{Tag: TagIterationCount, Value: int64(math.MaxInt64)},
+		Value{Tag: TagCertificateValue, Value: time.Date(2008, time.March, 14, 11, 56, 40, 0, time.UTC)},
+		Value{Tag: TagBatchItem, Value: Values{
+			Value{Tag: TagOperation, Value: OperationGet},
+			Value{Tag: TagUniqueIdentifier, Value: "<\"&\">"},
+			Value{Tag: TagBatchOrderOption, Value: true},
+		}},
+	}})
+	require.NoError(t, err)
+
+	exp, err := b.MarshalJSON()
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, EncodeJSON(&buf, b))
+	require.Equal(t, string(exp), buf.String())
+
+	// empty values
+	buf.Reset()
+	require.NoError(t, EncodeJSON(&buf, nil))
+	require.Equal(t, "null", buf.String())
+
+	// invalid values
+	buf.Reset()
+	err = EncodeJSON(&buf, b[:len(b)-1])
+	require.True(t, errors.Is(err, ErrHeaderTruncated) || errors.Is(err, ErrValueTruncated), Details(err))
+	require.Empty(t, buf.String())
+}
+
+func TestTTLV_MarshalJSONIndent(t *testing.T) {
+	b, err := Marshal(NewStruct(TagBatchItem,
+		NewValue(TagOperation, OperationGet),
+		NewStruct(TagRequestPayload,
+			NewValue(TagUniqueIdentifier, "1"),
+		),
+	))
+	require.NoError(t, err)
+
+	exp := `{
+  "tag": "BatchItem",
+  "value": [
+    {
+      "tag": "Operation",
+      "type": "Enumeration",
+      "value": "Get"
+    },
+    {
+      "tag": "RequestPayload",
+      "value": [
+        {
+          "tag": "UniqueIdentifier",
+          "type": "TextString",
+          "value": "1"
+        }
+      ]
+    }
+  ]
+}`
+
+	out, err := b.MarshalJSONIndent("", "  ")
+	require.NoError(t, err)
+	assert.Equal(t, exp, string(out))
+
+	// indenting doesn't change the content
+	compact, err := b.MarshalJSON()
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, json.Compact(&buf, out))
+	assert.Equal(t, string(compact), buf.String())
+}
+
 func TestTTLV_MarshalXML(t *testing.T) {
 	tests := []struct {
 		name string
@@ -865,6 +1590,11 @@ func TestTTLV_MarshalXML(t *testing.T) {
 			in:   Value{Tag: TagBatchCount, Value: 10 * time.Second},
 			exp:  `<BatchCount type="Interval" value="10"></BatchCount>`,
 		},
+		{
+			name: "unregisteredstructure",
+			in:   Value{Tag: Tag(0x540002), Value: Values{Value{Tag: TagBatchCount, Value: 10}}},
+			exp:  `<TTLV tag="0x540002"><BatchCount type="Integer" value="10"></BatchCount></TTLV>`,
+		},
 		{
 			name: "structure",
 			in: Value{Tag: TagKeyFormatType, Value: Values{
@@ -1170,3 +1900,309 @@ func TestTTLV_UnmarshalXML_errors(t *testing.T) {
 		})
 	}
 }
+
+func TestUnmarshalXMLFragment(t *testing.T) {
+	input := `<?xml version="1.0"?>
+<!-- hand authored attributes -->
+<Attribute>
+  <AttributeName type="TextString" value="Cryptographic Algorithm"/>
+  <AttributeValue type="Enumeration" value="AES"/>
+</Attribute>
+<Attribute>
+  <AttributeName type="TextString" value="Cryptographic Length"/>
+  <AttributeValue type="Integer" value="128"/>
+</Attribute>
+<ObjectType type="Enumeration" value="SymmetricKey"/>
+`
+
+	ttlvs, err := UnmarshalXMLFragment([]byte(input))
+	require.NoError(t, err)
+
+	expected := []Value{
+		{Tag: TagAttribute, Value: Values{
+			{Tag: TagAttributeName, Value: "Cryptographic Algorithm"},
+			{Tag: TagAttributeValue, Value: CryptographicAlgorithmAES},
+		}},
+		{Tag: TagAttribute, Value: Values{
+			{Tag: TagAttributeName, Value: "Cryptographic Length"},
+			{Tag: TagAttributeValue, Value: 128},
+		}},
+		{Tag: TagObjectType, Value: ObjectTypeSymmetricKey},
+	}
+
+	require.Len(t, ttlvs, len(expected))
+
+	for i, v := range expected {
+		b, err := Marshal(v)
+		require.NoError(t, err)
+		assert.Equal(t, TTLV(b), ttlvs[i], "element %d", i)
+	}
+
+	t.Run("empty", func(t *testing.T) {
+		ttlvs, err := UnmarshalXMLFragment([]byte(" \n"))
+		require.NoError(t, err)
+		assert.Empty(t, ttlvs)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := UnmarshalXMLFragment([]byte(`<BatchCount type="Integer" value="1"/>junk`))
+		require.EqualError(t, err, `unexpected text between elements: "junk"`)
+
+		_, err = UnmarshalXMLFragment([]byte(`<BatchCount type="Integer" value="1"/><Elephant type="Boolean" value="true"/>`))
+		require.EqualError(t, err, "element 1: invalid tag: unregistered enum name: Elephant")
+
+		_, err = UnmarshalXMLFragment([]byte(`<Attribute><AttributeName type="TextString" value="Name"/>`))
+		require.Error(t, err)
+	})
+}
+
+var roundTripSeed = flag.Int64("ttlv.seed", 0, "seed for TestTTLV_roundTrip_random.  Defaults to a time-based seed.")
+
+// randomValueGenerator generates random, valid TTLV trees, for property testing
+// the codecs.
+type randomValueGenerator struct {
+	rnd      *rand.Rand
+	maxDepth int
+}
+
+// tags used by the generator: registered tags, enum and bitmask tags, whose values are
+// formatted by name in JSON and XML, and unregistered tags.
+var randomValueTags = []Tag{
+	TagComment,
+	TagKeyMaterial,
+	TagObjectType,
+	TagCryptographicUsageMask,
+	TagBatchItem,
+	Tag(0x540001),
+	Tag(0x42ffff),
+}
+
+var randomValueRunes = []rune("abcXYZ019 -_.é世🔑")
+
+func (g *randomValueGenerator) value(depth int) Value {
+	tag := randomValueTags[g.rnd.Intn(len(randomValueTags))]
+
+	typ := Type(g.rnd.Intn(int(TypeInterval)) + 1)
+	if depth >= g.maxDepth && typ == TypeStructure {
+		typ = TypeTextString
+	}
+
+	switch typ {
+	case TypeStructure:
+		vals := Values{}
+		for i := g.rnd.Intn(5); i > 0; i-- {
+			vals = append(vals, g.value(depth+1))
+		}
+
+		return Value{Tag: tag, Value: vals}
+	case TypeInteger:
+		return Value{Tag: tag, Value: int32(g.rnd.Uint32())}
+	case TypeLongInteger:
+		return Value{Tag: tag, Value: int64(g.rnd.Uint64())}
+	case TypeBigInteger:
+		return Value{Tag: tag, Value: g.bigInt()}
+	case TypeEnumeration:
+		return Value{Tag: tag, Value: EnumValue(g.rnd.Uint32())}
+	case TypeBoolean:
+		return Value{Tag: tag, Value: g.rnd.Intn(2) == 1}
+	case TypeTextString:
+		r := make([]rune, g.rnd.Intn(20))
+		for i := range r {
+			r[i] = randomValueRunes[g.rnd.Intn(len(randomValueRunes))]
+		}
+
+		return Value{Tag: tag, Value: string(r)}
+	case TypeByteString:
+		b := make([]byte, g.rnd.Intn(20))
+		_, _ = g.rnd.Read(b)
+
+		return Value{Tag: tag, Value: b}
+	case TypeDateTime:
+		return Value{Tag: tag, Value: time.Unix(g.rnd.Int63n(1<<34)-1<<33, 0)}
+	case TypeInterval:
+		return Value{Tag: tag, Value: time.Duration(g.rnd.Uint32()) * time.Second}
+	default:
+		return Value{Tag: tag, Value: DateTimeExtended{Time: time.UnixMicro(g.rnd.Int63n(1<<50) - 1<<49)}}
+	}
+}
+
+// bigInt returns big integers clustered around the boundaries where the two's complement
+// encoding changes length or sign.
+func (g *randomValueGenerator) bigInt() *big.Int {
+	i := big.NewInt(1)
+	i.Lsh(i, uint(g.rnd.Intn(16)*8+7+g.rnd.Intn(2)))
+	i.Add(i, big.NewInt(int64(g.rnd.Intn(3)-1)))
+
+	if g.rnd.Intn(2) == 1 {
+		i.Neg(i)
+	}
+
+	if g.rnd.Intn(4) == 0 {
+		i.SetInt64(int64(g.rnd.Intn(3) - 1))
+	}
+
+	return i
+}
+
+func TestTTLV_negativeIntegers(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      Value
+		rawHex  string
+		expJSON string
+		expXML  string
+	}{
+		{
+			name:    "intminusone",
+			in:      Value{Tag: TagBatchCount, Value: int32(-1)},
+			rawHex:  "42000d0200000004ffffffff00000000",
+			expJSON: `{"tag":"BatchCount","type":"Integer","value":-1}`,
+			expXML:  `<BatchCount type="Integer" value="-1"></BatchCount>`,
+		},
+		{
+			name:    "intmin",
+			in:      Value{Tag: TagBatchCount, Value: int32(math.MinInt32)},
+			rawHex:  "42000d02000000048000000000000000",
+			expJSON: `{"tag":"BatchCount","type":"Integer","value":-2147483648}`,
+			expXML:  `<BatchCount type="Integer" value="-2147483648"></BatchCount>`,
+		},
+		{
+			name:    "longminusone",
+			in:      Value{Tag: TagBatchCount, Value: int64(-1)},
+			rawHex:  "42000d0300000008ffffffffffffffff",
+			expJSON: `{"tag":"BatchCount","type":"LongInteger","value":-1}`,
+			expXML:  `<BatchCount type="LongInteger" value="-1"></BatchCount>`,
+		},
+		{
+			// largest magnitude negative which is still encoded as a JSON number
+			name:    "longminjsonnumber",
+			in:      Value{Tag: TagBatchCount, Value: -(int64(1) << 52) + 1},
+			rawHex:  "42000d0300000008fff0000000000001",
+			expJSON: `{"tag":"BatchCount","type":"LongInteger","value":-4503599627370495}`,
+			expXML:  `<BatchCount type="LongInteger" value="-4503599627370495"></BatchCount>`,
+		},
+		{
+			name:    "longmin",
+			in:      Value{Tag: TagBatchCount, Value: int64(math.MinInt64)},
+			rawHex:  "42000d03000000088000000000000000",
+			expJSON: `{"tag":"BatchCount","type":"LongInteger","value":"0x8000000000000000"}`,
+			expXML:  `<BatchCount type="LongInteger" value="-9223372036854775808"></BatchCount>`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := Marshal(tc.in)
+			require.NoError(t, err)
+
+			// two's complement, big endian, padded to 8 bytes
+			assert.Equal(t, tc.rawHex, hex.EncodeToString(b))
+
+			j, err := json.Marshal(b)
+			require.NoError(t, err)
+			assert.JSONEq(t, tc.expJSON, string(j))
+
+			var fromJSON TTLV
+			require.NoError(t, json.Unmarshal(j, &fromJSON))
+			assert.Equal(t, b, fromJSON)
+
+			x, err := xml.Marshal(b)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expXML, string(x))
+
+			var fromXML TTLV
+			require.NoError(t, xml.Unmarshal(x, &fromXML))
+			assert.Equal(t, b, fromXML)
+		})
+	}
+
+	t.Run("longnumbernearmin", func(t *testing.T) {
+		// not representable as a float64, so must not be converted through one
+		var fromJSON TTLV
+		require.NoError(t, json.Unmarshal([]byte(`{"tag":"BatchCount","type":"LongInteger","value":-9223372036854775807}`), &fromJSON))
+		assert.Equal(t, int64(math.MinInt64+1), fromJSON.ValueLongInteger())
+	})
+}
+
+func TestTTLV_roundTrip_random(t *testing.T) {
+	seed := *roundTripSeed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	t.Cleanup(func() {
+		if t.Failed() {
+			t.Logf("reproduce with: go test -run TestTTLV_roundTrip_random -ttlv.seed=%d", seed)
+		}
+	})
+
+	g := randomValueGenerator{rnd: rand.New(rand.NewSource(seed)), maxDepth: 4} //nolint:gosec
+
+	for i := 0; i < 500; i++ {
+		v := g.value(0)
+
+		b, err := Marshal(v)
+		require.NoError(t, err)
+		require.NoError(t, b.Valid())
+
+		j, err := json.Marshal(b)
+		require.NoError(t, err)
+
+		var fromJSON TTLV
+		require.NoError(t, json.Unmarshal(j, &fromJSON), string(j))
+		require.Equal(t, b, fromJSON, "json: %s", j)
+
+		x, err := xml.Marshal(b)
+		require.NoError(t, err)
+
+		var fromXML TTLV
+		require.NoError(t, xml.Unmarshal(x, &fromXML), string(x))
+		require.Equal(t, b, fromXML, "xml: %s", x)
+
+		var fromBinary Value
+		require.NoError(t, Unmarshal(b, &fromBinary))
+
+		b2, err := Marshal(fromBinary)
+		require.NoError(t, err)
+		require.Equal(t, b, b2, "value: %v", b)
+	}
+}
+
+func TestRawTTLV(t *testing.T) {
+	type item struct {
+		Operation      Operation
+		RequestPayload RawTTLV
+		Comment        RawTTLV `ttlv:",any"`
+	}
+
+	b, err := Marshal(NewStruct(TagBatchItem,
+		NewValue(TagOperation, OperationGet),
+		NewStruct(TagRequestPayload,
+			NewValue(TagUniqueIdentifier, "1"),
+		),
+		NewValue(TagUniqueBatchItemID, []byte{1}),
+	))
+	require.NoError(t, err)
+
+	var v item
+	require.NoError(t, Unmarshal(b, &v))
+	assert.Equal(t, TagRequestPayload, v.RequestPayload.Tag)
+	assert.Equal(t, TagUniqueBatchItemID, v.Comment.Tag)
+
+	var payload struct {
+		UniqueIdentifier string
+	}
+
+	require.NoError(t, v.RequestPayload.Decode(&payload))
+	assert.Equal(t, "1", payload.UniqueIdentifier)
+
+	// the raw values are marshaled unchanged, with their original tags
+	out, err := Marshal(Value{Tag: TagBatchItem, Value: v})
+	require.NoError(t, err)
+	assert.Equal(t, b, out)
+
+	// decoding an empty value is a no-op
+	var empty RawTTLV
+	require.NoError(t, empty.Decode(&payload))
+	assert.Equal(t, "1", payload.UniqueIdentifier)
+}
@@ -0,0 +1,27 @@
+// Package ttlvtest provides helpers for tests which compare TTLV values.  It is kept
+// separate from the ttlv package so programs which import ttlv don't link the testing
+// package.
+package ttlvtest
+
+import (
+	"testing"
+
+	"github.com/gemalto/kmip-go/ttlv"
+)
+
+// AssertTTLVEqual fails the test if expected and actual are not Equal(), logging
+// the ttlv.Diff() of the two values.  It returns true if they are equal.  Since values
+// are compared in canonical form, differences in pad bytes and other insignificant
+// details of the encoding don't fail the test.
+func AssertTTLVEqual(t testing.TB, expected, actual ttlv.TTLV) bool {
+	t.Helper()
+
+	diff := ttlv.Diff(expected, actual)
+	if diff == "" {
+		return true
+	}
+
+	t.Errorf("TTLV values are not equal (-expected +actual):\n%s", diff)
+
+	return false
+}
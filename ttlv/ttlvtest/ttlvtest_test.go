@@ -0,0 +1,61 @@
+package ttlvtest_test
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/gemalto/kmip-go/kmip14"
+	. "github.com/gemalto/kmip-go/ttlv"
+	. "github.com/gemalto/kmip-go/ttlv/ttlvtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingTB captures the failures reported by AssertTTLVEqual, rather than
+// failing the enclosing test.
+type recordingTB struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recordingTB) Helper() {}
+
+func (r *recordingTB) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestAssertTTLVEqual(t *testing.T) {
+	msg := func(comments ...string) TTLV {
+		item := NewStruct(TagBatchItem, NewValue(TagOperation, OperationGet))
+		for _, c := range comments {
+			item.Value = append(item.Value.(Values), NewValue(TagComment, c))
+		}
+
+		b, err := Marshal(NewStruct(TagRequestMessage, item))
+		require.NoError(t, err)
+
+		return b
+	}
+
+	expected := msg("red", "green")
+
+	// differing only in pad bytes
+	padded := append(TTLV{}, expected...)
+	padded[len(padded)-1] = 0xff
+
+	assert.True(t, AssertTTLVEqual(t, expected, padded))
+
+	rec := &recordingTB{TB: t}
+	assert.False(t, AssertTTLVEqual(rec, expected, msg("red")))
+	require.Len(t, rec.errors, 1)
+	assert.Contains(t, rec.errors[0], `- RequestMessage.BatchItem.Comment[1]	TextString	"green"`)
+}
+
+func BenchmarkAssertTTLVEqual(b *testing.B) {
+	v, err := Marshal(NewStruct(TagRequestMessage, NewStruct(TagBatchItem, NewValue(TagOperation, OperationGet))))
+	require.NoError(b, err)
+
+	for i := 0; i < b.N; i++ {
+		AssertTTLVEqual(b, v, v)
+	}
+}
@@ -51,15 +51,23 @@ func (t Type) String() string {
 	return DefaultRegistry.FormatType(t)
 }
 
+// MarshalText implements encoding.TextMarshaler.  See String.
 func (t Type) MarshalText() (text []byte, err error) {
 	return []byte(t.String()), nil
 }
 
+// UnmarshalText implements encoding.TextUnmarshaler.  It parses
+// the text with ParseType.
 func (t *Type) UnmarshalText(text []byte) (err error) {
 	*t, err = DefaultRegistry.ParseType(string(text))
 	return
 }
 
+// Set implements flag.Value.  See UnmarshalText.
+func (t *Type) Set(s string) error {
+	return t.UnmarshalText([]byte(s))
+}
+
 // DateTimeExtended is a time wrapper which always marshals to a DateTimeExtended.
 type DateTimeExtended struct {
 	time.Time
@@ -177,10 +185,32 @@ func NewStruct(tag Tag, vals ...Value) Value {
 }
 
 type Encoder struct {
+	// ValidateTypes enables checking that values with tags registered as enums in the
+	// Registry are encoded with the matching type, using Registry.ValidateTypes().
+	// The check is made when values are flushed, and if it fails, Flush returns the error
+	// and discards the buffered values instead of writing them.  Off by default.
+	ValidateTypes bool
+	// ValidateOrder enables checking that the values in Structures with an order registered
+	// in the Registry appear in that order, using Registry.ValidateOrder(), for peers
+	// which reject values out of the spec's order.  Like ValidateTypes, the check is made when
+	// values are flushed.  Off by default.
+	ValidateOrder bool
+	// Reorder enables sorting the values in those Structures into the registered order when
+	// values are flushed, using Registry.Reorder(), instead of returning an error.  Off by default.
+	Reorder bool
+	// Registry is the registry ValidateTypes, ValidateOrder, and Reorder use, e.g. one with
+	// the orders of a different protocol version.  If nil, DefaultRegistry is used.  Tags and
+	// enum names are always resolved with DefaultRegistry when marshaling.
+	Registry *Registry
+
 	encodeDepth int
 	w           io.Writer
 	encBuf      encBuf
 
+	// err is an error from one of the Encode<Type> methods which don't return errors.
+	// It's returned by the next Flush.
+	err error
+
 	// these fields store where the encoder is when marshaling a nested struct.  its
 	// used to construct error messages.
 	currStruct string
@@ -19,7 +19,3 @@ type MessageExtension struct {
 	CriticalityIndicator bool
 	VendorExtension      interface{}
 }
-
-type Attributes struct {
-	Attributes []Attribute
-}
@@ -1,9 +1,15 @@
 package kmip
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
 	"time"
 
+	"github.com/ansel1/merry"
 	"github.com/gemalto/kmip-go/kmip14"
+	"github.com/gemalto/kmip-go/ttlv"
 )
 
 // 7.1
@@ -18,6 +24,198 @@ type ResponseMessage struct {
 	BatchItem      []ResponseBatchItem
 }
 
+// ValidateBatchCount returns an error wrapping ErrBatchCountMismatch if the Batch Count
+// in the header doesn't match the number of batch items in the message.
+func (m *RequestMessage) ValidateBatchCount() error {
+	return validateBatchCount(m.RequestHeader.BatchCount, len(m.BatchItem))
+}
+
+// ValidateBatchCount returns an error wrapping ErrBatchCountMismatch if the Batch Count
+// in the header doesn't match the number of batch items in the message.
+func (m *ResponseMessage) ValidateBatchCount() error {
+	return validateBatchCount(m.ResponseHeader.BatchCount, len(m.BatchItem))
+}
+
+func validateBatchCount(count, items int) error {
+	if count != items {
+		return merry.Appendf(ErrBatchCountMismatch, "Batch Count is %d, but there are %d batch items", count, items)
+	}
+
+	return nil
+}
+
+// requestPayloadTypes maps operations to the types of this package's request payloads
+// for them, for ValidateRequest().
+var requestPayloadTypes = map[kmip14.Operation]reflect.Type{
+	kmip14.OperationCreate:           reflect.TypeOf(CreateRequestPayload{}),
+	kmip14.OperationCreateKeyPair:    reflect.TypeOf(CreateKeyPairRequestPayload{}),
+	kmip14.OperationRegister:         reflect.TypeOf(RegisterRequestPayload{}),
+	kmip14.OperationGet:              reflect.TypeOf(GetRequestPayload{}),
+	kmip14.OperationGetAttributes:    reflect.TypeOf(GetAttributesRequestPayload{}),
+	kmip14.OperationCheck:            reflect.TypeOf(CheckRequestPayload{}),
+	kmip14.OperationRevoke:           reflect.TypeOf(RevokeRequestPayload{}),
+	kmip14.OperationDestroy:          reflect.TypeOf(DestroyRequestPayload{}),
+	kmip14.OperationLocate:           reflect.TypeOf(LocateRequestPayload{}),
+	kmip14.OperationArchive:          reflect.TypeOf(ArchiveRequestPayload{}),
+	kmip14.OperationRecover:          reflect.TypeOf(RecoverRequestPayload{}),
+	kmip14.OperationValidate:         reflect.TypeOf(ValidateRequestPayload{}),
+	kmip14.OperationQuery:            reflect.TypeOf(QueryRequestPayload{}),
+	kmip14.OperationDiscoverVersions: reflect.TypeOf(DiscoverVersionsRequestPayload{}),
+	kmip14.OperationCancel:           reflect.TypeOf(CancelRequestPayload{}),
+	kmip14.OperationNotify:           reflect.TypeOf(NotifyRequestPayload{}),
+	kmip14.OperationPut:              reflect.TypeOf(PutRequestPayload{}),
+}
+
+// ValidateRequest checks that a RequestMessage is assembled correctly for the protocol version,
+// e.g. before sending it.  It returns every problem found, rather than stopping at the first,
+// or nil if there are none:
+//
+//   - The header must have a Protocol Version, matching version.
+//   - There must be at least one batch item, and the Batch Count must match the number of items.
+//   - Each batch item must have an Operation and a Request Payload.  If the payload is one of
+//     this package's request payload types, it must be the one for the Operation.
+//   - The encoded header and batch items must pass the DefaultRegistry's ValidateTypes() and
+//     ValidateStructure() checks, and the Attribute Value of an attribute registered as an enum
+//     or bitmask must be encoded as an Enumeration or Integer respectively.
+//
+// Errors found in a batch item are prefixed with its index, e.g. "BatchItem[1]: ...".
+func ValidateRequest(msg RequestMessage, version ProtocolVersion) []error {
+	var errs []error
+
+	switch v := msg.RequestHeader.ProtocolVersion; {
+	case v.ProtocolVersionMajor == 0 && v.ProtocolVersionMinor == 0:
+		errs = append(errs, merry.New("RequestHeader: missing Protocol Version"))
+	case v != version:
+		errs = append(errs, merry.Errorf("RequestHeader: Protocol Version is %d.%d, expected %d.%d",
+			v.ProtocolVersionMajor, v.ProtocolVersionMinor, version.ProtocolVersionMajor, version.ProtocolVersionMinor))
+	}
+
+	if len(msg.BatchItem) == 0 {
+		errs = append(errs, merry.New("RequestMessage: no batch items"))
+	}
+
+	if err := msg.ValidateBatchCount(); err != nil {
+		errs = append(errs, merry.Prepend(err, "RequestHeader"))
+	}
+
+	for i := range msg.BatchItem {
+		bi := &msg.BatchItem[i]
+
+		switch {
+		case bi.Operation == 0:
+			errs = append(errs, merry.Errorf("BatchItem[%d]: missing Operation", i))
+		case bi.RequestPayload == nil:
+			errs = append(errs, merry.Errorf("BatchItem[%d]: missing Request Payload for %s", i, bi.Operation.String()))
+		default:
+			errs = append(errs, validateRequestPayload(i, bi.Operation, bi.RequestPayload)...)
+		}
+	}
+
+	b, err := ttlv.Marshal(msg)
+	if err != nil {
+		return append(errs, merry.Prepend(err, "RequestMessage"))
+	}
+
+	var item int
+
+	for n := b.ValueStructure(); len(n) > 0; n = n.Next() {
+		// check the contents of batch items, so errors can be prefixed with the item's index
+		// instead of the tag
+		values, prefix := n[:n.FullLen()], ""
+		if n.Tag() == kmip14.TagBatchItem {
+			values, prefix = n.ValueStructure(), fmt.Sprintf("BatchItem[%d]", item)
+			item++
+		}
+
+		found := []error{
+			ttlv.DefaultRegistry.ValidateTypes(values),
+			ttlv.DefaultRegistry.ValidateStructure(values),
+		}
+
+		for v := values; len(v) > 0; v = v.Next() {
+			found = append(found, validateAttributeValueTypes(v)...)
+		}
+
+		for _, err := range found {
+			switch {
+			case err == nil:
+			case prefix != "":
+				errs = append(errs, merry.Prepend(err, prefix))
+			default:
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	return errs
+}
+
+func validateRequestPayload(i int, op kmip14.Operation, payload interface{}) []error {
+	var errs []error
+
+	typ := reflect.Indirect(reflect.ValueOf(payload)).Type()
+
+	for expOp, expType := range requestPayloadTypes {
+		if typ == expType && expOp != op {
+			errs = append(errs, merry.Errorf("BatchItem[%d]: Request Payload for %s is a %s, which is the payload for %s",
+				i, op.String(), typ.Name(), expOp.String()))
+		}
+	}
+
+	return errs
+}
+
+// validateAttributeValueTypes checks the Attribute Value of each Attribute in t, at any depth, whose
+// Attribute Name is the name of a tag registered as an enum or bitmask.
+func validateAttributeValueTypes(t ttlv.TTLV) []error {
+	if t.Type() != ttlv.TypeStructure {
+		return nil
+	}
+
+	var errs []error
+
+	if t.Tag() == kmip14.TagAttribute {
+		var name string
+
+		for n := t.ValueStructure(); len(n) > 0; n = n.Next() {
+			switch n.Tag() {
+			case kmip14.TagAttributeName:
+				name = n.ValueTextString()
+			case kmip14.TagAttributeValue:
+				tag, err := ttlv.DefaultRegistry.ParseTag(ttlv.NormalizeName(name))
+				if err != nil {
+					continue
+				}
+
+				enum := ttlv.DefaultRegistry.EnumForTag(tag)
+				if enum == nil {
+					continue
+				}
+
+				expected := ttlv.TypeEnumeration
+				if enum.Bitmask() {
+					expected = ttlv.TypeInteger
+				}
+
+				if n.Type() != expected {
+					errs = append(errs, merry.Appendf(ttlv.ErrInvalidType, "Attribute Value of %s must be %s, got %s",
+						name, expected.String(), n.Type().String()))
+				}
+			}
+		}
+
+		return errs
+	}
+
+	for n := t.ValueStructure(); len(n) > 0; n = n.Next() {
+		for _, err := range validateAttributeValueTypes(n) {
+			errs = append(errs, merry.Prepend(err, ttlv.DefaultRegistry.FormatTag(t.Tag())))
+		}
+	}
+
+	return errs
+}
+
 // 7.2
 
 type RequestHeader struct {
@@ -62,3 +260,374 @@ type ResponseBatchItem struct {
 	ResponsePayload              interface{}         `ttlv:",omitempty"`
 	MessageExtension             *MessageExtension
 }
+
+// Err returns an *ItemError if the item's Result Status is Operation Failed or Operation
+// Undone.  Items with a Result Status of Success or Operation Pending return nil.
+func (bi *ResponseBatchItem) Err() error {
+	switch bi.ResultStatus {
+	case kmip14.ResultStatusSuccess, kmip14.ResultStatusOperationPending:
+		return nil
+	default:
+		return &ItemError{
+			Operation:         bi.Operation,
+			UniqueBatchItemID: bi.UniqueBatchItemID,
+			ResultStatus:      bi.ResultStatus,
+			ResultReason:      bi.ResultReason,
+			ResultMessage:     bi.ResultMessage,
+		}
+	}
+}
+
+// DecodePayload decodes the item's ResponsePayload into v, and returns the error from Err().
+// Failed and undone items usually don't carry a payload, but some do, like a failed Check,
+// which returns the constraints which weren't satisfied, so their payloads are decoded too,
+// but only on a best-effort basis: an error decoding the payload of a failed or undone item is
+// ignored, so it doesn't hide the *ItemError.  If there is no payload, v is not modified.
+func (bi *ResponseBatchItem) DecodePayload(v interface{}) error {
+	if itemErr := bi.Err(); itemErr != nil {
+		if b, err := coerceToTTLV(bi.ResponsePayload); err == nil && len(b) > 0 {
+			_ = ttlv.Unmarshal(b, v)
+		}
+
+		return itemErr
+	}
+
+	b, err := coerceToTTLV(bi.ResponsePayload)
+	if err != nil {
+		return err
+	}
+
+	if len(b) > 0 {
+		return ttlv.Unmarshal(b, v)
+	}
+
+	return nil
+}
+
+// MessageHeaders holds the header and the identifying fields of each batch item of a
+// message, without the payloads.  See DecodeHeadersOnly().
+type MessageHeaders struct {
+	// RequestHeader is set if the message is a RequestMessage.
+	RequestHeader *RequestHeader
+	// ResponseHeader is set if the message is a ResponseMessage.
+	ResponseHeader *ResponseHeader
+	BatchItem      []BatchItemHeader
+}
+
+// BatchItemHeader holds the fields of a batch item which identify it and its outcome.
+// The result fields are only set for response batch items.
+type BatchItemHeader struct {
+	Operation                    kmip14.Operation
+	UniqueBatchItemID            []byte
+	ResultStatus                 kmip14.ResultStatus
+	ResultReason                 kmip14.ResultReason
+	ResultMessage                string
+	AsynchronousCorrelationValue []byte
+}
+
+// DecodeHeadersOnly decodes the header of a RequestMessage or ResponseMessage, and the
+// identifying fields of each batch item, without decoding the payloads.  Payloads, and
+// any other values in a batch item, are skipped over using their encoded lengths, so
+// this is cheap even for messages carrying large amounts of key material, which is
+// useful for routing and logging.
+//
+// The skipped values are not validated, other than checking that their headers are
+// valid and that they fit within the enclosing value, so DecodeHeadersOnly may succeed
+// on a message that a full decode would reject.
+func DecodeHeadersOnly(t ttlv.TTLV) (*MessageHeaders, error) {
+	l, err := fullLenWithin(t)
+	if err != nil {
+		return nil, err
+	}
+
+	t = t[:l]
+
+	var mh MessageHeaders
+
+	switch t.Tag() {
+	case kmip14.TagRequestMessage, kmip14.TagResponseMessage:
+	default:
+		return nil, merry.Errorf("invalid tag: expected RequestMessage or ResponseMessage, was %s", t.Tag().String())
+	}
+
+	for n := t.ValueStructure(); len(n) > 0; {
+		l, err := fullLenWithin(n)
+		if err != nil {
+			return nil, merry.Prepend(err, t.Tag().String())
+		}
+
+		switch n.Tag() {
+		case kmip14.TagRequestHeader:
+			mh.RequestHeader = &RequestHeader{}
+			err = ttlv.Unmarshal(n[:l], mh.RequestHeader)
+		case kmip14.TagResponseHeader:
+			mh.ResponseHeader = &ResponseHeader{}
+			err = ttlv.Unmarshal(n[:l], mh.ResponseHeader)
+		case kmip14.TagBatchItem:
+			var bi BatchItemHeader
+			err = bi.decode(n[:l])
+			mh.BatchItem = append(mh.BatchItem, bi)
+		}
+
+		if err != nil {
+			return nil, merry.Prepend(err, t.Tag().String())
+		}
+
+		n = n[l:]
+	}
+
+	return &mh, nil
+}
+
+// checkMaxBatchItems returns ErrTooManyBatchItems if the Batch Count in the header of msg,
+// or the number of batch items in msg, exceeds max.  It stops counting as soon as the limit
+// is exceeded, and doesn't decode the batch items, so it's cheap to call before decoding the
+// message.  msg should already be valid TTLV.
+func checkMaxBatchItems(msg ttlv.TTLV, max int) error {
+	var count int
+
+	for n := msg.ValueStructure(); len(n) > 0; n = n.Next() {
+		switch n.Tag() {
+		case kmip14.TagRequestHeader, kmip14.TagResponseHeader:
+			for h := n.ValueStructure(); len(h) > 0; h = h.Next() {
+				if h.Tag() == kmip14.TagBatchCount && h.Type() == ttlv.TypeInteger && int(h.ValueInteger()) > max {
+					return merry.Appendf(ErrTooManyBatchItems, "Batch Count %d exceeds the limit of %d", h.ValueInteger(), max)
+				}
+			}
+		case kmip14.TagBatchItem:
+			count++
+			if count > max {
+				return merry.Appendf(ErrTooManyBatchItems, "message has more than the limit of %d batch items", max)
+			}
+		}
+	}
+
+	return nil
+}
+
+// PeekProtocolVersion returns the Protocol Version from the header of a RequestMessage or
+// ResponseMessage, without decoding the rest of the message, e.g. so a server can choose how to
+// handle a request before decoding it.  Like DecodeHeadersOnly, the values it skips over are not
+// validated.  An error is returned if the message doesn't have a header with a Protocol Version,
+// or the Protocol Version is malformed.
+func PeekProtocolVersion(msg ttlv.TTLV) (major, minor int, err error) {
+	l, err := fullLenWithin(msg)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var headerTag ttlv.Tag
+
+	switch msg.Tag() {
+	case kmip14.TagRequestMessage:
+		headerTag = kmip14.TagRequestHeader
+	case kmip14.TagResponseMessage:
+		headerTag = kmip14.TagResponseHeader
+	default:
+		return 0, 0, merry.Errorf("invalid tag: expected RequestMessage or ResponseMessage, was %s", msg.Tag().String())
+	}
+
+	// find returns the first value in the structure t with tag, or nil
+	find := func(t ttlv.TTLV, tag ttlv.Tag) (ttlv.TTLV, error) {
+		if t.Type() != ttlv.TypeStructure {
+			return nil, merry.Appendf(ttlv.ErrInvalidType, "%s must be Structure, got %s", t.Tag().String(), t.Type().String())
+		}
+
+		for n := t.ValueStructure(); len(n) > 0; {
+			l, err := fullLenWithin(n)
+			if err != nil {
+				return nil, merry.Prepend(err, t.Tag().String())
+			}
+
+			if n.Tag() == tag {
+				return n[:l], nil
+			}
+
+			n = n[l:]
+		}
+
+		return nil, merry.Errorf("%s: missing %s", t.Tag().String(), tag.String())
+	}
+
+	header, err := find(msg[:l], headerTag)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	pv, err := find(header, kmip14.TagProtocolVersion)
+	if err != nil {
+		return 0, 0, merry.Prepend(err, msg.Tag().String())
+	}
+
+	var v ProtocolVersion
+
+	err = ttlv.DefaultRegistry.ValidateStructure(pv)
+	if err == nil {
+		err = ttlv.Unmarshal(pv, &v)
+	}
+
+	if err != nil {
+		return 0, 0, merry.Prependf(err, "%s: %s", msg.Tag().String(), headerTag.String())
+	}
+
+	return v.ProtocolVersionMajor, v.ProtocolVersionMinor, nil
+}
+
+func (bi *BatchItemHeader) decode(t ttlv.TTLV) error {
+	for n := t.ValueStructure(); len(n) > 0; {
+		l, err := fullLenWithin(n)
+		if err != nil {
+			return merry.Prepend(err, t.Tag().String())
+		}
+
+		var v uint32
+
+		switch n.Tag() {
+		case kmip14.TagOperation:
+			v, err = n.AsEnum()
+			bi.Operation = kmip14.Operation(v)
+		case kmip14.TagUniqueBatchItemID:
+			bi.UniqueBatchItemID, err = n.AsBytes()
+		case kmip14.TagResultStatus:
+			v, err = n.AsEnum()
+			bi.ResultStatus = kmip14.ResultStatus(v)
+		case kmip14.TagResultReason:
+			v, err = n.AsEnum()
+			bi.ResultReason = kmip14.ResultReason(v)
+		case kmip14.TagResultMessage:
+			bi.ResultMessage, err = n.AsString()
+		case kmip14.TagAsynchronousCorrelationValue:
+			bi.AsynchronousCorrelationValue, err = n.AsBytes()
+		}
+
+		if err != nil {
+			return merry.Prepend(err, t.Tag().String())
+		}
+
+		n = n[l:]
+	}
+
+	return nil
+}
+
+// fullLenWithin returns the full length of the value at the start of t, after checking
+// that its header is valid and that the whole value fits within t.  Unlike Valid(), it
+// doesn't check the contents of the value.
+func fullLenWithin(t ttlv.TTLV) (int, error) {
+	if err := t.ValidHeader(); err != nil {
+		return 0, err
+	}
+
+	l := t.FullLen()
+	if l > len(t) {
+		return 0, merry.Here(ttlv.ErrValueTruncated)
+	}
+
+	return l, nil
+}
+
+// BatchItemReader reads a RequestMessage from a stream one batch item at a time, so each item
+// can be processed as soon as it has been read, rather than after the whole message has been
+// read and decoded.  Only one batch item is held in memory at a time, which bounds the memory
+// used for messages with very large batches.
+//
+// The RequestHeader must come before the batch items, as the spec requires.
+type BatchItemReader struct {
+	// RequestHeader is the header of the message, read by NewBatchItemReader.
+	RequestHeader RequestHeader
+
+	dec *ttlv.Decoder
+	// end is the length of the message's value, i.e. the decoder's offset once all items are read
+	end  int64
+	next int
+	err  error
+}
+
+// NewBatchItemReader reads the start of a RequestMessage from r, up to and including
+// its RequestHeader.  The batch items are then read by calling Next().  Only the bytes
+// of the RequestMessage are read from r.
+func NewBatchItemReader(r io.Reader) (*BatchItemReader, error) {
+	var header [8]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, merry.Prepend(err, "RequestMessage")
+	}
+
+	t := ttlv.TTLV(header[:])
+	if err := t.ValidHeader(); err != nil {
+		return nil, merry.Prepend(err, "RequestMessage")
+	}
+
+	if t.Tag() != kmip14.TagRequestMessage || t.Type() != ttlv.TypeStructure {
+		return nil, merry.Errorf("invalid tag: expected RequestMessage structure, was %s %s", t.Tag().String(), t.Type().String())
+	}
+
+	br := BatchItemReader{
+		dec: ttlv.NewDecoder(io.LimitReader(r, int64(t.Len()))),
+		end: int64(t.Len()),
+	}
+
+	v, err := br.dec.NextTTLV()
+	if err != nil {
+		return nil, merry.Prepend(err, "RequestMessage: reading RequestHeader")
+	}
+
+	if v.Tag() != kmip14.TagRequestHeader {
+		return nil, merry.Errorf("RequestMessage: expected RequestHeader, was %s", v.Tag().String())
+	}
+
+	if err := br.dec.DecodeValue(&br.RequestHeader, v); err != nil {
+		return nil, merry.Prepend(err, "RequestMessage")
+	}
+
+	return &br, nil
+}
+
+// Next reads and decodes the next batch item.  Items are returned in the order they appear in the
+// message.  The Request Payload of the item is left as a ttlv.TTLV, to be decoded by the caller
+// once it knows the payload type from the Operation.
+//
+// Next returns io.EOF once all the items have been read.  If an item can't be read or decoded,
+// the error is prefixed with the item's index, e.g. "BatchItem[3]", and all subsequent calls
+// return the same error, since the rest of the stream can't be read reliably.
+func (br *BatchItemReader) Next() (*RequestBatchItem, error) {
+	if br.err != nil {
+		return nil, br.err
+	}
+
+	if br.dec.InputOffset() >= br.end {
+		br.err = io.EOF
+		return nil, io.EOF
+	}
+
+	i := br.next
+
+	v, err := br.dec.NextTTLV()
+	if errors.Is(err, io.EOF) {
+		// the stream ended before the end of the message
+		err = merry.WithCause(io.ErrUnexpectedEOF, err)
+	}
+
+	if err == nil {
+		err = v.Valid()
+	}
+
+	if err != nil {
+		br.err = merry.Prependf(err, "BatchItem[%d]", i)
+		return nil, br.err
+	}
+
+	if v.Tag() != kmip14.TagBatchItem {
+		br.err = merry.Errorf("BatchItem[%d]: expected BatchItem, was %s", i, v.Tag().String())
+		return nil, br.err
+	}
+
+	var item RequestBatchItem
+	if err := br.dec.DecodeValue(&item, v); err != nil {
+		br.err = merry.Prependf(err, "BatchItem[%d]", i)
+		return nil, br.err
+	}
+
+	br.next++
+
+	return &item, nil
+}
@@ -0,0 +1,432 @@
+package kmip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/gemalto/kmip-go/kmip14"
+	"github.com/gemalto/kmip-go/ttlv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestHeader_marshal(t *testing.T) {
+	// the optional header fields must be encoded in the order given by the spec (7.2 Table 274)
+	ts := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	h := RequestHeader{
+		ProtocolVersion:        ProtocolVersion{ProtocolVersionMajor: 1, ProtocolVersionMinor: 4},
+		MaximumResponseSize:    4096,
+		ClientCorrelationValue: "client-1",
+		ServerCorrelationValue: "server-1",
+		AsynchronousIndicator:  true,
+		BatchOrderOption:       true,
+		TimeStamp:              &ts,
+		BatchCount:             1,
+	}
+
+	b, err := ttlv.Marshal(h)
+	require.NoError(t, err)
+
+	expected, err := ttlv.Marshal(ttlv.Value{Tag: kmip14.TagRequestHeader, Value: ttlv.Values{
+		{Tag: kmip14.TagProtocolVersion, Value: ttlv.Values{
+			{Tag: kmip14.TagProtocolVersionMajor, Value: 1},
+			{Tag: kmip14.TagProtocolVersionMinor, Value: 4},
+		}},
+		{Tag: kmip14.TagMaximumResponseSize, Value: 4096},
+		{Tag: kmip14.TagClientCorrelationValue, Value: "client-1"},
+		{Tag: kmip14.TagServerCorrelationValue, Value: "server-1"},
+		{Tag: kmip14.TagAsynchronousIndicator, Value: true},
+		{Tag: kmip14.TagBatchOrderOption, Value: true},
+		{Tag: kmip14.TagTimeStamp, Value: ts},
+		{Tag: kmip14.TagBatchCount, Value: 1},
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, expected, b)
+
+	var decoded RequestHeader
+	require.NoError(t, ttlv.Unmarshal(b, &decoded))
+	assert.Equal(t, h, decoded)
+}
+
+func TestResponseBatchItem_DecodePayload(t *testing.T) {
+	decode := func(bi ResponseBatchItem) ResponseBatchItem {
+		b, err := ttlv.Marshal(ttlv.Value{Tag: kmip14.TagBatchItem, Value: bi})
+		require.NoError(t, err)
+
+		var decoded ResponseBatchItem
+		require.NoError(t, ttlv.Unmarshal(b, &decoded))
+
+		return decoded
+	}
+
+	bi := decode(ResponseBatchItem{
+		Operation:       kmip14.OperationGet,
+		ResultStatus:    kmip14.ResultStatusSuccess,
+		ResponsePayload: GetResponsePayload{ObjectType: kmip14.ObjectTypeSymmetricKey, UniqueIdentifier: "1"},
+	})
+	require.NoError(t, bi.Err())
+
+	var p GetResponsePayload
+	require.NoError(t, bi.DecodePayload(&p))
+	assert.Equal(t, "1", p.UniqueIdentifier)
+
+	// pending items may not have a payload
+	bi = decode(ResponseBatchItem{
+		Operation:                    kmip14.OperationGet,
+		ResultStatus:                 kmip14.ResultStatusOperationPending,
+		AsynchronousCorrelationValue: []byte{1, 2},
+	})
+	require.NoError(t, bi.Err())
+	require.NoError(t, bi.DecodePayload(&GetResponsePayload{}))
+
+	bi = decode(ResponseBatchItem{
+		Operation:     kmip14.OperationGet,
+		ResultStatus:  kmip14.ResultStatusOperationFailed,
+		ResultReason:  kmip14.ResultReasonItemNotFound,
+		ResultMessage: "no such key",
+	})
+	err := bi.DecodePayload(&GetResponsePayload{})
+
+	var itemErr *ItemError
+
+	require.True(t, errors.As(err, &itemErr))
+	assert.False(t, itemErr.Undone())
+	assert.Equal(t, kmip14.ResultReasonItemNotFound, itemErr.ResultReason)
+	assert.EqualError(t, err, "kmip: Get: OperationFailed: ItemNotFound: no such key")
+
+	bi = decode(ResponseBatchItem{
+		Operation:    kmip14.OperationCreate,
+		ResultStatus: kmip14.ResultStatusOperationUndone,
+	})
+	err = bi.DecodePayload(&CreateResponsePayload{})
+	require.True(t, errors.As(err, &itemErr))
+	assert.True(t, itemErr.Undone())
+	assert.EqualError(t, err, "kmip: Create: OperationUndone (rolled back after another batch item failed)")
+
+	// a malformed payload on a failed item doesn't hide the item's error
+	bi = decode(ResponseBatchItem{
+		Operation:       kmip14.OperationGet,
+		ResultStatus:    kmip14.ResultStatusOperationFailed,
+		ResultReason:    kmip14.ResultReasonItemNotFound,
+		ResponsePayload: s(kmip14.TagResponsePayload, v(kmip14.TagUniqueIdentifier, 5)),
+	})
+	err = bi.DecodePayload(&GetResponsePayload{})
+	require.True(t, errors.As(err, &itemErr), Details(err))
+	assert.Equal(t, kmip14.ResultReasonItemNotFound, itemErr.ResultReason)
+
+	// but the payload of a successful item must decode
+	bi.ResultStatus, bi.ResultReason = kmip14.ResultStatusSuccess, 0
+	err = bi.DecodePayload(&GetResponsePayload{})
+	require.Error(t, err)
+	require.False(t, errors.As(err, &itemErr))
+}
+
+func TestDecodeHeadersOnly(t *testing.T) {
+	msg := ResponseMessage{
+		ResponseHeader: ResponseHeader{
+			ProtocolVersion: ProtocolVersion{ProtocolVersionMajor: 1, ProtocolVersionMinor: 4},
+			TimeStamp:       time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC),
+			BatchCount:      2,
+		},
+		BatchItem: []ResponseBatchItem{
+			{
+				Operation:         kmip14.OperationGet,
+				UniqueBatchItemID: []byte{1},
+				ResultStatus:      kmip14.ResultStatusSuccess,
+				ResponsePayload: GetResponsePayload{
+					ObjectType:       kmip14.ObjectTypeOpaqueObject,
+					UniqueIdentifier: "1",
+					OpaqueObject:     &OpaqueObject{OpaqueDataType: 0x80000001, OpaqueDataValue: make([]byte, 1<<20)},
+				},
+			},
+			{
+				Operation:         kmip14.OperationDestroy,
+				UniqueBatchItemID: []byte{2},
+				ResultStatus:      kmip14.ResultStatusOperationFailed,
+				ResultReason:      kmip14.ResultReasonItemNotFound,
+				ResultMessage:     "not found",
+			},
+		},
+	}
+
+	b, err := ttlv.Marshal(msg)
+	require.NoError(t, err)
+
+	mh, err := DecodeHeadersOnly(b)
+	require.NoError(t, err)
+	assert.Nil(t, mh.RequestHeader)
+	require.NotNil(t, mh.ResponseHeader)
+	assert.Equal(t, 2, mh.ResponseHeader.BatchCount)
+	assert.Equal(t, []BatchItemHeader{
+		{Operation: kmip14.OperationGet, UniqueBatchItemID: []byte{1}, ResultStatus: kmip14.ResultStatusSuccess},
+		{
+			Operation:         kmip14.OperationDestroy,
+			UniqueBatchItemID: []byte{2},
+			ResultStatus:      kmip14.ResultStatusOperationFailed,
+			ResultReason:      kmip14.ResultReasonItemNotFound,
+			ResultMessage:     "not found",
+		},
+	}, mh.BatchItem)
+
+	// a payload which claims to extend past the end of the message is rejected
+	corrupt := append(ttlv.TTLV{}, b...)
+	payload := bytes.Index(corrupt, []byte{0x42, 0x00, 0x7c, 0x01})
+	require.Greater(t, payload, 0)
+	binary.BigEndian.PutUint32(corrupt[payload+4:], 1<<21)
+
+	_, err = DecodeHeadersOnly(corrupt)
+	require.True(t, errors.Is(err, ttlv.ErrValueTruncated), Details(err))
+
+	_, err = DecodeHeadersOnly(b[:100])
+	require.True(t, errors.Is(err, ttlv.ErrValueTruncated), Details(err))
+}
+
+func TestValidateRequest(t *testing.T) {
+	v14 := ProtocolVersion{ProtocolVersionMajor: 1, ProtocolVersionMinor: 4}
+
+	var ta TemplateAttribute
+	ta.Append(kmip14.TagCryptographicAlgorithm, kmip14.CryptographicAlgorithmAES)
+	ta.Append(kmip14.TagCryptographicLength, 256)
+
+	valid := RequestMessage{
+		RequestHeader: RequestHeader{ProtocolVersion: v14, BatchCount: 2},
+		BatchItem: []RequestBatchItem{
+			{
+				Operation: kmip14.OperationCreate,
+				RequestPayload: CreateRequestPayload{
+					ObjectType:        kmip14.ObjectTypeSymmetricKey,
+					TemplateAttribute: ta,
+				},
+			},
+			{
+				Operation:      kmip14.OperationGet,
+				RequestPayload: &GetRequestPayload{},
+			},
+		},
+	}
+	assert.Empty(t, ValidateRequest(valid, v14))
+
+	var badAttrs TemplateAttribute
+	// the enum value is encoded as an Integer
+	badAttrs.Append(kmip14.TagCryptographicAlgorithm, 3)
+
+	invalid := RequestMessage{
+		RequestHeader: RequestHeader{ProtocolVersion: v14, BatchCount: 4},
+		BatchItem: []RequestBatchItem{
+			{
+				Operation: kmip14.OperationCreate,
+				RequestPayload: CreateRequestPayload{
+					ObjectType:        kmip14.ObjectTypeSymmetricKey,
+					TemplateAttribute: badAttrs,
+				},
+			},
+			{
+				Operation:      kmip14.OperationDestroy,
+				RequestPayload: &GetRequestPayload{},
+			},
+			{
+				Operation: kmip14.OperationGet,
+			},
+		},
+	}
+
+	errs := ValidateRequest(invalid, ProtocolVersion{ProtocolVersionMajor: 2})
+
+	var msgs []string
+	for _, err := range errs {
+		msgs = append(msgs, err.Error())
+	}
+
+	assert.Equal(t, []string{
+		"RequestHeader: Protocol Version is 1.4, expected 2.0",
+		"RequestHeader: batch count does not match the number of batch items: Batch Count is 4, but there are 3 batch items",
+		"BatchItem[1]: Request Payload for Destroy is a GetRequestPayload, which is the payload for Get",
+		"BatchItem[2]: missing Request Payload for Get",
+		"BatchItem[0]: RequestPayload: TemplateAttribute: invalid KMIP type: Attribute Value of Cryptographic Algorithm must be Enumeration, got Integer",
+	}, msgs)
+	assert.True(t, errors.Is(errs[1], ErrBatchCountMismatch))
+	assert.True(t, errors.Is(errs[4], ttlv.ErrInvalidType))
+
+	errs = ValidateRequest(RequestMessage{}, v14)
+	require.Len(t, errs, 2)
+	assert.EqualError(t, errs[0], "RequestHeader: missing Protocol Version")
+	assert.EqualError(t, errs[1], "RequestMessage: no batch items")
+}
+
+func TestBatchItemReader(t *testing.T) {
+	msg := RequestMessage{
+		RequestHeader: RequestHeader{
+			ProtocolVersion: ProtocolVersion{ProtocolVersionMajor: 1, ProtocolVersionMinor: 4},
+			BatchCount:      3,
+		},
+	}
+
+	for _, id := range []string{"1", "2", "3"} {
+		msg.BatchItem = append(msg.BatchItem, RequestBatchItem{
+			Operation:      kmip14.OperationGet,
+			RequestPayload: GetRequestPayload{UniqueIdentifier: id},
+		})
+	}
+
+	b, err := ttlv.Marshal(msg)
+	require.NoError(t, err)
+
+	// the bytes following the message must not be read
+	r := bytes.NewReader(append(append([]byte{}, b...), 0xff))
+
+	br, err := NewBatchItemReader(r)
+	require.NoError(t, err)
+	assert.Equal(t, msg.RequestHeader, br.RequestHeader)
+
+	var ids []string
+
+	for {
+		item, err := br.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		require.NoError(t, err)
+		assert.Equal(t, kmip14.OperationGet, item.Operation)
+
+		var p GetRequestPayload
+		require.NoError(t, ttlv.Unmarshal(item.RequestPayload.(ttlv.TTLV), &p))
+
+		ids = append(ids, p.UniqueIdentifier)
+	}
+
+	assert.Equal(t, []string{"1", "2", "3"}, ids)
+	assert.Equal(t, 1, r.Len())
+
+	// the stream ends in the middle of the second item
+	br, err = NewBatchItemReader(bytes.NewReader(b[:len(b)-70]))
+	require.NoError(t, err)
+
+	_, err = br.Next()
+	require.NoError(t, err)
+
+	_, err = br.Next()
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, io.ErrUnexpectedEOF), Details(err))
+	assert.Contains(t, err.Error(), "BatchItem[1]")
+
+	_, err2 := br.Next()
+	assert.Equal(t, err, err2)
+
+	_, err = NewBatchItemReader(bytes.NewReader(b[len(b)-16:]))
+	require.Error(t, err)
+}
+
+func TestPeekProtocolVersion(t *testing.T) {
+	b, err := ttlv.Marshal(RequestMessage{
+		RequestHeader: RequestHeader{
+			ProtocolVersion: ProtocolVersion{ProtocolVersionMajor: 2, ProtocolVersionMinor: 1},
+			BatchCount:      1,
+		},
+		BatchItem: []RequestBatchItem{{Operation: kmip14.OperationQuery, RequestPayload: QueryRequestPayload{}}},
+	})
+	require.NoError(t, err)
+
+	major, minor, err := PeekProtocolVersion(b)
+	require.NoError(t, err)
+	assert.Equal(t, 2, major)
+	assert.Equal(t, 1, minor)
+
+	b, err = ttlv.Marshal(ResponseMessage{
+		ResponseHeader: ResponseHeader{ProtocolVersion: ProtocolVersion{ProtocolVersionMajor: 1, ProtocolVersionMinor: 4}},
+	})
+	require.NoError(t, err)
+
+	major, minor, err = PeekProtocolVersion(b)
+	require.NoError(t, err)
+	assert.Equal(t, 1, major)
+	assert.Equal(t, 4, minor)
+
+	tests := map[string]struct {
+		msg ttlv.Value
+		err string
+	}{
+		"noheader": {
+			msg: ttlv.NewStruct(kmip14.TagRequestMessage, ttlv.NewStruct(kmip14.TagBatchItem)),
+			err: "RequestMessage: missing RequestHeader",
+		},
+		"noversion": {
+			msg: ttlv.NewStruct(kmip14.TagRequestMessage, ttlv.NewStruct(kmip14.TagRequestHeader,
+				ttlv.NewValue(kmip14.TagBatchCount, 1),
+			)),
+			err: "RequestMessage: RequestHeader: missing ProtocolVersion",
+		},
+		"nominor": {
+			msg: ttlv.NewStruct(kmip14.TagRequestMessage, ttlv.NewStruct(kmip14.TagRequestHeader,
+				ttlv.NewStruct(kmip14.TagProtocolVersion, ttlv.NewValue(kmip14.TagProtocolVersionMajor, 1)),
+			)),
+			err: "RequestMessage: RequestHeader: ProtocolVersion missing required ProtocolVersionMinor",
+		},
+		"notamessage": {
+			msg: ttlv.NewStruct(kmip14.TagRequestHeader),
+			err: "invalid tag: expected RequestMessage or ResponseMessage, was RequestHeader",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			b, err := ttlv.Marshal(tc.msg)
+			require.NoError(t, err)
+
+			_, _, err = PeekProtocolVersion(b)
+			require.EqualError(t, err, tc.err)
+		})
+	}
+
+	_, _, err = PeekProtocolVersion(b[:20])
+	assert.True(t, errors.Is(err, ttlv.ErrValueTruncated), Details(err))
+}
+
+func TestMessages_canonicalOrder(t *testing.T) {
+	// the message structs encode their values in the order the spec defines
+	b, err := ttlv.Marshal(RequestMessage{
+		RequestHeader: RequestHeader{
+			ProtocolVersion:              ProtocolVersion{ProtocolVersionMajor: 1, ProtocolVersionMinor: 4},
+			MaximumResponseSize:          1000,
+			ClientCorrelationValue:       "c",
+			BatchErrorContinuationOption: kmip14.BatchErrorContinuationOptionContinue,
+			BatchOrderOption:             true,
+			BatchCount:                   1,
+		},
+		BatchItem: []RequestBatchItem{{
+			Operation:         kmip14.OperationRegister,
+			UniqueBatchItemID: []byte{1},
+			RequestPayload: RegisterRequestPayload{
+				ObjectType: kmip14.ObjectTypeSymmetricKey,
+				SymmetricKey: &SymmetricKey{KeyBlock: KeyBlock{
+					KeyFormatType:          kmip14.KeyFormatTypeRaw,
+					KeyValue:               &KeyValue{KeyMaterial: []byte{1, 2, 3}},
+					CryptographicAlgorithm: kmip14.CryptographicAlgorithmAES,
+					CryptographicLength:    128,
+				}},
+			},
+		}},
+	})
+	require.NoError(t, err)
+	require.NoError(t, ttlv.DefaultRegistry.ValidateOrder(b))
+
+	b, err = ttlv.Marshal(ResponseMessage{
+		ResponseHeader: ResponseHeader{
+			ProtocolVersion:        ProtocolVersion{ProtocolVersionMajor: 1, ProtocolVersionMinor: 4},
+			TimeStamp:              time.Now(),
+			ClientCorrelationValue: "c",
+			BatchCount:             1,
+		},
+		BatchItem: []ResponseBatchItem{{
+			Operation:       kmip14.OperationRegister,
+			ResultStatus:    kmip14.ResultStatusSuccess,
+			ResponsePayload: RegisterResponsePayload{UniqueIdentifier: "1"},
+		}},
+	})
+	require.NoError(t, err)
+	require.NoError(t, ttlv.DefaultRegistry.ValidateOrder(b))
+}